@@ -162,6 +162,11 @@ func (lru *Lru) Set(item LruItem) (added bool, droppedItem LruItem, err error) {
 		// Prune.
 		if node.before != nil {
 			node.before.after = node.after
+
+			if node.after != nil {
+				node.after.before = node.before
+			}
+
 			node.before = nil
 		}
 
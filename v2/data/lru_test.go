@@ -671,3 +671,42 @@ func TestLru_MaxCount(t *testing.T) {
 		t.Fatalf("MaxCount not correct (2).")
 	}
 }
+
+func TestLru_Set_MoveMiddleToFront(t *testing.T) {
+	lru := NewLru(3)
+
+	for _, id := range []int{11, 22, 33} {
+		_, _, err := lru.Set(testLruItem{id: id})
+		log.PanicIf(err)
+	}
+
+	// Bump the middle one. The neighbors have to be relinked.
+
+	_, _, err := lru.Set(testLruItem{id: 22})
+	log.PanicIf(err)
+
+	positions := []int{
+		lru.FindPosition(22),
+		lru.FindPosition(33),
+		lru.FindPosition(11),
+	}
+
+	if reflect.DeepEqual(positions, []int{0, 1, 2}) != true {
+		t.Fatalf("Positions not correct: %v", positions)
+	}
+
+	// Evicting has to drop the oldest and only the oldest.
+
+	for _, id := range []int{44, 55} {
+		_, dropped, err := lru.Set(testLruItem{id: id})
+		log.PanicIf(err)
+
+		if dropped == nil {
+			t.Fatalf("Nothing was dropped for (%d).", id)
+		}
+	}
+
+	if lru.Exists(11) != false || lru.Exists(33) != false || lru.Exists(22) != true {
+		t.Fatalf("Wrong items were dropped: %v", lru.All())
+	}
+}
@@ -2,6 +2,7 @@ package ridata
 
 import (
 	"io"
+	"io/fs"
 	"os"
 	"strings"
 
@@ -84,3 +85,33 @@ func DetectMimetype(f *os.File) (mimetype string, err error) {
 
 	return mimetype, nil
 }
+
+// DetectMimetypeWithFilesystem is a variant of DetectMimetype that opens the
+// given path on the given filesystem. An empty-string is returned if it is a
+// zero-length file.
+func DetectMimetypeWithFilesystem(fsys fs.FS, filepath string) (mimetype string, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	f, err := fsys.Open(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	log.PanicIf(err)
+
+	fileSize := fi.Size()
+
+	if fileSize == 0 {
+		return "", nil
+	}
+
+	mimetype, err = GetMimetypeFromContent(f, fileSize)
+	log.PanicIf(err)
+
+	return mimetype, nil
+}
@@ -3,11 +3,11 @@ package ridata
 import (
 	"bytes"
 	"testing"
+	"testing/fstest"
 
 	"encoding/hex"
 
 	"github.com/dsoprea/go-logging"
-	"github.com/dsoprea/go-utility/v2/filesystem"
 )
 
 func TestGetMimetypeFromContent(t *testing.T) {
@@ -34,7 +34,7 @@ func TestGetMimetypeFromContent__ShortData_Error(t *testing.T) {
 
 	buffer = buffer[:256]
 
-	b := rifs.NewSeekableBufferWithBytes(buffer)
+	b := bytes.NewReader(buffer)
 
 	_, err = GetMimetypeFromContent(b, 0)
 	if err == nil {
@@ -52,7 +52,7 @@ func TestGetMimetypeFromContent__ShortData_Success(t *testing.T) {
 
 	buffer = buffer[:256]
 
-	b := rifs.NewSeekableBufferWithBytes(buffer)
+	b := bytes.NewReader(buffer)
 
 	mimetype, err := GetMimetypeFromContent(b, int64(len(buffer)))
 	log.PanicIf(err)
@@ -61,3 +61,24 @@ func TestGetMimetypeFromContent__ShortData_Success(t *testing.T) {
 		t.Fatalf("Mime-type not correct: [%s]", mimetype)
 	}
 }
+
+func TestDetectMimetypeWithFilesystem(t *testing.T) {
+	mapFs := fstest.MapFS{
+		"image.png": &fstest.MapFile{Data: []byte("\x89PNG\x0D\x0A\x1A\x0A")},
+		"empty":     &fstest.MapFile{Data: []byte{}},
+	}
+
+	mimetype, err := DetectMimetypeWithFilesystem(mapFs, "image.png")
+	log.PanicIf(err)
+
+	if mimetype != "image/png" {
+		t.Fatalf("Mime-type not correct: [%s]", mimetype)
+	}
+
+	mimetype, err = DetectMimetypeWithFilesystem(mapFs, "empty")
+	log.PanicIf(err)
+
+	if mimetype != "" {
+		t.Fatalf("Mime-type for empty file not correct: [%s]", mimetype)
+	}
+}
@@ -0,0 +1,185 @@
+package rifs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrAtomicFileFinished is returned when an `AtomicFile` is used after it
+	// was committed or aborted.
+	ErrAtomicFileFinished = errors.New("atomic file already committed or aborted")
+)
+
+// AtomicFile is a `ReadWriteSeekCloser` that is backed by a temporary file in
+// the same directory as its destination. The destination is only replaced,
+// in a single rename, when `Commit` is called. If the process dies first, the
+// destination is untouched and, at worst, the temporary file is left behind.
+type AtomicFile struct {
+	filepath     string
+	tempFilepath string
+
+	f        *os.File
+	finished bool
+}
+
+// NewAtomicFile returns a new AtomicFile instance. `perm` is the mode that the
+// destination will have.
+func NewAtomicFile(filepath string, perm os.FileMode) (af *AtomicFile, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	// It has to be in the same directory in order for the rename to be
+	// atomic.
+	f, err := ioutil.TempFile(path.Dir(filepath), "."+path.Base(filepath)+".tmp-")
+	log.PanicIf(err)
+
+	err = f.Chmod(perm)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		log.Panic(err)
+	}
+
+	af = &AtomicFile{
+		filepath:     filepath,
+		tempFilepath: f.Name(),
+		f:            f,
+	}
+
+	return af, nil
+}
+
+// Name returns the path of the destination.
+func (af *AtomicFile) Name() string {
+	return af.filepath
+}
+
+// TempName returns the path of the temporary file.
+func (af *AtomicFile) TempName() string {
+	return af.tempFilepath
+}
+
+// Read reads from the temporary file.
+func (af *AtomicFile) Read(buffer []byte) (n int, err error) {
+	if af.finished == true {
+		return 0, ErrAtomicFileFinished
+	}
+
+	return af.f.Read(buffer)
+}
+
+// Write writes to the temporary file.
+func (af *AtomicFile) Write(buffer []byte) (n int, err error) {
+	if af.finished == true {
+		return 0, ErrAtomicFileFinished
+	}
+
+	return af.f.Write(buffer)
+}
+
+// Seek seeks within the temporary file.
+func (af *AtomicFile) Seek(offset int64, whence int) (n int64, err error) {
+	if af.finished == true {
+		return 0, ErrAtomicFileFinished
+	}
+
+	return af.f.Seek(offset, whence)
+}
+
+// Commit flushes the temporary file to disk, renames it over the destination,
+// and flushes the directory so that the rename is durable. If it fails before
+// the rename, the temporary file is removed and the destination is untouched.
+func (af *AtomicFile) Commit() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if af.finished == true {
+		return ErrAtomicFileFinished
+	}
+
+	af.finished = true
+
+	err = af.f.Sync()
+	if err != nil {
+		af.f.Close()
+		os.Remove(af.tempFilepath)
+
+		log.Panic(err)
+	}
+
+	err = af.f.Close()
+	if err != nil {
+		os.Remove(af.tempFilepath)
+		log.Panic(err)
+	}
+
+	err = os.Rename(af.tempFilepath, af.filepath)
+	if err != nil {
+		os.Remove(af.tempFilepath)
+		log.Panic(err)
+	}
+
+	err = syncDirectory(path.Dir(af.filepath))
+	log.PanicIf(err)
+
+	return nil
+}
+
+// Abort discards the temporary file. The destination is untouched.
+func (af *AtomicFile) Abort() (err error) {
+	if af.finished == true {
+		return ErrAtomicFileFinished
+	}
+
+	af.finished = true
+
+	closeErr := af.f.Close()
+
+	err = os.Remove(af.tempFilepath)
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// Close aborts if neither `Commit` nor `Abort` were called. Otherwise, it
+// does nothing. It is intended to be deferred.
+func (af *AtomicFile) Close() (err error) {
+	if af.finished == true {
+		return nil
+	}
+
+	return af.Abort()
+}
+
+// syncDirectory flushes a directory's entries to disk.
+func syncDirectory(dirPath string) (err error) {
+	// Directories can not be opened for syncing on Windows, and renames are
+	// already durable there.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,200 @@
+package rifs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	atomicFileCrashHelperEnvironmentVariable = "RIFS_ATOMIC_FILE_CRASH_HELPER_PATH"
+)
+
+func TestAtomicFile_Commit(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("old content"), 0644)
+	log.PanicIf(err)
+
+	af, err := NewAtomicFile(filepath, 0640)
+	log.PanicIf(err)
+
+	defer af.Close()
+
+	_, err = af.Write([]byte("new content"))
+	log.PanicIf(err)
+
+	// It should be usable as a regular RWS.
+
+	_, err = af.Seek(4, io.SeekStart)
+	log.PanicIf(err)
+
+	buffer := make([]byte, 7)
+
+	_, err = io.ReadFull(af, buffer)
+	log.PanicIf(err)
+
+	if string(buffer) != "content" {
+		t.Fatalf("Read not correct: [%s]", string(buffer))
+	}
+
+	// Nothing should be visible before the commit.
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "old content" {
+		t.Fatalf("Destination changed before commit: [%s]", string(data))
+	}
+
+	err = af.Commit()
+	log.PanicIf(err)
+
+	data, err = ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "new content" {
+		t.Fatalf("Destination not correct after commit: [%s]", string(data))
+	}
+
+	fi, err := os.Stat(filepath)
+	log.PanicIf(err)
+
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("Mode not correct: %v", fi.Mode())
+	}
+
+	if DoesExist(af.TempName()) != false {
+		t.Fatalf("Temporary file should be gone after commit.")
+	}
+
+	// Once committed, it's finished.
+
+	if _, err := af.Write([]byte("x")); err != ErrAtomicFileFinished {
+		t.Fatalf("Expected finished error: %v", err)
+	} else if err := af.Commit(); err != ErrAtomicFileFinished {
+		t.Fatalf("Expected finished error for second commit: %v", err)
+	}
+
+	err = af.Close()
+	log.PanicIf(err)
+}
+
+func TestAtomicFile_Abort(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file1")
+
+	af, err := NewAtomicFile(filepath, 0644)
+	log.PanicIf(err)
+
+	_, err = af.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	err = af.Abort()
+	log.PanicIf(err)
+
+	if DoesExist(filepath) != false {
+		t.Fatalf("Destination should not have been created.")
+	} else if DoesExist(af.TempName()) != false {
+		t.Fatalf("Temporary file should have been removed.")
+	}
+}
+
+func TestAtomicFile_CloseWithoutCommit(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("old content"), 0644)
+	log.PanicIf(err)
+
+	af, err := NewAtomicFile(filepath, 0644)
+	log.PanicIf(err)
+
+	_, err = af.Write([]byte("new content"))
+	log.PanicIf(err)
+
+	err = af.Close()
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "old content" {
+		t.Fatalf("Destination changed: [%s]", string(data))
+	} else if DoesExist(af.TempName()) != false {
+		t.Fatalf("Temporary file should have been removed.")
+	}
+}
+
+// TestAtomicFile_CrashHelper is run in a subprocess by
+// TestAtomicFile_Crash. It dies in the middle of a write.
+func TestAtomicFile_CrashHelper(t *testing.T) {
+	filepath := os.Getenv(atomicFileCrashHelperEnvironmentVariable)
+	if filepath == "" {
+		t.Skip("Only run as a subprocess.")
+	}
+
+	af, err := NewAtomicFile(filepath, 0644)
+	log.PanicIf(err)
+
+	_, err = af.Write([]byte("half-written"))
+	log.PanicIf(err)
+
+	// No deferreds or cleanup will run.
+	os.Exit(3)
+}
+
+func TestAtomicFile_Crash(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("old content"), 0644)
+	log.PanicIf(err)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestAtomicFile_CrashHelper$")
+	cmd.Env = append(os.Environ(), atomicFileCrashHelperEnvironmentVariable+"="+filepath)
+
+	err = cmd.Run()
+
+	if exitErr, ok := err.(*exec.ExitError); ok == false || exitErr.ExitCode() != 3 {
+		t.Fatalf("Helper did not crash as expected: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "old content" {
+		t.Fatalf("Destination damaged by crash: [%s]", string(data))
+	}
+
+	// The only evidence should be the temporary file.
+
+	entries, err := ioutil.ReadDir(tempPath)
+	log.PanicIf(err)
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected the destination and one temporary file: (%d)", len(entries))
+	}
+}
@@ -0,0 +1,312 @@
+package rifs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/data"
+)
+
+const (
+	defaultCachingReaderAtBlockSize  = 64 * 1024
+	defaultCachingReaderAtBlockCount = 64
+)
+
+// CachingReaderAtOptions describes how a `CachingReaderAt` caches.
+type CachingReaderAtOptions struct {
+	// BlockSize is the size of the aligned blocks that are read and cached.
+	// Defaults to 64K.
+	BlockSize int
+
+	// BlockCount is the number of blocks to keep. Defaults to 64.
+	BlockCount int
+
+	// ReadAhead is the number of additional blocks to read, in the same
+	// request, when a block is missed right after the block before it was
+	// read. Zero disables it.
+	ReadAhead int
+}
+
+// CachingReaderAtStats are the cache statistics.
+type CachingReaderAtStats struct {
+	// Hits is the number of blocks found in the cache.
+	Hits int64
+
+	// Misses is the number of blocks that had to be read.
+	Misses int64
+
+	// ReadAheadBlocks is the number of blocks that were read ahead of being
+	// requested.
+	ReadAheadBlocks int64
+
+	// Fetches is the number of reads done against the source. Concurrent
+	// misses on the same block share one fetch.
+	Fetches int64
+}
+
+// String returns a descriptive string.
+func (cras CachingReaderAtStats) String() string {
+	return fmt.Sprintf("CachingReaderAtStats<HITS=(%d) MISSES=(%d) READ-AHEAD=(%d) FETCHES=(%d)>", cras.Hits, cras.Misses, cras.ReadAheadBlocks, cras.Fetches)
+}
+
+// cachedBlock is one block in the LRU. It is short (or empty) at the end of
+// the data.
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// Id returns the block index.
+func (cb *cachedBlock) Id() ridata.LruKey {
+	return cb.index
+}
+
+// blockFetch is a read of the source that is in progress. `doneC` is closed
+// once `blocks` or `err` is set.
+type blockFetch struct {
+	doneC  chan struct{}
+	blocks []*cachedBlock
+	err    error
+}
+
+// CachingReaderAt is a ReaderAt that reads aligned blocks from another
+// ReaderAt and keeps the most recently used ones. This greatly reduces the
+// number of reads against slow sources for many small, scattered reads. It is
+// safe for concurrent use and the source is read without holding the lock,
+// so a miss doesn't hold up reads of other blocks. The source must not change.
+type CachingReaderAt struct {
+	ra      io.ReaderAt
+	options CachingReaderAtOptions
+
+	mutex     sync.Mutex
+	lru       *ridata.Lru
+	stats     CachingReaderAtStats
+	lastBlock int64
+
+	// inFlight has the fetches in progress, by the index of the block that
+	// was missed.
+	inFlight map[int64]*blockFetch
+}
+
+// NewCachingReaderAt returns a new CachingReaderAt instance.
+func NewCachingReaderAt(ra io.ReaderAt, options CachingReaderAtOptions) *CachingReaderAt {
+	if options.BlockSize <= 0 {
+		options.BlockSize = defaultCachingReaderAtBlockSize
+	}
+
+	if options.BlockCount <= 0 {
+		options.BlockCount = defaultCachingReaderAtBlockCount
+	}
+
+	if options.ReadAhead < 0 {
+		options.ReadAhead = 0
+	}
+
+	return &CachingReaderAt{
+		ra:        ra,
+		options:   options,
+		lru:       ridata.NewLru(options.BlockCount),
+		lastBlock: -2,
+		inFlight:  make(map[int64]*blockFetch),
+	}
+}
+
+// Stats returns the cache statistics.
+func (cra *CachingReaderAt) Stats() CachingReaderAtStats {
+	cra.mutex.Lock()
+	defer cra.mutex.Unlock()
+
+	return cra.stats
+}
+
+// fetch reads the given block, and any blocks to read ahead, in one request.
+// The lock must not be held.
+func (cra *CachingReaderAt) fetch(index int64, readAhead int) (blocks []*cachedBlock, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	blockSize := cra.options.BlockSize
+	buffer := make([]byte, blockSize*(1+readAhead))
+
+	n, err := cra.ra.ReadAt(buffer, index*int64(blockSize))
+	if err != nil && err != io.EOF {
+		log.Panic(err)
+	}
+
+	blocks = make([]*cachedBlock, 0, 1+readAhead)
+
+	for i := 0; i <= readAhead; i++ {
+		start := i * blockSize
+		if i > 0 && start >= n {
+			break
+		}
+
+		end := start + blockSize
+		if end > n {
+			end = n
+		}
+
+		data := make([]byte, end-start)
+		copy(data, buffer[start:end])
+
+		block := &cachedBlock{
+			index: index + int64(i),
+			data:  data,
+		}
+
+		blocks = append(blocks, block)
+
+		// Nothing after a short block.
+		if end-start < blockSize {
+			break
+		}
+	}
+
+	return blocks, nil
+}
+
+// block returns the given block from the cache or the source. The lock must be
+// held, but is released while the source is read. If the block is already
+// being read, we wait for that read rather than doing another.
+func (cra *CachingReaderAt) block(index int64) (cb *cachedBlock, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	found, item, err := cra.lru.Get(index)
+	log.PanicIf(err)
+
+	if found == true {
+		cra.stats.Hits++
+		cra.lastBlock = index
+
+		return item.(*cachedBlock), nil
+	}
+
+	cra.stats.Misses++
+
+	if bf, ok := cra.inFlight[index]; ok == true {
+		cra.mutex.Unlock()
+		<-bf.doneC
+		cra.mutex.Lock()
+
+		if bf.err != nil {
+			return nil, bf.err
+		}
+
+		return bf.blocks[0], nil
+	}
+
+	readAhead := 0
+	if index == cra.lastBlock+1 {
+		readAhead = cra.options.ReadAhead
+	}
+
+	cra.lastBlock = index
+
+	bf := &blockFetch{
+		doneC: make(chan struct{}),
+	}
+
+	cra.inFlight[index] = bf
+
+	cra.mutex.Unlock()
+	bf.blocks, bf.err = cra.fetch(index, readAhead)
+	cra.mutex.Lock()
+
+	delete(cra.inFlight, index)
+	close(bf.doneC)
+
+	log.PanicIf(bf.err)
+
+	cra.stats.Fetches++
+	cra.stats.ReadAheadBlocks += int64(len(bf.blocks) - 1)
+
+	for _, block := range bf.blocks {
+		_, _, err := cra.lru.Set(block)
+		log.PanicIf(err)
+	}
+
+	return bf.blocks[0], nil
+}
+
+// ReadAt reads from the cached blocks, reading any that are missing. A short
+// read returns `io.EOF`.
+func (cra *CachingReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	cra.mutex.Lock()
+	defer cra.mutex.Unlock()
+
+	blockSize := int64(cra.options.BlockSize)
+
+	for n < len(p) {
+		current := offset + int64(n)
+		index := current / blockSize
+
+		cb, err := cra.block(index)
+		if err != nil {
+			return n, err
+		}
+
+		within := current - index*blockSize
+		if within >= int64(len(cb.data)) {
+			return n, io.EOF
+		}
+
+		copiedCount := copy(p[n:], cb.data[within:])
+		n += copiedCount
+
+		// A short block is the end.
+		if int64(len(cb.data)) < blockSize && n < len(p) {
+			return n, io.EOF
+		}
+	}
+
+	return n, nil
+}
+
+// CachingReadSeeker is a ReadSeeker (and ReaderAt) with a block cache in front
+// of another ReadSeeker.
+type CachingReadSeeker struct {
+	*io.SectionReader
+
+	cra *CachingReaderAt
+}
+
+// NewCachingReadSeeker returns a new CachingReadSeeker instance. The source is
+// only read through `ReadSeekerToReaderAt`.
+func NewCachingReadSeeker(rs io.ReadSeeker, options CachingReaderAtOptions) (crs *CachingReadSeeker, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	cra := NewCachingReaderAt(NewReadSeekerToReaderAt(rs), options)
+
+	crs = &CachingReadSeeker{
+		SectionReader: io.NewSectionReader(cra, 0, size),
+		cra:           cra,
+	}
+
+	return crs, nil
+}
+
+// Stats returns the cache statistics.
+func (crs *CachingReadSeeker) Stats() CachingReaderAtStats {
+	return crs.cra.Stats()
+}
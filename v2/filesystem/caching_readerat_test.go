@@ -0,0 +1,281 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+// countingReaderAt counts the reads against the source.
+type countingReaderAt struct {
+	ra    io.ReaderAt
+	count int64
+}
+
+func (cra *countingReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	atomic.AddInt64(&cra.count, 1)
+	return cra.ra.ReadAt(p, offset)
+}
+
+// gatedReaderAt blocks reads at one offset until released. `arrivedC` is
+// closed when the first such read arrives.
+type gatedReaderAt struct {
+	countingReaderAt
+
+	gatedOffset int64
+	arrivedC    chan struct{}
+	releaseC    chan struct{}
+	arrivedOnce sync.Once
+}
+
+func (gra *gatedReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset == gra.gatedOffset {
+		gra.arrivedOnce.Do(func() {
+			close(gra.arrivedC)
+		})
+
+		<-gra.releaseC
+	}
+
+	return gra.countingReaderAt.ReadAt(p, offset)
+}
+
+func getCachingReaderAtTestData() []byte {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	return data
+}
+
+func TestCachingReaderAt_ReadAt(t *testing.T) {
+	data := getCachingReaderAtTestData()
+	source := &countingReaderAt{ra: bytes.NewReader(data)}
+
+	options := CachingReaderAtOptions{
+		BlockSize:  100,
+		BlockCount: 4,
+	}
+
+	cra := NewCachingReaderAt(source, options)
+
+	// Many small reads inside the same block.
+
+	for i := 0; i < 10; i++ {
+		fragment := make([]byte, 5)
+
+		_, err := cra.ReadAt(fragment, int64(210+i*5))
+		log.PanicIf(err)
+
+		if bytes.Equal(fragment, data[210+i*5:215+i*5]) != true {
+			t.Fatalf("Data not correct at (%d).", i)
+		}
+	}
+
+	stats := cra.Stats()
+
+	if source.count != 1 {
+		t.Fatalf("Source should have been read once: (%d)", source.count)
+	} else if stats.Hits != 9 || stats.Misses != 1 {
+		t.Fatalf("Stats not correct: %s", stats)
+	}
+
+	// Across two blocks.
+
+	fragment := make([]byte, 20)
+
+	_, err := cra.ReadAt(fragment, 290)
+	log.PanicIf(err)
+
+	if bytes.Equal(fragment, data[290:310]) != true {
+		t.Fatalf("Data across blocks not correct.")
+	}
+
+	stats = cra.Stats()
+
+	if stats.Hits != 10 || stats.Misses != 2 {
+		t.Fatalf("Stats not correct after crossing: %s", stats)
+	}
+}
+
+func TestCachingReaderAt_ReadAt_Eviction(t *testing.T) {
+	data := getCachingReaderAtTestData()
+	source := &countingReaderAt{ra: bytes.NewReader(data)}
+
+	options := CachingReaderAtOptions{
+		BlockSize:  100,
+		BlockCount: 2,
+	}
+
+	cra := NewCachingReaderAt(source, options)
+
+	fragment := make([]byte, 1)
+
+	for _, offset := range []int64{0, 500, 900, 0} {
+		_, err := cra.ReadAt(fragment, offset)
+		log.PanicIf(err)
+	}
+
+	// The first block was dropped before it was needed again.
+	if source.count != 4 {
+		t.Fatalf("Source read count not correct: (%d)", source.count)
+	}
+}
+
+func TestCachingReaderAt_ReadAt_ReadAhead(t *testing.T) {
+	data := getCachingReaderAtTestData()
+	source := &countingReaderAt{ra: bytes.NewReader(data)}
+
+	options := CachingReaderAtOptions{
+		BlockSize:  100,
+		BlockCount: 10,
+		ReadAhead:  3,
+	}
+
+	cra := NewCachingReaderAt(source, options)
+
+	recovered, err := ioutil.ReadAll(io.NewSectionReader(cra, 0, 1000))
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	stats := cra.Stats()
+
+	// The first block is not sequential. The rest are read four at a time.
+	if source.count != 4 {
+		t.Fatalf("Source read count not correct: (%d) %s", source.count, stats)
+	} else if stats.ReadAheadBlocks != 6 {
+		t.Fatalf("Stats not correct: %s", stats)
+	}
+}
+
+func TestCachingReaderAt_ReadAt_SharedFetch(t *testing.T) {
+	data := getCachingReaderAtTestData()
+
+	source := &gatedReaderAt{
+		countingReaderAt: countingReaderAt{ra: bytes.NewReader(data)},
+		gatedOffset:      500,
+		arrivedC:         make(chan struct{}),
+		releaseC:         make(chan struct{}),
+	}
+
+	options := CachingReaderAtOptions{
+		BlockSize:  100,
+		BlockCount: 10,
+	}
+
+	cra := NewCachingReaderAt(source, options)
+
+	// Cache another block first.
+
+	_, err := cra.ReadAt(make([]byte, 10), 100)
+	log.PanicIf(err)
+
+	// Several misses on the same block at once.
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			fragment := make([]byte, 10)
+
+			_, err := cra.ReadAt(fragment, int64(500+i*10))
+			log.PanicIf(err)
+
+			if bytes.Equal(fragment, data[500+i*10:510+i*10]) != true {
+				t.Errorf("Data not correct at (%d).", i)
+			}
+		}(i)
+	}
+
+	// The cached block can still be read while the source is busy.
+
+	<-source.arrivedC
+
+	doneC := make(chan struct{})
+
+	go func() {
+		defer close(doneC)
+
+		_, err := cra.ReadAt(make([]byte, 10), 120)
+		log.PanicIf(err)
+	}()
+
+	select {
+	case <-doneC:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Cached read was blocked by a miss.")
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(source.releaseC)
+
+	wg.Wait()
+
+	if source.count != 2 {
+		t.Fatalf("Source read count not correct: (%d)", source.count)
+	}
+}
+
+func TestCachingReaderAt_Conformance(t *testing.T) {
+	data := getCachingReaderAtTestData()
+
+	options := CachingReaderAtOptions{
+		BlockSize:  64,
+		BlockCount: 4,
+		ReadAhead:  2,
+	}
+
+	cra := NewCachingReaderAt(bytes.NewReader(data), options)
+
+	err := ritesting.CheckReaderAt(cra, data)
+	log.PanicIf(err)
+
+	small := []byte("some test data")
+	cra = NewCachingReaderAt(bytes.NewReader(small), CachingReaderAtOptions{BlockSize: 4})
+
+	err = ritesting.CheckReaderAt(cra, small)
+	log.PanicIf(err)
+}
+
+func TestCachingReadSeeker(t *testing.T) {
+	data := getCachingReaderAtTestData()
+
+	crs, err := NewCachingReadSeeker(bytes.NewReader(data), CachingReaderAtOptions{BlockSize: 100})
+	log.PanicIf(err)
+
+	_, err = crs.Seek(950, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(crs)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data[950:]) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	_, err = crs.Seek(960, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = ioutil.ReadAll(crs)
+	log.PanicIf(err)
+
+	if stats := crs.Stats(); stats.Misses != 1 || stats.Hits == 0 {
+		t.Fatalf("Stats not correct: %s", stats)
+	}
+}
@@ -0,0 +1,205 @@
+package rifs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ReaderSegment is a range of a source that is part of a
+// `ConcatenatedReader`.
+type ReaderSegment struct {
+	// ReaderAt is the source.
+	ReaderAt io.ReaderAt
+
+	// Offset is where the range starts in the source.
+	Offset int64
+
+	// Length is the size of the range. The source must have at least this
+	// many bytes after the offset.
+	Length int64
+}
+
+// String returns a descriptive string.
+func (rs ReaderSegment) String() string {
+	return fmt.Sprintf("ReaderSegment<OFFSET=(%d) LENGTH=(%d)>", rs.Offset, rs.Length)
+}
+
+// NewReaderAtSegment returns a segment for a range of a ReaderAt. For a
+// ReadSeeker, wrap it once with `NewReadSeekerToReaderAt` and use that for
+// every range of it, since the ranges share the seek position.
+func NewReaderAtSegment(ra io.ReaderAt, offset, length int64) ReaderSegment {
+	return ReaderSegment{
+		ReaderAt: ra,
+		Offset:   offset,
+		Length:   length,
+	}
+}
+
+// NewBytesSegment returns a segment for the given bytes.
+func NewBytesSegment(data []byte) ReaderSegment {
+	return ReaderSegment{
+		ReaderAt: bytes.NewReader(data),
+		Length:   int64(len(data)),
+	}
+}
+
+// ConcatenatedReader presents a series of segments of other sources as one
+// seekable stream without copying them. `ReadAt` is safe for concurrent use if
+// the sources are, but `Read` and `Seek` are not. Segments of the same
+// ReadSeeker are only safe if they share one `ReadSeekerToReaderAt`.
+type ConcatenatedReader struct {
+	segments []ReaderSegment
+
+	// starts has the logical offset of each segment.
+	starts []int64
+
+	size     int64
+	position int64
+}
+
+// NewConcatenatedReader returns a new ConcatenatedReader instance. Empty
+// segments are dropped.
+func NewConcatenatedReader(segments ...ReaderSegment) (cr *ConcatenatedReader, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	cr = &ConcatenatedReader{
+		segments: make([]ReaderSegment, 0, len(segments)),
+		starts:   make([]int64, 0, len(segments)),
+	}
+
+	for i, segment := range segments {
+		if segment.Offset < 0 || segment.Length < 0 {
+			log.Panicf("segment (%d) has a negative offset or length: %s", i, segment)
+		} else if segment.Length == 0 {
+			continue
+		}
+
+		cr.segments = append(cr.segments, segment)
+		cr.starts = append(cr.starts, cr.size)
+		cr.size += segment.Length
+	}
+
+	return cr, nil
+}
+
+// Size returns the total size of the segments.
+func (cr *ConcatenatedReader) Size() int64 {
+	return cr.size
+}
+
+// ReadAt reads across as many segments as necessary. A short read returns
+// `io.EOF`.
+func (cr *ConcatenatedReader) ReadAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	} else if offset >= cr.size {
+		return 0, io.EOF
+	}
+
+	// The last segment that starts at or before the offset.
+	i := sort.Search(len(cr.starts), func(i int) bool {
+		return cr.starts[i] > offset
+	}) - 1
+
+	for ; i < len(cr.segments) && n < len(p); i++ {
+		segment := cr.segments[i]
+
+		within := offset + int64(n) - cr.starts[i]
+		remaining := segment.Length - within
+
+		buffer := p[n:]
+		if int64(len(buffer)) > remaining {
+			buffer = buffer[:remaining]
+		}
+
+		readCount, err := segment.ReaderAt.ReadAt(buffer, segment.Offset+within)
+		n += readCount
+
+		if readCount < len(buffer) {
+			if err == nil || err == io.EOF {
+				log.Panicf("segment (%d) is shorter than its length: %s", i, segment)
+			}
+
+			log.Panic(err)
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Read reads from the current position.
+func (cr *ConcatenatedReader) Read(p []byte) (n int, err error) {
+	n, err = cr.ReadAt(p, cr.position)
+	cr.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek seeks within the logical stream.
+func (cr *ConcatenatedReader) Seek(offset int64, whence int) (newOffset int64, err error) {
+	position, err := CalculateSeek(cr.position, offset, whence, cr.size)
+	if err != nil {
+		return cr.position, err
+	}
+
+	cr.position = position
+
+	return cr.position, nil
+}
+
+// WriteTo writes everything from the current position, segment by segment.
+func (cr *ConcatenatedReader) WriteTo(w io.Writer) (n int64, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	for cr.position < cr.size {
+		i := sort.Search(len(cr.starts), func(i int) bool {
+			return cr.starts[i] > cr.position
+		}) - 1
+
+		segment := cr.segments[i]
+		within := cr.position - cr.starts[i]
+		remaining := segment.Length - within
+
+		sr := io.NewSectionReader(segment.ReaderAt, segment.Offset+within, remaining)
+
+		copyCount, err := io.Copy(w, sr)
+		n += copyCount
+		cr.position += copyCount
+
+		log.PanicIf(err)
+
+		if copyCount < remaining {
+			log.Panicf("segment (%d) is shorter than its length: %s", i, segment)
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,144 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+// getConcatenatedReaderTestReader replaces the middle of an original with a
+// new segment, like rewriting an EXIF block.
+func getConcatenatedReaderTestReader() (cr *ConcatenatedReader, expected []byte) {
+	original := []byte("HEADER-oldexif-BODY")
+
+	// Both ranges of the original come from the same ReadSeeker.
+	ra := NewReadSeekerToReaderAt(bytes.NewReader(original))
+
+	segments := []ReaderSegment{
+		NewReaderAtSegment(ra, 0, 7),
+		NewBytesSegment([]byte("NEW-EXIF")),
+		{},
+		NewReaderAtSegment(ra, 14, 5),
+	}
+
+	cr, err := NewConcatenatedReader(segments...)
+	log.PanicIf(err)
+
+	return cr, []byte("HEADER-NEW-EXIF-BODY")
+}
+
+func TestConcatenatedReader_Read(t *testing.T) {
+	cr, expected := getConcatenatedReaderTestReader()
+
+	if cr.Size() != int64(len(expected)) {
+		t.Fatalf("Size not correct: (%d)", cr.Size())
+	}
+
+	// Small reads so that we cross boundaries.
+
+	recovered := make([]byte, 0)
+	buffer := make([]byte, 3)
+
+	for {
+		n, err := cr.Read(buffer)
+		recovered = append(recovered, buffer[:n]...)
+
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+	}
+
+	if bytes.Equal(recovered, expected) != true {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	}
+}
+
+func TestConcatenatedReader_Seek(t *testing.T) {
+	cr, expected := getConcatenatedReaderTestReader()
+
+	position, err := cr.Seek(-4, io.SeekEnd)
+	log.PanicIf(err)
+
+	if position != int64(len(expected))-4 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+
+	recovered, err := ioutil.ReadAll(cr)
+	log.PanicIf(err)
+
+	if string(recovered) != "BODY" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	}
+
+	_, err = cr.Seek(-3, io.SeekCurrent)
+	log.PanicIf(err)
+
+	recovered, err = ioutil.ReadAll(cr)
+	log.PanicIf(err)
+
+	if string(recovered) != "ODY" {
+		t.Fatalf("Data after relative seek not correct: [%s]", string(recovered))
+	}
+}
+
+func TestConcatenatedReader_WriteTo(t *testing.T) {
+	cr, expected := getConcatenatedReaderTestReader()
+
+	_, err := cr.Seek(3, io.SeekStart)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	n, err := cr.WriteTo(b)
+	log.PanicIf(err)
+
+	if n != int64(len(expected))-3 || bytes.Equal(b.Bytes(), expected[3:]) != true {
+		t.Fatalf("WriteTo not correct: (%d) [%s]", n, b.String())
+	}
+
+	n, err = cr.WriteTo(b)
+	log.PanicIf(err)
+
+	if n != 0 {
+		t.Fatalf("Nothing should have been left: (%d)", n)
+	}
+}
+
+func TestConcatenatedReader_ShortSegment(t *testing.T) {
+	segments := []ReaderSegment{
+		{ReaderAt: bytes.NewReader([]byte("abc")), Length: 5},
+	}
+
+	cr, err := NewConcatenatedReader(segments...)
+	log.PanicIf(err)
+
+	_, err = cr.ReadAt(make([]byte, 5), 0)
+	if err == nil || err == io.EOF {
+		t.Fatalf("Expected a short-segment error: %v", err)
+	}
+}
+
+func TestNewConcatenatedReader_NegativeLength(t *testing.T) {
+	segments := []ReaderSegment{
+		{ReaderAt: bytes.NewReader(nil), Length: -1},
+	}
+
+	_, err := NewConcatenatedReader(segments...)
+	if err == nil {
+		t.Fatalf("Expected error for negative length.")
+	}
+}
+
+func TestConcatenatedReader_Conformance(t *testing.T) {
+	cr, expected := getConcatenatedReaderTestReader()
+
+	err := ritesting.CheckReaderAt(cr, expected)
+	log.PanicIf(err)
+}
@@ -0,0 +1,584 @@
+package rifs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// CopyTreeActionType describes what was (or, for a dry-run, would be) done to
+// a destination path.
+type CopyTreeActionType int
+
+const (
+	// CopyTreeCreateDirectory indicates that a directory was created.
+	CopyTreeCreateDirectory CopyTreeActionType = iota
+
+	// CopyTreeCopyFile indicates that a file was copied.
+	CopyTreeCopyFile
+
+	// CopyTreeCreateSymlink indicates that a symlink was created.
+	CopyTreeCreateSymlink
+
+	// CopyTreeSkipUnchanged indicates that a file was already up to date.
+	CopyTreeSkipUnchanged
+
+	// CopyTreeDelete indicates that an extraneous path was removed (mirror
+	// mode only).
+	CopyTreeDelete
+)
+
+// String returns a descriptive string.
+func (ctat CopyTreeActionType) String() string {
+	if ctat == CopyTreeCreateDirectory {
+		return "MKDIR"
+	} else if ctat == CopyTreeCopyFile {
+		return "COPY"
+	} else if ctat == CopyTreeCreateSymlink {
+		return "SYMLINK"
+	} else if ctat == CopyTreeSkipUnchanged {
+		return "SKIP"
+	} else if ctat == CopyTreeDelete {
+		return "DELETE"
+	}
+
+	log.Panicf("unknown copy-tree action type: (%d)", ctat)
+	return ""
+}
+
+// CopyTreeAction is one change to the destination.
+type CopyTreeAction struct {
+	Type CopyTreeActionType
+
+	// Filepath is relative to the source and destination roots.
+	Filepath string
+
+	// Size is the number of bytes copied. Only set for file copies.
+	Size int64
+}
+
+// String returns a descriptive string.
+func (cta CopyTreeAction) String() string {
+	return fmt.Sprintf("CopyTreeAction<TYPE=[%s] PATH=[%s] SIZE=(%d)>", cta.Type, cta.Filepath, cta.Size)
+}
+
+// CopyTreeReport describes what `CopyTree` did.
+type CopyTreeReport struct {
+	// Actions are in the order that they were done.
+	Actions []CopyTreeAction
+
+	// BytesCopied is the total size of all copied files.
+	BytesCopied int64
+}
+
+// Count returns the number of actions of the given type.
+func (ctr *CopyTreeReport) Count(actionType CopyTreeActionType) int {
+	count := 0
+	for _, cta := range ctr.Actions {
+		if cta.Type == actionType {
+			count++
+		}
+	}
+
+	return count
+}
+
+// String returns a descriptive string.
+func (ctr *CopyTreeReport) String() string {
+	return fmt.Sprintf("CopyTreeReport<ACTIONS=(%d) BYTES-COPIED=(%d)>", len(ctr.Actions), ctr.BytesCopied)
+}
+
+// CopyTreeOptions describes how to copy a tree.
+type CopyTreeOptions struct {
+	// Filter is an optional predicate that can exclude source paths. In
+	// mirror mode, destination paths that it excludes are also left alone.
+	Filter FileListFilterPredicate
+
+	// ErrorPolicy decides what happens when a path can not be processed. If
+	// not provided, the copy fails on the first error.
+	ErrorPolicy PathErrorPolicy
+
+	// IncludeSymlinks recreates symlinks in the destination rather than
+	// skipping them. They are never followed.
+	IncludeSymlinks bool
+
+	// PreserveXattrs copies extended attributes, where supported.
+	PreserveXattrs bool
+
+	// CompareHashes compares content to decide whether an existing file is
+	// unchanged. Otherwise, files with the same size and modification-time
+	// are considered unchanged.
+	CompareHashes bool
+
+	// DryRun reports what would be done without changing anything.
+	DryRun bool
+
+	// Mirror removes anything in the destination that is not in the source.
+	Mirror bool
+
+	// Progress, if provided, is called after each write with the number of
+	// bytes written, and once more with `isEof` set when the copy is done.
+	Progress ProgressFunc
+
+	// Buffer is the buffer to copy with. One is allocated if not provided.
+	Buffer []byte
+}
+
+// treeCopier holds the state for one invocation of `CopyTree`.
+type treeCopier struct {
+	sourcePath      string
+	destinationPath string
+	options         CopyTreeOptions
+
+	fw     *fileWalker
+	report *CopyTreeReport
+}
+
+// CopyTree copies the tree at the source path into the destination path,
+// which is created if necessary. Modes and modification-times are preserved.
+// Only the OS filesystem is supported.
+func CopyTree(sourcePath, destinationPath string, options CopyTreeOptions) (report *CopyTreeReport, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	startAt := time.Now()
+
+	if options.Buffer == nil {
+		options.Buffer = make([]byte, defaultCopyBufferSize)
+	}
+
+	tc := &treeCopier{
+		sourcePath:      path.Clean(sourcePath),
+		destinationPath: path.Clean(destinationPath),
+		options:         options,
+		fw: &fileWalker{
+			options: ListFilesOptions{
+				ErrorPolicy: options.ErrorPolicy,
+			},
+		},
+		report: &CopyTreeReport{
+			Actions: make([]CopyTreeAction, 0),
+		},
+	}
+
+	rootInfo, err := os.Stat(tc.sourcePath)
+	log.PanicIf(err)
+
+	if rootInfo.IsDir() == false {
+		log.Panicf("source is not a directory: [%s]", tc.sourcePath)
+	}
+
+	visited, err := tc.list(tc.sourcePath, options.IncludeSymlinks)
+	log.PanicIf(err)
+
+	err = tc.ensureDirectory(".", rootInfo)
+	log.PanicIf(err)
+
+	copied := make(map[string]bool)
+	directories := make([]VisitedFile, 0)
+
+	for _, vf := range visited {
+		relPath := relativeWalkPath(tc.sourcePath, vf.Filepath)
+		copied[relPath] = true
+
+		ok, err := tc.fw.attempt(vf.Filepath, "copy", func() error {
+			if vf.Info.IsDir() == true {
+				return tc.ensureDirectory(relPath, vf.Info)
+			} else if (vf.Info.Mode() & os.ModeSymlink) > 0 {
+				return tc.ensureSymlink(relPath)
+			} else if vf.Info.Mode().IsRegular() == true {
+				return tc.ensureFile(relPath, vf.Info)
+			}
+
+			// Devices, sockets, and pipes are not copied.
+			return nil
+		})
+
+		log.PanicIf(err)
+
+		if ok == true && vf.Info.IsDir() == true {
+			directories = append(directories, vf)
+		}
+	}
+
+	if options.Mirror == true {
+		err := tc.removeExtraneous(copied)
+		log.PanicIf(err)
+	}
+
+	// Copying into a directory changes its time, so directories are finished
+	// last and deepest first.
+
+	if options.DryRun == false {
+		for i := len(directories) - 1; i >= 0; i-- {
+			vf := directories[i]
+			relPath := relativeWalkPath(tc.sourcePath, vf.Filepath)
+
+			_, err := tc.fw.attempt(vf.Filepath, "metadata", func() error {
+				return tc.applyMetadata(vf.Filepath, path.Join(tc.destinationPath, relPath), vf.Info)
+			})
+
+			log.PanicIf(err)
+		}
+
+		err = tc.applyMetadata(tc.sourcePath, tc.destinationPath, rootInfo)
+		log.PanicIf(err)
+
+		if options.Progress != nil {
+			err := options.Progress(0, time.Since(startAt), true)
+			log.PanicIf(err)
+		}
+	}
+
+	return tc.report, nil
+}
+
+// list returns everything under the given path.
+func (tc *treeCopier) list(rootPath string, includeSymlinks bool) (visited []VisitedFile, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	listOptions := ListFilesOptions{
+		Filter:          tc.options.Filter,
+		ErrorPolicy:     tc.options.ErrorPolicy,
+		IncludeSymlinks: includeSymlinks,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(rootPath, listOptions)
+	log.PanicIf(err)
+
+	visited = make([]VisitedFile, 0)
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				return visited, nil
+			}
+
+			if event.File != nil {
+				visited = append(visited, *event.File)
+			}
+		}
+	}
+}
+
+func (tc *treeCopier) addAction(actionType CopyTreeActionType, relPath string, size int64) {
+	cta := CopyTreeAction{
+		Type:     actionType,
+		Filepath: relPath,
+		Size:     size,
+	}
+
+	tc.report.Actions = append(tc.report.Actions, cta)
+	tc.report.BytesCopied += size
+}
+
+// clear removes whatever is at the destination path so that something of a
+// different type can take its place.
+func (tc *treeCopier) clear(toFilepath string) error {
+	if tc.options.DryRun == true {
+		return nil
+	}
+
+	// Read-only directories (e.g. from a previous copy) can't be emptied.
+	// Directories are visited before their contents.
+	err := filepath.Walk(toFilepath, func(walkFilepath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if fi.IsDir() == true {
+			return makeOwnerWritable(walkFilepath, fi, 0700)
+		}
+
+		return nil
+	})
+
+	if err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+
+	return os.RemoveAll(toFilepath)
+}
+
+// makeOwnerWritable adds the given owner bits to the mode of the path if it
+// doesn't already have them.
+func makeOwnerWritable(toFilepath string, fi os.FileInfo, ownerBits os.FileMode) error {
+	if fi.Mode().Perm()&ownerBits == ownerBits {
+		return nil
+	}
+
+	return os.Chmod(toFilepath, fi.Mode().Perm()|ownerBits)
+}
+
+func (tc *treeCopier) ensureDirectory(relPath string, fi os.FileInfo) (err error) {
+	toFilepath := path.Join(tc.destinationPath, relPath)
+
+	existingInfo, err := os.Lstat(toFilepath)
+	if err == nil && existingInfo.IsDir() == true {
+		if tc.options.DryRun == true {
+			return nil
+		}
+
+		// A previous copy might have left it read-only. The real mode is
+		// applied when we're done.
+		return makeOwnerWritable(toFilepath, existingInfo, 0700)
+	} else if err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+
+	if err == nil {
+		err := tc.clear(toFilepath)
+		if err != nil {
+			return err
+		}
+	}
+
+	tc.addAction(CopyTreeCreateDirectory, relPath, 0)
+
+	if tc.options.DryRun == true {
+		return nil
+	}
+
+	// Make sure that we can write into it. The real mode is applied when
+	// we're done.
+	return os.MkdirAll(toFilepath, fi.Mode().Perm()|0700)
+}
+
+func (tc *treeCopier) ensureSymlink(relPath string) (err error) {
+	fromFilepath := path.Join(tc.sourcePath, relPath)
+	toFilepath := path.Join(tc.destinationPath, relPath)
+
+	target, err := os.Readlink(fromFilepath)
+	if err != nil {
+		return err
+	}
+
+	existingInfo, err := os.Lstat(toFilepath)
+	if err == nil {
+		if (existingInfo.Mode() & os.ModeSymlink) > 0 {
+			existingTarget, err := os.Readlink(toFilepath)
+			if err == nil && existingTarget == target {
+				tc.addAction(CopyTreeSkipUnchanged, relPath, 0)
+				return nil
+			}
+		}
+
+		err := tc.clear(toFilepath)
+		if err != nil {
+			return err
+		}
+	} else if os.IsNotExist(err) == false {
+		return err
+	}
+
+	tc.addAction(CopyTreeCreateSymlink, relPath, 0)
+
+	if tc.options.DryRun == true {
+		return nil
+	}
+
+	return os.Symlink(target, toFilepath)
+}
+
+// isUnchanged returns true if the destination file does not need to be
+// copied.
+func (tc *treeCopier) isUnchanged(fromFilepath, toFilepath string, fromInfo, toInfo os.FileInfo) (unchanged bool, err error) {
+	if toInfo.Mode().IsRegular() == false || toInfo.Size() != fromInfo.Size() {
+		return false, nil
+	}
+
+	if tc.options.CompareHashes == false {
+		return toInfo.ModTime().Equal(fromInfo.ModTime()), nil
+	}
+
+	fsys := defaultFilesystem
+
+	fromHash, err := hashFile(fsys, fromFilepath, sha256.New())
+	if err != nil {
+		return false, err
+	}
+
+	toHash, err := hashFile(fsys, toFilepath, sha256.New())
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(fromHash, toHash), nil
+}
+
+func (tc *treeCopier) ensureFile(relPath string, fi os.FileInfo) (err error) {
+	fromFilepath := path.Join(tc.sourcePath, relPath)
+	toFilepath := path.Join(tc.destinationPath, relPath)
+
+	existingInfo, err := os.Lstat(toFilepath)
+	if err == nil {
+		unchanged, err := tc.isUnchanged(fromFilepath, toFilepath, fi, existingInfo)
+		if err != nil {
+			return err
+		}
+
+		if unchanged == true {
+			tc.addAction(CopyTreeSkipUnchanged, relPath, 0)
+
+			if tc.options.DryRun == true {
+				return nil
+			}
+
+			// The content is the same but the metadata might not be.
+			return tc.applyMetadata(fromFilepath, toFilepath, fi)
+		}
+
+		if existingInfo.Mode().IsRegular() == false {
+			err := tc.clear(toFilepath)
+			if err != nil {
+				return err
+			}
+		} else if tc.options.DryRun == false {
+			// A previous copy might have left it read-only. The real mode is
+			// applied after copying.
+			err := makeOwnerWritable(toFilepath, existingInfo, 0200)
+			if err != nil {
+				return err
+			}
+		}
+	} else if os.IsNotExist(err) == false {
+		return err
+	}
+
+	if tc.options.DryRun == true {
+		tc.addAction(CopyTreeCopyFile, relPath, fi.Size())
+		return nil
+	}
+
+	size, err := tc.copyFile(fromFilepath, toFilepath)
+	if err != nil {
+		return err
+	}
+
+	tc.addAction(CopyTreeCopyFile, relPath, size)
+
+	return tc.applyMetadata(fromFilepath, toFilepath, fi)
+}
+
+func (tc *treeCopier) copyFile(fromFilepath, toFilepath string) (size int64, err error) {
+	r, err := os.Open(fromFilepath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	f, err := os.OpenFile(toFilepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	var w io.Writer = f
+	if tc.options.Progress != nil {
+		w = NewWriteProgressWrapper(f, tc.options.Progress)
+	}
+
+	n, err := GracefulCopy(w, r, tc.options.Buffer)
+	if err != nil {
+		f.Close()
+		return int64(n), err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return int64(n), err
+	}
+
+	return int64(n), nil
+}
+
+// applyMetadata copies the mode, times, and, if requested, the extended
+// attributes from one path to another.
+func (tc *treeCopier) applyMetadata(fromFilepath, toFilepath string, fi os.FileInfo) (err error) {
+	if tc.options.PreserveXattrs == true {
+		// Setting attributes requires write access.
+		toInfo, err := os.Lstat(toFilepath)
+		if err != nil {
+			return err
+		}
+
+		err = makeOwnerWritable(toFilepath, toInfo, 0200)
+		if err != nil {
+			return err
+		}
+
+		err = copyXattrs(fromFilepath, toFilepath)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chmod(toFilepath, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(toFilepath, fi.ModTime(), fi.ModTime())
+}
+
+// removeExtraneous removes everything in the destination that was not in the
+// source.
+func (tc *treeCopier) removeExtraneous(copied map[string]bool) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	// A dry-run might not have created it.
+	if _, err := os.Lstat(tc.destinationPath); os.IsNotExist(err) == true {
+		return nil
+	}
+
+	visited, err := tc.list(tc.destinationPath, true)
+	log.PanicIf(err)
+
+	removed := make(map[string]bool)
+
+	for _, vf := range visited {
+		relPath := relativeWalkPath(tc.destinationPath, vf.Filepath)
+
+		// Parents are always visited before their children, which go with
+		// them.
+		if removed[path.Dir(relPath)] == true {
+			removed[relPath] = true
+			continue
+		} else if copied[relPath] == true {
+			continue
+		}
+
+		ok, err := tc.fw.attempt(vf.Filepath, "remove", func() error {
+			return tc.clear(vf.Filepath)
+		})
+
+		log.PanicIf(err)
+
+		if ok == true {
+			tc.addAction(CopyTreeDelete, relPath, 0)
+			removed[relPath] = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package rifs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// newCopyTreeTestSource creates a small tree and returns its path.
+func newCopyTreeTestSource() string {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	err = os.MkdirAll(path.Join(tempPath, "aa", "bb"), 0750)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "file1"), []byte("abc"), 0600)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "aa", "bb", "file2"), []byte("defgh"), 0644)
+	log.PanicIf(err)
+
+	err = os.Symlink("file1", path.Join(tempPath, "link1"))
+	log.PanicIf(err)
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err = os.Chtimes(path.Join(tempPath, "file1"), mtime, mtime)
+	log.PanicIf(err)
+
+	err = os.Chtimes(path.Join(tempPath, "aa"), mtime, mtime)
+	log.PanicIf(err)
+
+	return tempPath
+}
+
+// removeCopyTreeTestPath makes every directory in the tree accessible and then
+// removes the tree.
+func removeCopyTreeTestPath(rootPath string) {
+	err := filepath.Walk(rootPath, func(childPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() == true {
+			return os.Chmod(childPath, fi.Mode().Perm()|0700)
+		}
+
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	err = os.RemoveAll(rootPath)
+	log.PanicIf(err)
+}
+
+func TestCopyTree(t *testing.T) {
+	sourcePath := newCopyTreeTestSource()
+	defer os.RemoveAll(sourcePath)
+
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	destinationPath := path.Join(tempPath, "destination")
+
+	progressBytes := 0
+	progressEof := false
+
+	options := CopyTreeOptions{
+		IncludeSymlinks: true,
+		Progress: func(n int, duration time.Duration, isEof bool) error {
+			progressBytes += n
+
+			if isEof == true {
+				progressEof = true
+			}
+
+			return nil
+		},
+	}
+
+	report, err := CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeCreateDirectory) != 3 {
+		t.Fatalf("Directory count not correct: %v", report.Actions)
+	} else if report.Count(CopyTreeCopyFile) != 2 {
+		t.Fatalf("File count not correct: %v", report.Actions)
+	} else if report.Count(CopyTreeCreateSymlink) != 1 {
+		t.Fatalf("Symlink count not correct: %v", report.Actions)
+	} else if report.BytesCopied != 8 {
+		t.Fatalf("Byte count not correct: (%d)", report.BytesCopied)
+	} else if progressBytes != 8 || progressEof != true {
+		t.Fatalf("Progress not correct: (%d) %v", progressBytes, progressEof)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(destinationPath, "aa", "bb", "file2"))
+	log.PanicIf(err)
+
+	if string(data) != "defgh" {
+		t.Fatalf("Content not correct: [%s]", string(data))
+	}
+
+	fi, err := os.Stat(path.Join(destinationPath, "file1"))
+	log.PanicIf(err)
+
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("File mode not preserved: %v", fi.Mode())
+	} else if fi.ModTime().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) != true {
+		t.Fatalf("File time not preserved: %v", fi.ModTime())
+	}
+
+	fi, err = os.Stat(path.Join(destinationPath, "aa"))
+	log.PanicIf(err)
+
+	if fi.Mode().Perm() != 0750 {
+		t.Fatalf("Directory mode not preserved: %v", fi.Mode())
+	} else if fi.ModTime().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) != true {
+		t.Fatalf("Directory time not preserved: %v", fi.ModTime())
+	}
+
+	target, err := os.Readlink(path.Join(destinationPath, "link1"))
+	log.PanicIf(err)
+
+	if target != "file1" {
+		t.Fatalf("Symlink target not correct: [%s]", target)
+	}
+
+	// A second pass should have nothing to do.
+
+	report, err = CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeSkipUnchanged) != 3 || report.BytesCopied != 0 || len(report.Actions) != 3 {
+		t.Fatalf("Second pass should only skip: %v", report.Actions)
+	}
+}
+
+func TestCopyTree_ChangedFile(t *testing.T) {
+	sourcePath := newCopyTreeTestSource()
+	defer os.RemoveAll(sourcePath)
+
+	destinationPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destinationPath)
+
+	_, err = CopyTree(sourcePath, destinationPath, CopyTreeOptions{})
+	log.PanicIf(err)
+
+	// Same size and time but different content. Only a hash comparison will
+	// catch it.
+
+	filepath := path.Join(sourcePath, "file1")
+
+	fi, err := os.Stat(filepath)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(filepath, []byte("xyz"), 0600)
+	log.PanicIf(err)
+
+	err = os.Chtimes(filepath, fi.ModTime(), fi.ModTime())
+	log.PanicIf(err)
+
+	report, err := CopyTree(sourcePath, destinationPath, CopyTreeOptions{})
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeCopyFile) != 0 {
+		t.Fatalf("Size and time comparison should not have copied: %v", report.Actions)
+	}
+
+	options := CopyTreeOptions{
+		CompareHashes: true,
+	}
+
+	report, err = CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeCopyFile) != 1 || report.BytesCopied != 3 {
+		t.Fatalf("Hash comparison should have copied: %v", report.Actions)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(destinationPath, "file1"))
+	log.PanicIf(err)
+
+	if string(data) != "xyz" {
+		t.Fatalf("Content not updated: [%s]", string(data))
+	}
+}
+
+func TestCopyTree_MirrorAndDryRun(t *testing.T) {
+	sourcePath := newCopyTreeTestSource()
+	defer os.RemoveAll(sourcePath)
+
+	destinationPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destinationPath)
+
+	_, err = CopyTree(sourcePath, destinationPath, CopyTreeOptions{})
+	log.PanicIf(err)
+
+	err = os.MkdirAll(path.Join(destinationPath, "extra", "nested"), 0755)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(destinationPath, "extra", "nested", "file3"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(destinationPath, "aa", "file4"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = os.Remove(path.Join(destinationPath, "file1"))
+	log.PanicIf(err)
+
+	options := CopyTreeOptions{
+		Mirror: true,
+		DryRun: true,
+	}
+
+	report, err := CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeDelete) != 2 {
+		t.Fatalf("Delete count not correct: %v", report.Actions)
+	} else if report.Count(CopyTreeCopyFile) != 1 || report.BytesCopied != 3 {
+		t.Fatalf("Copy count not correct: %v", report.Actions)
+	}
+
+	// Nothing should have changed.
+
+	if DoesExist(path.Join(destinationPath, "extra")) != true || DoesExist(path.Join(destinationPath, "file1")) != false {
+		t.Fatalf("Dry-run changed the destination.")
+	}
+
+	options.DryRun = false
+
+	report, err = CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeDelete) != 2 {
+		t.Fatalf("Delete count not correct: %v", report.Actions)
+	}
+
+	if DoesExist(path.Join(destinationPath, "extra")) != false || DoesExist(path.Join(destinationPath, "aa", "file4")) != false {
+		t.Fatalf("Extraneous paths not removed.")
+	} else if DoesExist(path.Join(destinationPath, "file1")) != true {
+		t.Fatalf("Missing file not copied.")
+	}
+}
+
+func TestCopyTree_ReadOnly_Resync(t *testing.T) {
+	sourcePath := newCopyTreeTestSource()
+
+	// The modes would otherwise prevent cleanup.
+	defer removeCopyTreeTestPath(sourcePath)
+
+	destinationPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer removeCopyTreeTestPath(destinationPath)
+
+	file1Filepath := path.Join(sourcePath, "file1")
+	roPath := path.Join(sourcePath, "ro")
+
+	err = os.Mkdir(roPath, 0755)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(roPath, "file3"), []byte("ijk"), 0444)
+	log.PanicIf(err)
+
+	err = os.Chmod(roPath, 0555)
+	log.PanicIf(err)
+
+	err = os.Chmod(file1Filepath, 0400)
+	log.PanicIf(err)
+
+	_, err = CopyTree(sourcePath, destinationPath, CopyTreeOptions{})
+	log.PanicIf(err)
+
+	// Change a read-only file and add and remove files in a read-only
+	// directory.
+
+	err = os.Chmod(file1Filepath, 0600)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(file1Filepath, []byte("xyzw"), 0600)
+	log.PanicIf(err)
+
+	err = os.Chmod(file1Filepath, 0400)
+	log.PanicIf(err)
+
+	err = os.Chmod(roPath, 0755)
+	log.PanicIf(err)
+
+	err = os.Remove(path.Join(roPath, "file3"))
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(roPath, "file4"), []byte("lmno"), 0444)
+	log.PanicIf(err)
+
+	err = os.Chmod(roPath, 0555)
+	log.PanicIf(err)
+
+	options := CopyTreeOptions{
+		Mirror: true,
+	}
+
+	report, err := CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	if report.Count(CopyTreeCopyFile) != 2 || report.Count(CopyTreeDelete) != 1 {
+		t.Fatalf("Actions not correct: %v", report.Actions)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(destinationPath, "file1"))
+	log.PanicIf(err)
+
+	if string(data) != "xyzw" {
+		t.Fatalf("Content not updated: [%s]", string(data))
+	}
+
+	data, err = ioutil.ReadFile(path.Join(destinationPath, "ro", "file4"))
+	log.PanicIf(err)
+
+	if string(data) != "lmno" {
+		t.Fatalf("New file not correct: [%s]", string(data))
+	}
+
+	_, err = os.Lstat(path.Join(destinationPath, "ro", "file3"))
+	if os.IsNotExist(err) == false {
+		t.Fatalf("Removed file still exists: [%v]", err)
+	}
+
+	// The final modes are restored.
+
+	expectedModes := map[string]os.FileMode{
+		"file1":    0400,
+		"ro":       0555,
+		"ro/file4": 0444,
+	}
+
+	for relPath, expectedMode := range expectedModes {
+		fi, err := os.Lstat(path.Join(destinationPath, relPath))
+		log.PanicIf(err)
+
+		if fi.Mode().Perm() != expectedMode {
+			t.Fatalf("Mode not correct for [%s]: [%s]", relPath, fi.Mode())
+		}
+	}
+}
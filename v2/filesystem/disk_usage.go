@@ -0,0 +1,316 @@
+package rifs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/data"
+)
+
+const (
+	defaultDiskUsageLargestFilesCount = 10
+
+	// diskUsageBlockSize is the unit of `fileStatInfo.Blocks`.
+	diskUsageBlockSize = 512
+)
+
+// DiskUsageSizeMode determines how the size of a file is measured.
+type DiskUsageSizeMode int
+
+const (
+	// DiskUsageApparentSize uses the length of the content.
+	DiskUsageApparentSize DiskUsageSizeMode = iota
+
+	// DiskUsageAllocatedSize uses the space allocated on disk. This is not
+	// available on all platforms and filesystems, in which case the apparent
+	// size is used.
+	DiskUsageAllocatedSize
+)
+
+// DiskUsageOptions describes how to calculate disk usage.
+type DiskUsageOptions struct {
+	// Filter is an optional predicate that can exclude paths.
+	Filter FileListFilterPredicate
+
+	// ErrorPolicy decides what happens when a path can not be processed. If
+	// not provided, the calculation fails on the first error.
+	ErrorPolicy PathErrorPolicy
+
+	// Filesystem is the filesystem to scan. If not provided, the OS is used.
+	Filesystem Filesystem
+
+	// SizeMode determines how sizes are measured.
+	SizeMode DiskUsageSizeMode
+
+	// DeduplicateHardLinks counts files with more than one hard-link only
+	// the first time that they are encountered.
+	DeduplicateHardLinks bool
+
+	// LargestFilesCount is the number of largest files to track for each
+	// directory. Defaults to ten.
+	LargestFilesCount int
+
+	// DetectMimetypes populates the mime-type breakdowns. This requires
+	// reading the head of every file.
+	DetectMimetypes bool
+}
+
+// DiskUsageFile is a file and its measured size.
+type DiskUsageFile struct {
+	Filepath string
+	Size     int64
+}
+
+// DiskUsageTotals is a byte- and file-count.
+type DiskUsageTotals struct {
+	Bytes int64
+	Files int
+}
+
+// DiskUsageNode has the totals for one directory, including everything under
+// it. Only regular files are counted.
+type DiskUsageNode struct {
+	// Filepath is the path of the directory as it was walked.
+	Filepath string
+
+	DiskUsageTotals
+
+	// Directories is the number of descendant directories.
+	Directories int
+
+	// LargestFiles is sorted by descending size.
+	LargestFiles []DiskUsageFile
+
+	// ByMimetype is keyed by mime-type. Only populated if requested. Empty
+	// files have an empty mime-type.
+	ByMimetype map[string]*DiskUsageTotals
+
+	// ByExtension is keyed by the lowercase extension, including the period.
+	// Files without an extension have an empty key.
+	ByExtension map[string]*DiskUsageTotals
+
+	// Children is keyed by name.
+	Children map[string]*DiskUsageNode
+}
+
+func newDiskUsageNode(filepath string) *DiskUsageNode {
+	return &DiskUsageNode{
+		Filepath:     filepath,
+		LargestFiles: make([]DiskUsageFile, 0),
+		ByMimetype:   make(map[string]*DiskUsageTotals),
+		ByExtension:  make(map[string]*DiskUsageTotals),
+		Children:     make(map[string]*DiskUsageNode),
+	}
+}
+
+// String returns a descriptive string.
+func (dun *DiskUsageNode) String() string {
+	return fmt.Sprintf("DiskUsageNode<PATH=[%s] BYTES=(%d) FILES=(%d) DIRECTORIES=(%d)>", dun.Filepath, dun.Bytes, dun.Files, dun.Directories)
+}
+
+// SortedChildren returns the immediate subdirectories by descending size.
+func (dun *DiskUsageNode) SortedChildren() []*DiskUsageNode {
+	children := make([]*DiskUsageNode, 0, len(dun.Children))
+	for _, child := range dun.Children {
+		children = append(children, child)
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Bytes != children[j].Bytes {
+			return children[i].Bytes > children[j].Bytes
+		}
+
+		return children[i].Filepath < children[j].Filepath
+	})
+
+	return children
+}
+
+// Find returns the node for the given path relative to this one, or nil.
+func (dun *DiskUsageNode) Find(relPath string) *DiskUsageNode {
+	current := dun
+
+	for _, name := range strings.Split(path.Clean(relPath), "/") {
+		if name == "." {
+			continue
+		}
+
+		current = current.Children[name]
+		if current == nil {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// addFile counts a file against this directory.
+func (dun *DiskUsageNode) addFile(duf DiskUsageFile, mimetype string, options DiskUsageOptions) {
+	dun.Bytes += duf.Size
+	dun.Files++
+
+	extension := strings.ToLower(path.Ext(duf.Filepath))
+	addDiskUsageTotals(dun.ByExtension, extension, duf.Size)
+
+	if options.DetectMimetypes == true {
+		addDiskUsageTotals(dun.ByMimetype, mimetype, duf.Size)
+	}
+
+	// Insert in order and drop the smallest if we have too many.
+
+	i := sort.Search(len(dun.LargestFiles), func(i int) bool {
+		return dun.LargestFiles[i].Size < duf.Size
+	})
+
+	largestFilesCount := options.LargestFilesCount
+
+	if i >= largestFilesCount {
+		return
+	}
+
+	dun.LargestFiles = append(dun.LargestFiles, DiskUsageFile{})
+	copy(dun.LargestFiles[i+1:], dun.LargestFiles[i:])
+	dun.LargestFiles[i] = duf
+
+	if len(dun.LargestFiles) > largestFilesCount {
+		dun.LargestFiles = dun.LargestFiles[:largestFilesCount]
+	}
+}
+
+func addDiskUsageTotals(totals map[string]*DiskUsageTotals, key string, size int64) {
+	dut, found := totals[key]
+	if found == false {
+		dut = new(DiskUsageTotals)
+		totals[key] = dut
+	}
+
+	dut.Bytes += size
+	dut.Files++
+}
+
+// CalculateDiskUsage walks the given path and returns a tree of per-directory
+// totals similar to what `du` reports. Symlinks are not followed.
+func CalculateDiskUsage(rootPath string, options DiskUsageOptions) (root *DiskUsageNode, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if options.LargestFilesCount <= 0 {
+		options.LargestFilesCount = defaultDiskUsageLargestFilesCount
+	}
+
+	rootPath = path.Clean(rootPath)
+	fsys := filesystemOrDefault(options.Filesystem)
+
+	listOptions := ListFilesOptions{
+		Filter:      options.Filter,
+		ErrorPolicy: options.ErrorPolicy,
+		Filesystem:  fsys,
+	}
+
+	fw := &fileWalker{
+		options: listOptions,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(rootPath, listOptions)
+	log.PanicIf(err)
+
+	root = newDiskUsageNode(rootPath)
+	seenInodes := make(map[[2]uint64]bool)
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				return root, nil
+			}
+
+			if event.File == nil {
+				continue
+			}
+
+			vf := event.File
+			relPath := relativeWalkPath(rootPath, vf.Filepath)
+
+			// The directories leading to this path.
+			ancestors := []*DiskUsageNode{root}
+
+			parts := strings.Split(relPath, "/")
+			for _, name := range parts[:len(parts)-1] {
+				current := ancestors[len(ancestors)-1]
+
+				child, found := current.Children[name]
+				if found == false {
+					child = newDiskUsageNode(path.Join(current.Filepath, name))
+					current.Children[name] = child
+				}
+
+				ancestors = append(ancestors, child)
+			}
+
+			if vf.Info.IsDir() == true {
+				parent := ancestors[len(ancestors)-1]
+				if _, found := parent.Children[vf.Info.Name()]; found == false {
+					parent.Children[vf.Info.Name()] = newDiskUsageNode(vf.Filepath)
+				}
+
+				for _, dun := range ancestors {
+					dun.Directories++
+				}
+
+				continue
+			} else if vf.Info.Mode().IsRegular() == false {
+				continue
+			}
+
+			size := vf.Info.Size()
+
+			if fsi, ok := getFileStatInfo(vf.Info); ok == true {
+				if options.DeduplicateHardLinks == true && fsi.Links > 1 {
+					key := [2]uint64{fsi.Device, fsi.Inode}
+					if seenInodes[key] == true {
+						continue
+					}
+
+					seenInodes[key] = true
+				}
+
+				if options.SizeMode == DiskUsageAllocatedSize {
+					size = fsi.Blocks * diskUsageBlockSize
+				}
+			}
+
+			mimetype := ""
+			if options.DetectMimetypes == true {
+				ok, err := fw.attempt(vf.Filepath, "mimetype", func() (err error) {
+					mimetype, err = ridata.DetectMimetypeWithFilesystem(fsys, vf.Filepath)
+					return err
+				})
+
+				log.PanicIf(err)
+
+				if ok == false {
+					continue
+				}
+			}
+
+			duf := DiskUsageFile{
+				Filepath: vf.Filepath,
+				Size:     size,
+			}
+
+			for _, dun := range ancestors {
+				dun.addFile(duf, mimetype, options)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package rifs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCalculateDiskUsage(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("root/aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.MkdirAll("root/cc", 0755)
+	log.PanicIf(err)
+
+	files := map[string]string{
+		"root/file1.txt":         "12345",
+		"root/aa/image.PNG":      "\x89PNG\x0D\x0A\x1A\x0A",
+		"root/aa/bb/file2.txt":   "123",
+		"root/aa/bb/noextension": "1234567890",
+		"root/cc/empty.txt":      "",
+	}
+
+	for filepath, content := range files {
+		err := mfs.WriteFile(filepath, []byte(content), 0644)
+		log.PanicIf(err)
+	}
+
+	options := DiskUsageOptions{
+		Filesystem:        mfs,
+		LargestFilesCount: 2,
+		DetectMimetypes:   true,
+	}
+
+	root, err := CalculateDiskUsage("root", options)
+	log.PanicIf(err)
+
+	if root.Bytes != 26 || root.Files != 5 || root.Directories != 3 {
+		t.Fatalf("Root totals not correct: %s", root)
+	}
+
+	expectedLargest := []DiskUsageFile{
+		{Filepath: "root/aa/bb/noextension", Size: 10},
+		{Filepath: "root/aa/image.PNG", Size: 8},
+	}
+
+	if reflect.DeepEqual(root.LargestFiles, expectedLargest) != true {
+		t.Fatalf("Largest files not correct: %v", root.LargestFiles)
+	}
+
+	if *root.ByExtension[".txt"] != (DiskUsageTotals{Bytes: 8, Files: 3}) {
+		t.Fatalf("Extension totals not correct: %v", *root.ByExtension[".txt"])
+	} else if *root.ByExtension[".png"] != (DiskUsageTotals{Bytes: 8, Files: 1}) {
+		t.Fatalf("Extension totals not correct: %v", *root.ByExtension[".png"])
+	} else if *root.ByExtension[""] != (DiskUsageTotals{Bytes: 10, Files: 1}) {
+		t.Fatalf("Extension totals not correct: %v", *root.ByExtension[""])
+	}
+
+	if *root.ByMimetype["image/png"] != (DiskUsageTotals{Bytes: 8, Files: 1}) {
+		t.Fatalf("Mime-type totals not correct: %v", root.ByMimetype)
+	} else if *root.ByMimetype[""] != (DiskUsageTotals{Bytes: 0, Files: 1}) {
+		t.Fatalf("Mime-type totals for empty files not correct: %v", root.ByMimetype)
+	}
+
+	aa := root.Find("aa")
+	if aa.Bytes != 21 || aa.Files != 3 || aa.Directories != 1 {
+		t.Fatalf("Subdirectory totals not correct: %s", aa)
+	}
+
+	bb := root.Find("aa/bb")
+	if bb.Filepath != "root/aa/bb" || bb.Bytes != 13 || bb.Files != 2 {
+		t.Fatalf("Nested totals not correct: %s", bb)
+	}
+
+	if root.Find("aa/missing") != nil {
+		t.Fatalf("Expected nil for missing directory.")
+	}
+
+	children := root.SortedChildren()
+	if len(children) != 2 || children[0] != aa || children[1].Filepath != "root/cc" {
+		t.Fatalf("Children not correct: %v", children)
+	}
+}
+
+func TestCalculateDiskUsage_HardLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Inodes are not available on this platform.")
+	}
+
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, make([]byte, 10000), 0644)
+	log.PanicIf(err)
+
+	err = os.Link(filepath, path.Join(tempPath, "file2"))
+	log.PanicIf(err)
+
+	root, err := CalculateDiskUsage(tempPath, DiskUsageOptions{})
+	log.PanicIf(err)
+
+	if root.Bytes != 20000 || root.Files != 2 {
+		t.Fatalf("Totals without deduplication not correct: %s", root)
+	}
+
+	options := DiskUsageOptions{
+		DeduplicateHardLinks: true,
+	}
+
+	root, err = CalculateDiskUsage(tempPath, options)
+	log.PanicIf(err)
+
+	if root.Bytes != 10000 || root.Files != 1 {
+		t.Fatalf("Totals with deduplication not correct: %s", root)
+	}
+
+	options = DiskUsageOptions{
+		DeduplicateHardLinks: true,
+		SizeMode:             DiskUsageAllocatedSize,
+	}
+
+	root, err = CalculateDiskUsage(tempPath, options)
+	log.PanicIf(err)
+
+	// This depends on the filesystem, but it will be in whole blocks.
+	if root.Bytes%diskUsageBlockSize != 0 {
+		t.Fatalf("Allocated size not correct: (%d)", root.Bytes)
+	}
+}
@@ -1,6 +1,7 @@
 package rifs
 
 import (
+	"io/fs"
 	"os"
 )
 
@@ -17,3 +18,15 @@ func DoesExist(filepath string) bool {
 	f.Close()
 	return true
 }
+
+// DoesExistWithFilesystem is a variant of `DoesExist` that checks the given
+// filesystem rather than the OS.
+func DoesExistWithFilesystem(fsys fs.FS, filepath string) bool {
+	f, err := fsys.Open(filepath)
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	return true
+}
@@ -0,0 +1,482 @@
+package rifs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/crypto"
+)
+
+const (
+	defaultDuplicatePartialHashSize = 4 * 1024
+)
+
+// DuplicateStage identifies the stage of duplicate detection.
+type DuplicateStage int
+
+const (
+	// DuplicateStageScan is the initial scan, where files are grouped by
+	// size.
+	DuplicateStageScan DuplicateStage = iota
+
+	// DuplicateStagePartialHash is where files with the same size are grouped
+	// by a hash of their head and tail.
+	DuplicateStagePartialHash
+
+	// DuplicateStageFullHash is where files with the same partial hash are
+	// grouped by a hash of their whole content.
+	DuplicateStageFullHash
+)
+
+// String returns a descriptive string.
+func (ds DuplicateStage) String() string {
+	if ds == DuplicateStageScan {
+		return "SCAN"
+	} else if ds == DuplicateStagePartialHash {
+		return "PARTIAL-HASH"
+	} else if ds == DuplicateStageFullHash {
+		return "FULL-HASH"
+	}
+
+	log.Panicf("unknown duplicate stage: (%d)", ds)
+	return ""
+}
+
+// DuplicateProgress describes how far along duplicate detection is.
+type DuplicateProgress struct {
+	Stage DuplicateStage
+
+	// FilesDone is the number of files processed in the current stage.
+	FilesDone int
+
+	// FilesTotal is the number of files to process in the current stage. It
+	// is not known during the scan and will be zero.
+	FilesTotal int
+
+	// BytesRead is the number of bytes read in the current stage.
+	BytesRead int64
+}
+
+// DuplicateProgressFunc receives progress updates. Returning an error stops
+// detection.
+type DuplicateProgressFunc func(dp DuplicateProgress) error
+
+// DuplicateGroup is a set of files with identical content.
+type DuplicateGroup struct {
+	Size int64
+
+	// Hash is the full-content hash. For files small enough to be completely
+	// read by the partial hash, it is the partial hash.
+	Hash []byte
+
+	// Filepaths is sorted.
+	Filepaths []string
+}
+
+// String returns a descriptive string.
+func (dg DuplicateGroup) String() string {
+	return fmt.Sprintf("DuplicateGroup<SIZE=(%d) HASH=[%x] COUNT=(%d)>", dg.Size, dg.Hash, len(dg.Filepaths))
+}
+
+// DuplicateOptions describes how to find duplicates.
+type DuplicateOptions struct {
+	// Filter is an optional predicate that can exclude paths.
+	Filter FileListFilterPredicate
+
+	// ErrorPolicy decides what happens when a path can not be processed. If
+	// not provided, detection fails on the first error. Skipped files are
+	// just left out of the results.
+	ErrorPolicy PathErrorPolicy
+
+	// Filesystem is the filesystem to scan. If not provided, the OS is used.
+	Filesystem Filesystem
+
+	// HashFactory returns a new hash. Defaults to `sha256.New`.
+	HashFactory func() hash.Hash
+
+	// PartialHashSize is the number of bytes read from both the head and the
+	// tail of each file for the partial hash. Defaults to 4K.
+	PartialHashSize int64
+
+	// MinimumSize excludes files that are smaller. Empty files are always
+	// excluded.
+	MinimumSize int64
+
+	// Concurrency is the maximum number of files hashed at once. Defaults to
+	// the number of CPUs.
+	Concurrency int
+
+	// Progress, if provided, is called after each file is processed.
+	Progress DuplicateProgressFunc
+}
+
+// duplicateCandidate is a file that might have a duplicate.
+type duplicateCandidate struct {
+	filepath string
+	size     int64
+	hash     []byte
+}
+
+// duplicateFinder holds the state for one invocation of `FindDuplicates`.
+type duplicateFinder struct {
+	options DuplicateOptions
+	fsys    Filesystem
+	fw      *fileWalker
+}
+
+// FindDuplicates returns the groups of files under the given path that have
+// identical content. Files are first grouped by size, then by a hash of their
+// head and tail, and finally by a hash of their whole content, so most files
+// are never read in full. Groups are sorted by descending size.
+func FindDuplicates(rootPath string, options DuplicateOptions) (groups []DuplicateGroup, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if options.HashFactory == nil {
+		options.HashFactory = sha256.New
+	}
+
+	if options.PartialHashSize <= 0 {
+		options.PartialHashSize = defaultDuplicatePartialHashSize
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = runtime.NumCPU()
+	}
+
+	fsys := filesystemOrDefault(options.Filesystem)
+
+	df := &duplicateFinder{
+		options: options,
+		fsys:    fsys,
+		fw: &fileWalker{
+			options: ListFilesOptions{
+				ErrorPolicy: options.ErrorPolicy,
+			},
+		},
+	}
+
+	bySize, err := df.scan(rootPath)
+	log.PanicIf(err)
+
+	// Stage 2: Group by partial hash.
+
+	candidates := make([]duplicateCandidate, 0)
+	for _, sizeCandidates := range bySize {
+		if len(sizeCandidates) > 1 {
+			candidates = append(candidates, sizeCandidates...)
+		}
+	}
+
+	candidates, err = df.hashAll(DuplicateStagePartialHash, candidates, df.partialHash)
+	log.PanicIf(err)
+
+	partialGroups := groupDuplicateCandidates(candidates)
+
+	groups = make([]DuplicateGroup, 0)
+	candidates = make([]duplicateCandidate, 0)
+
+	for _, partialCandidates := range partialGroups {
+		// The partial hash already covered the whole file.
+		if partialCandidates[0].size <= df.options.PartialHashSize*2 {
+			groups = append(groups, newDuplicateGroup(partialCandidates))
+			continue
+		}
+
+		candidates = append(candidates, partialCandidates...)
+	}
+
+	// Stage 3: Group by full hash.
+
+	candidates, err = df.hashAll(DuplicateStageFullHash, candidates, df.fullHash)
+	log.PanicIf(err)
+
+	for _, fullCandidates := range groupDuplicateCandidates(candidates) {
+		groups = append(groups, newDuplicateGroup(fullCandidates))
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+
+		return groups[i].Filepaths[0] < groups[j].Filepaths[0]
+	})
+
+	return groups, nil
+}
+
+// scan lists the tree and groups the regular files by size.
+func (df *duplicateFinder) scan(rootPath string) (bySize map[int64][]duplicateCandidate, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	listOptions := ListFilesOptions{
+		Filter:      df.options.Filter,
+		ErrorPolicy: df.options.ErrorPolicy,
+		Filesystem:  df.fsys,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(rootPath, listOptions)
+	log.PanicIf(err)
+
+	bySize = make(map[int64][]duplicateCandidate)
+	dp := DuplicateProgress{
+		Stage: DuplicateStageScan,
+	}
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				return bySize, nil
+			}
+
+			if event.File == nil {
+				continue
+			}
+
+			fi := event.File.Info
+			if fi.Mode().IsRegular() == false || fi.Size() == 0 || fi.Size() < df.options.MinimumSize {
+				continue
+			}
+
+			dc := duplicateCandidate{
+				filepath: event.File.Filepath,
+				size:     fi.Size(),
+			}
+
+			bySize[dc.size] = append(bySize[dc.size], dc)
+
+			dp.FilesDone++
+
+			err := df.reportProgress(dp)
+			log.PanicIf(err)
+		}
+	}
+}
+
+func (df *duplicateFinder) reportProgress(dp DuplicateProgress) error {
+	if df.options.Progress == nil {
+		return nil
+	}
+
+	return df.options.Progress(dp)
+}
+
+// duplicateHashResult is the outcome of hashing one candidate.
+type duplicateHashResult struct {
+	candidate duplicateCandidate
+	bytesRead int64
+	ok        bool
+	err       error
+}
+
+// hashAll hashes the given candidates with bounded parallelism and returns the
+// ones that could be hashed.
+func (df *duplicateFinder) hashAll(stage DuplicateStage, candidates []duplicateCandidate, hashCb func(dc duplicateCandidate) (sum []byte, bytesRead int64, err error)) (hashed []duplicateCandidate, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	candidatesC := make(chan duplicateCandidate)
+	resultsC := make(chan duplicateHashResult)
+	doneC := make(chan struct{})
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < df.options.Concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for dc := range candidatesC {
+				dhr := duplicateHashResult{
+					candidate: dc,
+				}
+
+				dhr.ok, dhr.err = df.fw.attempt(dc.filepath, "hash", func() (err error) {
+					dhr.candidate.hash, dhr.bytesRead, err = hashCb(dc)
+					return err
+				})
+
+				select {
+				case resultsC <- dhr:
+				case <-doneC:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(candidatesC)
+
+		for _, dc := range candidates {
+			select {
+			case candidatesC <- dc:
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	// Release the workers however we leave.
+	defer func() {
+		close(doneC)
+		wg.Wait()
+	}()
+
+	hashed = make([]duplicateCandidate, 0, len(candidates))
+	dp := DuplicateProgress{
+		Stage:      stage,
+		FilesTotal: len(candidates),
+	}
+
+	for range candidates {
+		dhr := <-resultsC
+		log.PanicIf(dhr.err)
+
+		if dhr.ok == true {
+			hashed = append(hashed, dhr.candidate)
+		}
+
+		dp.FilesDone++
+		dp.BytesRead += dhr.bytesRead
+
+		err := df.reportProgress(dp)
+		log.PanicIf(err)
+	}
+
+	return hashed, nil
+}
+
+// partialHash hashes the head and tail of the file. If the file is small
+// enough, this is the whole file.
+func (df *duplicateFinder) partialHash(dc duplicateCandidate) (sum []byte, bytesRead int64, err error) {
+	f, err := df.fsys.Open(dc.filepath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer f.Close()
+
+	h := df.options.HashFactory()
+	partialSize := df.options.PartialHashSize
+
+	if dc.size <= partialSize*2 {
+		n, err := io.Copy(h, f)
+		if err != nil {
+			return nil, n, err
+		}
+
+		return h.Sum(nil), n, nil
+	}
+
+	n, err := io.CopyN(h, f, partialSize)
+	if err != nil {
+		return nil, n, err
+	}
+
+	bytesRead = n
+	tailOffset := dc.size - partialSize
+
+	if s, ok := f.(io.Seeker); ok == true {
+		_, err = s.Seek(tailOffset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, f, tailOffset-partialSize)
+	}
+
+	if err != nil {
+		return nil, bytesRead, err
+	}
+
+	n, err = io.CopyN(h, f, partialSize)
+	bytesRead += n
+
+	if err != nil {
+		return nil, bytesRead, err
+	}
+
+	return h.Sum(nil), bytesRead, nil
+}
+
+// fullHash hashes the whole file.
+func (df *duplicateFinder) fullHash(dc duplicateCandidate) (sum []byte, bytesRead int64, err error) {
+	f, err := df.fsys.Open(dc.filepath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer f.Close()
+
+	rhp := ricrypto.NewReaderHashProxy(f, df.options.HashFactory())
+
+	bytesRead, err = io.Copy(ioutil.Discard, rhp)
+	if err != nil {
+		return nil, bytesRead, err
+	}
+
+	return rhp.Sum(), bytesRead, nil
+}
+
+// groupDuplicateCandidates groups the candidates by size and hash and returns
+// the groups that have more than one member.
+func groupDuplicateCandidates(candidates []duplicateCandidate) [][]duplicateCandidate {
+	byKey := make(map[string][]duplicateCandidate)
+	keys := make([]string, 0)
+
+	for _, dc := range candidates {
+		key := fmt.Sprintf("%d/%x", dc.size, dc.hash)
+
+		if _, found := byKey[key]; found == false {
+			keys = append(keys, key)
+		}
+
+		byKey[key] = append(byKey[key], dc)
+	}
+
+	grouped := make([][]duplicateCandidate, 0)
+	for _, key := range keys {
+		if len(byKey[key]) > 1 {
+			grouped = append(grouped, byKey[key])
+		}
+	}
+
+	return grouped
+}
+
+func newDuplicateGroup(candidates []duplicateCandidate) DuplicateGroup {
+	filepaths := make([]string, len(candidates))
+	for i, dc := range candidates {
+		filepaths[i] = dc.filepath
+	}
+
+	sort.Strings(filepaths)
+
+	return DuplicateGroup{
+		Size:      candidates[0].size,
+		Hash:      candidates[0].hash,
+		Filepaths: filepaths,
+	}
+}
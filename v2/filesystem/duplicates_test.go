@@ -0,0 +1,138 @@
+package rifs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func newDuplicatesTestFilesystem() *MemoryFilesystem {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("root/aa", 0755)
+	log.PanicIf(err)
+
+	files := map[string]string{
+		// Small enough to be handled by the partial hash alone.
+		"root/small1":    "abc",
+		"root/aa/small2": "abc",
+		"root/small3":    "abd",
+
+		// Same size, head, and tail, but different middles.
+		"root/large1":    "0123456789--AA--0123456789",
+		"root/aa/large2": "0123456789--AA--0123456789",
+		"root/large3":    "0123456789--BB--0123456789",
+
+		"root/unique": "a unique file",
+		"root/empty1": "",
+		"root/empty2": "",
+	}
+
+	for filepath, content := range files {
+		err := mfs.WriteFile(filepath, []byte(content), 0644)
+		log.PanicIf(err)
+	}
+
+	return mfs
+}
+
+func TestFindDuplicates(t *testing.T) {
+	mfs := newDuplicatesTestFilesystem()
+
+	progress := make(map[DuplicateStage]DuplicateProgress)
+
+	options := DuplicateOptions{
+		Filesystem:      mfs,
+		PartialHashSize: 10,
+		Concurrency:     2,
+		Progress: func(dp DuplicateProgress) error {
+			progress[dp.Stage] = dp
+			return nil
+		},
+	}
+
+	groups, err := FindDuplicates("root", options)
+	log.PanicIf(err)
+
+	if len(groups) != 2 {
+		t.Fatalf("Group count not correct: %v", groups)
+	}
+
+	if reflect.DeepEqual(groups[0].Filepaths, []string{"root/aa/large2", "root/large1"}) != true {
+		t.Fatalf("First group not correct: %v", groups[0].Filepaths)
+	} else if groups[0].Size != 26 {
+		t.Fatalf("First group size not correct: (%d)", groups[0].Size)
+	}
+
+	if reflect.DeepEqual(groups[1].Filepaths, []string{"root/aa/small2", "root/small1"}) != true {
+		t.Fatalf("Second group not correct: %v", groups[1].Filepaths)
+	}
+
+	// The scan sees every non-empty file. Only files that share a size are
+	// partially hashed, and only large files with the same partial hash are
+	// fully hashed.
+
+	if progress[DuplicateStageScan].FilesDone != 7 {
+		t.Fatalf("Scan progress not correct: %v", progress[DuplicateStageScan])
+	}
+
+	pp := progress[DuplicateStagePartialHash]
+	if pp.FilesDone != 6 || pp.FilesTotal != 6 {
+		t.Fatalf("Partial-hash progress not correct: %v", pp)
+	} else if pp.BytesRead != 3*3+3*20 {
+		t.Fatalf("Partial-hash bytes not correct: (%d)", pp.BytesRead)
+	}
+
+	fp := progress[DuplicateStageFullHash]
+	if fp.FilesDone != 3 || fp.BytesRead != 3*26 {
+		t.Fatalf("Full-hash progress not correct: %v", fp)
+	}
+}
+
+func TestFindDuplicates_MinimumSize(t *testing.T) {
+	mfs := newDuplicatesTestFilesystem()
+
+	options := DuplicateOptions{
+		Filesystem:  mfs,
+		MinimumSize: 10,
+	}
+
+	groups, err := FindDuplicates("root", options)
+	log.PanicIf(err)
+
+	if len(groups) != 1 || groups[0].Size != 26 {
+		t.Fatalf("Groups not correct: %v", groups)
+	}
+}
+
+func TestFindDuplicates_ProgressError(t *testing.T) {
+	mfs := newDuplicatesTestFilesystem()
+
+	errStop := errors.New("stop")
+
+	options := DuplicateOptions{
+		Filesystem: mfs,
+		Progress: func(dp DuplicateProgress) error {
+			if dp.Stage == DuplicateStagePartialHash {
+				return errStop
+			}
+
+			return nil
+		},
+	}
+
+	_, err := FindDuplicates("root", options)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if log.Is(err, errStop) != true {
+		t.Fatalf("Error not correct: %v", err)
+	}
+}
+
+func TestDuplicateStage_String(t *testing.T) {
+	if DuplicateStageFullHash.String() != "FULL-HASH" {
+		t.Fatalf("String not correct: [%s]", DuplicateStageFullHash.String())
+	}
+}
@@ -0,0 +1,17 @@
+package rifs
+
+// fileStatInfo is the platform-specific information that we care about for a
+// file.
+type fileStatInfo struct {
+	// Device is the ID of the device that contains the file.
+	Device uint64
+
+	// Inode is the inode number.
+	Inode uint64
+
+	// Links is the number of hard-links.
+	Links uint64
+
+	// Blocks is the number of 512-byte blocks allocated.
+	Blocks int64
+}
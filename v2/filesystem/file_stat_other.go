@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !solaris && !illumos
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris,!illumos
+
+package rifs
+
+import (
+	"os"
+)
+
+// getFileStatInfo returns the platform-specific information for the given
+// file. This is not available on this platform.
+func getFileStatInfo(fi os.FileInfo) (fsi fileStatInfo, ok bool) {
+	return fsi, false
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || illumos
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris illumos
+
+package rifs
+
+import (
+	"os"
+	"syscall"
+)
+
+// getFileStatInfo returns the platform-specific information for the given
+// file. `ok` will be false if not available.
+func getFileStatInfo(fi os.FileInfo) (fsi fileStatInfo, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if ok == false {
+		return fsi, false
+	}
+
+	fsi = fileStatInfo{
+		Device: uint64(st.Dev),
+		Inode:  uint64(st.Ino),
+		Links:  uint64(st.Nlink),
+		Blocks: int64(st.Blocks),
+	}
+
+	return fsi, true
+}
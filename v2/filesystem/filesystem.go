@@ -0,0 +1,207 @@
+package rifs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Filesystem is a read-only filesystem. It is a superset of `fs.FS`, so any
+// implementation can also be passed to anything that accepts one.
+type Filesystem interface {
+	fs.FS
+	fs.StatFS
+	fs.ReadDirFS
+
+	// Lstat returns information for the given path without following
+	// symlinks.
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// WritableFilesystem is a `Filesystem` that can also be modified.
+type WritableFilesystem interface {
+	Filesystem
+
+	// OpenFile opens a file with the given `os.O_*` flags and, if created,
+	// permissions.
+	OpenFile(name string, flag int, perm os.FileMode) (ReadWriteSeekCloser, error)
+
+	// Mkdir creates a single directory.
+	Mkdir(name string, perm os.FileMode) error
+
+	// MkdirAll creates a directory and any missing parents.
+	MkdirAll(name string, perm os.FileMode) error
+
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+
+	// Rename moves a file or directory.
+	Rename(oldName, newName string) error
+
+	// Chmod changes the permissions of a file or directory.
+	Chmod(name string, mode os.FileMode) error
+
+	// Chtimes changes the access and modification times.
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// OsFilesystem is a `WritableFilesystem` that forwards directly to the `os`
+// package. Unlike `fs.FS` implementations, names are native paths and may be
+// absolute.
+type OsFilesystem struct {
+}
+
+// NewOsFilesystem returns a new OsFilesystem instance.
+func NewOsFilesystem() *OsFilesystem {
+	return new(OsFilesystem)
+}
+
+// Open opens a file for reading.
+func (OsFilesystem) Open(name string) (fs.File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		// Don't return a typed-nil.
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Stat returns information for the given path.
+func (OsFilesystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Lstat returns information for the given path without following symlinks.
+func (OsFilesystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+// ReadDir returns the sorted entries of the given directory.
+func (OsFilesystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// OpenFile opens a file with the given flags.
+func (OsFilesystem) OpenFile(name string, flag int, perm os.FileMode) (ReadWriteSeekCloser, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// Don't return a typed-nil.
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Mkdir creates a single directory.
+func (OsFilesystem) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (OsFilesystem) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// Remove removes a file or an empty directory.
+func (OsFilesystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Rename moves a file or directory.
+func (OsFilesystem) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+// Chmod changes the permissions of a file or directory.
+func (OsFilesystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times.
+func (OsFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// lstatFs is satisfied by `fs.FS` implementations that know about symlinks.
+type lstatFs interface {
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// IoFilesystem adapts any `fs.FS` (e.g. `embed.FS`, `zip.Reader`, or
+// `os.DirFS`) to a `Filesystem`.
+type IoFilesystem struct {
+	fsys fs.FS
+}
+
+// NewIoFilesystem returns a new IoFilesystem instance.
+func NewIoFilesystem(fsys fs.FS) *IoFilesystem {
+	return &IoFilesystem{
+		fsys: fsys,
+	}
+}
+
+// Open opens a file for reading.
+func (iofs *IoFilesystem) Open(name string) (fs.File, error) {
+	return iofs.fsys.Open(name)
+}
+
+// Stat returns information for the given path.
+func (iofs *IoFilesystem) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(iofs.fsys, name)
+}
+
+// Lstat returns information for the given path without following symlinks. If
+// the underlying filesystem has no concept of symlinks, this is the same as
+// `Stat`.
+func (iofs *IoFilesystem) Lstat(name string) (os.FileInfo, error) {
+	if lfs, ok := iofs.fsys.(lstatFs); ok == true {
+		return lfs.Lstat(name)
+	}
+
+	return fs.Stat(iofs.fsys, name)
+}
+
+// ReadDir returns the sorted entries of the given directory.
+func (iofs *IoFilesystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(iofs.fsys, name)
+}
+
+// simpleDirEntry is a `fs.DirEntry` over a `os.FileInfo`.
+type simpleDirEntry struct {
+	info os.FileInfo
+}
+
+// Name returns the base name of the entry.
+func (sde simpleDirEntry) Name() string {
+	return sde.info.Name()
+}
+
+// IsDir returns true if a directory.
+func (sde simpleDirEntry) IsDir() bool {
+	return sde.info.IsDir()
+}
+
+// Type returns the type bits of the mode.
+func (sde simpleDirEntry) Type() os.FileMode {
+	return sde.info.Mode().Type()
+}
+
+// Info returns the full information.
+func (sde simpleDirEntry) Info() (os.FileInfo, error) {
+	return sde.info, nil
+}
+
+var (
+	defaultFilesystem Filesystem = NewOsFilesystem()
+)
+
+// filesystemOrDefault returns the given filesystem or the OS filesystem if
+// not given.
+func filesystemOrDefault(fsys Filesystem) Filesystem {
+	if fsys == nil {
+		return defaultFilesystem
+	}
+
+	return fsys
+}
@@ -0,0 +1,138 @@
+package rifs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestOsFilesystem(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	var fsys WritableFilesystem = NewOsFilesystem()
+
+	filepath := path.Join(tempPath, "aa", "file1")
+
+	err = fsys.MkdirAll(path.Dir(filepath), 0755)
+	log.PanicIf(err)
+
+	f, err := fsys.OpenFile(filepath, os.O_CREATE|os.O_WRONLY, 0644)
+	log.PanicIf(err)
+
+	_, err = f.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	f.Close()
+
+	fi, err := fsys.Stat(filepath)
+	log.PanicIf(err)
+
+	if fi.Size() != 3 {
+		t.Fatalf("Size not correct: (%d)", fi.Size())
+	}
+
+	entries, err := fsys.ReadDir(path.Dir(filepath))
+	log.PanicIf(err)
+
+	if len(entries) != 1 || entries[0].Name() != "file1" {
+		t.Fatalf("Entries not correct: %v", entries)
+	}
+
+	_, err = fsys.Open(path.Join(tempPath, "invalid"))
+	if os.IsNotExist(err) != true {
+		t.Fatalf("Expected not-exist error: [%v]", err)
+	}
+}
+
+func TestIoFilesystem(t *testing.T) {
+	mapFs := fstest.MapFS{
+		"aa/file1":    &fstest.MapFile{Data: []byte("abc")},
+		"aa/bb/file2": &fstest.MapFile{Data: []byte("defg")},
+	}
+
+	fsys := NewIoFilesystem(mapFs)
+
+	fi, err := fsys.Lstat("aa/bb/file2")
+	log.PanicIf(err)
+
+	if fi.Size() != 4 {
+		t.Fatalf("Size not correct: (%d)", fi.Size())
+	}
+
+	entries, err := fsys.ReadDir("aa")
+	log.PanicIf(err)
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	if reflect.DeepEqual(names, []string{"bb", "file1"}) != true {
+		t.Fatalf("Entries not correct: %v", names)
+	}
+}
+
+func TestListFilesWithOptions_Filesystem(t *testing.T) {
+	mapFs := fstest.MapFS{
+		"aa/file1":    &fstest.MapFile{Data: []byte("abc")},
+		"aa/bb/file2": &fstest.MapFile{Data: []byte("defg")},
+		"file3":       &fstest.MapFile{Data: []byte("hi")},
+	}
+
+	options := ListFilesOptions{
+		Filesystem: NewIoFilesystem(mapFs),
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(".", options)
+	log.PanicIf(err)
+
+	visited := make([]string, 0)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			visited = append(visited, event.File.Filepath)
+		}
+	}
+
+	expected := []string{
+		"aa",
+		"file3",
+		"aa/bb",
+		"aa/file1",
+		"aa/bb/file2",
+	}
+
+	if reflect.DeepEqual(visited, expected) != true {
+		t.Fatalf("Visited paths not correct: %v", visited)
+	}
+}
+
+func TestDoesExistWithFilesystem(t *testing.T) {
+	mapFs := fstest.MapFS{
+		"aa/file1": &fstest.MapFile{Data: []byte("abc")},
+	}
+
+	if DoesExistWithFilesystem(mapFs, "aa/file1") != true {
+		t.Fatalf("Existent file incorrectly looks like it does not exist.")
+	} else if DoesExistWithFilesystem(mapFs, "aa/file2") != false {
+		t.Fatalf("Nonexistent file incorrectly looks like it exists.")
+	}
+}
@@ -1,8 +1,10 @@
 package rifs
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"time"
 )
 
 const (
@@ -16,6 +18,8 @@ const (
 //
 // - Ignore short-writes. If less bytes were written than the bytes that were
 //   given, we'll keep trying until done.
+//
+// On error, `copyCount` is the number of bytes that were successfully written.
 func GracefulCopy(w io.Writer, r io.Reader, buffer []byte) (copyCount int, err error) {
 	if buffer == nil {
 		buffer = make([]byte, defaultCopyBufferSize)
@@ -26,7 +30,7 @@ func GracefulCopy(w io.Writer, r io.Reader, buffer []byte) (copyCount int, err e
 		if err != nil {
 			if err != io.EOF {
 				err = fmt.Errorf("read error: %s", err.Error())
-				return 0, err
+				return copyCount, err
 			}
 
 			// Only break on EOF if no bytes were actually read.
@@ -39,15 +43,193 @@ func GracefulCopy(w io.Writer, r io.Reader, buffer []byte) (copyCount int, err e
 
 		for len(writeBuffer) > 0 {
 			writtenCount, err := w.Write(writeBuffer)
+			copyCount += writtenCount
+
 			if err != nil {
 				err = fmt.Errorf("write error: %s", err.Error())
-				return 0, err
+				return copyCount, err
 			}
 
 			writeBuffer = writeBuffer[writtenCount:]
 		}
+	}
+
+	return copyCount, nil
+}
+
+// CopyRetryPolicy decides whether a failed read should be retried and how long
+// to wait first. `attempt` starts at one and is reset after every successful
+// read.
+type CopyRetryPolicy func(attempt int, err error) (retry bool, delay time.Duration)
+
+// NewCopyRetryPolicy returns a policy that retries up to `maxAttempts` times,
+// doubling the delay (starting at `initialDelay`) after each attempt.
+func NewCopyRetryPolicy(maxAttempts int, initialDelay time.Duration) CopyRetryPolicy {
+	return func(attempt int, err error) (retry bool, delay time.Duration) {
+		if attempt >= maxAttempts {
+			return false, 0
+		}
+
+		return true, initialDelay << uint(attempt-1)
+	}
+}
+
+// CopyOptions describes how `CopyWithOptions` will copy.
+type CopyOptions struct {
+	// Buffer is the buffer to copy with. One is allocated if not provided.
+	Buffer []byte
+
+	// BytesPerSecond limits the rate of the copy. Zero is unlimited.
+	BytesPerSecond int64
+
+	// RateLimiter, if given, also throttles the copy. It can be shared with
+	// other copies and streams in order to enforce a global cap.
+	RateLimiter *RateLimiter
+
+	// RetryPolicy decides whether failed reads are retried. Retries are only
+	// possible if the source is an `io.Seeker`, since we'll need to return to
+	// where the failed read started.
+	RetryPolicy CopyRetryPolicy
+}
+
+// sleepWithContext waits for the given duration or until the context is done.
+func sleepWithContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(duration)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CopyWithOptions copies like `GracefulCopy` but can be cancelled, throttled,
+// and can retry failed reads. `copyCount` is always the number of bytes that
+// were successfully written, even on error, so an interrupted copy can be
+// resumed from there.
+//
+// If there are no limits, no retry policy, and the context can not be
+// cancelled, `io.WriterTo` and `io.ReaderFrom` are used when available.
+func CopyWithOptions(ctx context.Context, w io.Writer, r io.Reader, options CopyOptions) (copyCount int64, err error) {
+	if ctx.Done() == nil && options.BytesPerSecond <= 0 && options.RateLimiter == nil && options.RetryPolicy == nil {
+		if wt, ok := r.(io.WriterTo); ok == true {
+			return wt.WriteTo(w)
+		} else if rf, ok := w.(io.ReaderFrom); ok == true {
+			return rf.ReadFrom(r)
+		}
+	}
+
+	buffer := options.Buffer
+	if buffer == nil {
+		buffer = make([]byte, defaultCopyBufferSize)
+	}
+
+	// Don't read more than a second's worth at a time or the rate will be
+	// very uneven.
+	if options.BytesPerSecond > 0 && int64(len(buffer)) > options.BytesPerSecond {
+		buffer = buffer[:options.BytesPerSecond]
+	}
+
+	// Nor more than the limiter will allow at once.
+	if options.RateLimiter != nil {
+		if burst := options.RateLimiter.Burst(); burst > 0 && int64(len(buffer)) > burst {
+			buffer = buffer[:burst]
+		}
+	}
+
+	// If we might retry, we need to know where we started.
+
+	var s io.Seeker
+	var initialOffset int64
+
+	if options.RetryPolicy != nil {
+		if s, _ = r.(io.Seeker); s != nil {
+			initialOffset, err = s.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return 0, fmt.Errorf("seek error: %w", err)
+			}
+		}
+	}
 
-		copyCount += readCount
+	startAt := time.Now()
+	attempt := 0
+
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return copyCount, err
+		}
+
+		readCount, err := r.Read(buffer)
+		if err != nil && err != io.EOF {
+			if s == nil {
+				return copyCount, fmt.Errorf("read error: %w", err)
+			}
+
+			attempt++
+
+			retry, delay := options.RetryPolicy(attempt, err)
+			if retry == false {
+				return copyCount, fmt.Errorf("read error: %w", err)
+			}
+
+			// Anything that was read along with the error is discarded and
+			// read again.
+
+			err = sleepWithContext(ctx, delay)
+			if err != nil {
+				return copyCount, err
+			}
+
+			_, err = s.Seek(initialOffset+copyCount, io.SeekStart)
+			if err != nil {
+				return copyCount, fmt.Errorf("seek error: %w", err)
+			}
+
+			continue
+		}
+
+		attempt = 0
+
+		// Only break on EOF if no bytes were actually read.
+		if err == io.EOF && readCount == 0 {
+			break
+		}
+
+		if options.RateLimiter != nil {
+			err := options.RateLimiter.WaitN(ctx, readCount)
+			if err != nil {
+				return copyCount, err
+			}
+		}
+
+		writeBuffer := buffer[:readCount]
+
+		for len(writeBuffer) > 0 {
+			writtenCount, err := w.Write(writeBuffer)
+			copyCount += int64(writtenCount)
+
+			if err != nil {
+				return copyCount, fmt.Errorf("write error: %w", err)
+			}
+
+			writeBuffer = writeBuffer[writtenCount:]
+		}
+
+		if options.BytesPerSecond > 0 {
+			expected := time.Duration(float64(copyCount) / float64(options.BytesPerSecond) * float64(time.Second))
+
+			err := sleepWithContext(ctx, expected-time.Since(startAt))
+			if err != nil {
+				return copyCount, err
+			}
+		}
 	}
 
 	return copyCount, nil
@@ -2,9 +2,12 @@ package rifs
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dsoprea/go-logging"
 )
@@ -168,3 +171,322 @@ func TestGracefulCopy__UncleanEof(t *testing.T) {
 		t.Fatalf("Copied bytes not correct.")
 	}
 }
+
+// failingWriter fails once a certain number of bytes have been written.
+type failingWriter struct {
+	w     io.Writer
+	limit int
+}
+
+func (fw *failingWriter) Write(buffer []byte) (n int, err error) {
+	if len(buffer) > fw.limit {
+		buffer = buffer[:fw.limit]
+		err = errors.New("write limit reached")
+	}
+
+	n, writeErr := fw.w.Write(buffer)
+	log.PanicIf(writeErr)
+
+	fw.limit -= n
+
+	return n, err
+}
+
+func TestGracefulCopy__PartialCountOnError(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 100))
+	sbFrom := NewSeekableBufferWithBytes(data)
+
+	sbTo := NewSeekableBuffer()
+	fw := &failingWriter{
+		w:     sbTo,
+		limit: 25,
+	}
+
+	n, err := GracefulCopy(fw, sbFrom, make([]byte, 10))
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if n != 25 {
+		t.Fatalf("Partial count not correct: (%d)", n)
+	}
+}
+
+func TestCopyWithOptions(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 1000))
+	sbFrom := NewSeekableBufferWithBytes(data)
+	sbTo := NewSeekableBuffer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	options := CopyOptions{
+		Buffer: make([]byte, 7),
+	}
+
+	n, err := CopyWithOptions(ctx, sbTo, sbFrom, options)
+	log.PanicIf(err)
+
+	if n != int64(len(data)) {
+		t.Fatalf("Count of copied bytes not correct: (%d)", n)
+	} else if bytes.Equal(sbTo.Bytes(), data) != true {
+		t.Fatalf("Copied bytes not correct.")
+	}
+}
+
+func TestCopyWithOptions_PartialCountOnError(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 100))
+	sbFrom := NewSeekableBufferWithBytes(data)
+
+	sbTo := NewSeekableBuffer()
+	fw := &failingWriter{
+		w:     sbTo,
+		limit: 25,
+	}
+
+	options := CopyOptions{
+		Buffer: make([]byte, 10),
+	}
+
+	n, err := CopyWithOptions(context.Background(), fw, sbFrom, options)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if n != 25 {
+		t.Fatalf("Partial count not correct: (%d)", n)
+	}
+}
+
+func TestCopyWithOptions_Cancel(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 100))
+	sbFrom := NewSeekableBufferWithBytes(data)
+	sbTo := NewSeekableBuffer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n, err := CopyWithOptions(ctx, sbTo, sbFrom, CopyOptions{})
+	if err != context.Canceled {
+		t.Fatalf("Expected cancellation: %v", err)
+	} else if n != 0 {
+		t.Fatalf("Nothing should have been copied: (%d)", n)
+	}
+}
+
+func TestCopyWithOptions_BytesPerSecond(t *testing.T) {
+	data := make([]byte, 300)
+	sbFrom := NewSeekableBufferWithBytes(data)
+	sbTo := NewSeekableBuffer()
+
+	options := CopyOptions{
+		BytesPerSecond: 1000,
+	}
+
+	startAt := time.Now()
+
+	n, err := CopyWithOptions(context.Background(), sbTo, sbFrom, options)
+	log.PanicIf(err)
+
+	duration := time.Since(startAt)
+
+	if n != 300 {
+		t.Fatalf("Count of copied bytes not correct: (%d)", n)
+	} else if duration < time.Millisecond*250 {
+		t.Fatalf("Copy was not throttled: %s", duration)
+	}
+}
+
+func TestCopyWithOptions_BytesPerSecond_Cancel(t *testing.T) {
+	data := make([]byte, 1000)
+	sbFrom := NewSeekableBufferWithBytes(data)
+	sbTo := NewSeekableBuffer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	options := CopyOptions{
+		Buffer:         make([]byte, 10),
+		BytesPerSecond: 100,
+	}
+
+	n, err := CopyWithOptions(ctx, sbTo, sbFrom, options)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline: %v", err)
+	} else if n == 0 || n >= 1000 {
+		t.Fatalf("Expected a partial copy: (%d)", n)
+	} else if int64(len(sbTo.Bytes())) != n {
+		t.Fatalf("Count does not match what was written: (%d) != (%d)", len(sbTo.Bytes()), n)
+	}
+}
+
+func TestCopyWithOptions_RateLimiter(t *testing.T) {
+	rl := NewRateLimiter(1000, 100)
+
+	// Two copies share the same cap.
+
+	startAt := time.Now()
+
+	for i := 0; i < 2; i++ {
+		sbFrom := NewSeekableBufferWithBytes(make([]byte, 150))
+		sbTo := NewSeekableBuffer()
+
+		options := CopyOptions{
+			RateLimiter: rl,
+		}
+
+		n, err := CopyWithOptions(context.Background(), sbTo, sbFrom, options)
+		log.PanicIf(err)
+
+		if n != 150 {
+			t.Fatalf("Count of copied bytes not correct: (%d)", n)
+		}
+	}
+
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Copies were not throttled: %s", duration)
+	}
+}
+
+func TestCopyWithOptions_RateLimiter_Unlimited(t *testing.T) {
+	rl := NewRateLimiter(0, 0)
+
+	rsc := NewReadSeekCounter(NewSeekableBufferWithBytes(make([]byte, 10000)))
+	sbTo := NewSeekableBuffer()
+
+	options := CopyOptions{
+		RateLimiter: rl,
+	}
+
+	n, err := CopyWithOptions(context.Background(), sbTo, rsc, options)
+	log.PanicIf(err)
+
+	// One read for the data and one for the EOF.
+
+	if n != 10000 {
+		t.Fatalf("Count of copied bytes not correct: (%d)", n)
+	} else if rsc.Stats().Reads != 2 {
+		t.Fatalf("Reads should not have been limited: %s", rsc.Stats())
+	}
+}
+
+// flakyReader fails every other read, after having read part of the data.
+type flakyReader struct {
+	rs     io.ReadSeeker
+	failed bool
+}
+
+func (fr *flakyReader) Read(buffer []byte) (n int, err error) {
+	if fr.failed == false {
+		fr.failed = true
+
+		n, _ = fr.rs.Read(buffer[:len(buffer)/2])
+		return n, errors.New("transient error")
+	}
+
+	fr.failed = false
+
+	return fr.rs.Read(buffer)
+}
+
+func (fr *flakyReader) Seek(offset int64, whence int) (int64, error) {
+	return fr.rs.Seek(offset, whence)
+}
+
+func TestCopyWithOptions_Retry(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 100))
+	sbFrom := NewSeekableBufferWithBytes(data)
+
+	// Start partway through to make sure that we return to the right place.
+
+	_, err := sbFrom.Seek(10, io.SeekStart)
+	log.PanicIf(err)
+
+	fr := &flakyReader{
+		rs: sbFrom,
+	}
+
+	sbTo := NewSeekableBuffer()
+
+	options := CopyOptions{
+		Buffer:      make([]byte, 16),
+		RetryPolicy: NewCopyRetryPolicy(2, time.Millisecond),
+	}
+
+	n, err := CopyWithOptions(context.Background(), sbTo, fr, options)
+	log.PanicIf(err)
+
+	if n != int64(len(data)-10) {
+		t.Fatalf("Count of copied bytes not correct: (%d)", n)
+	} else if bytes.Equal(sbTo.Bytes(), data[10:]) != true {
+		t.Fatalf("Copied bytes not correct.")
+	}
+
+	// Without retries, the first failure is fatal.
+
+	_, err = sbFrom.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	fr.failed = false
+
+	_, err = CopyWithOptions(context.Background(), NewSeekableBuffer(), fr, CopyOptions{})
+	if err == nil {
+		t.Fatalf("Expected error without retries.")
+	}
+}
+
+func TestNewCopyRetryPolicy(t *testing.T) {
+	policy := NewCopyRetryPolicy(3, time.Millisecond*10)
+
+	if retry, delay := policy(1, nil); retry != true || delay != time.Millisecond*10 {
+		t.Fatalf("First attempt not correct: %v %s", retry, delay)
+	} else if retry, delay := policy(2, nil); retry != true || delay != time.Millisecond*20 {
+		t.Fatalf("Second attempt not correct: %v %s", retry, delay)
+	} else if retry, _ := policy(3, nil); retry != false {
+		t.Fatalf("Last attempt should not be retried.")
+	}
+}
+
+// writerToReader records whether `WriteTo` was used.
+type writerToReader struct {
+	*bytes.Reader
+	used bool
+}
+
+func (wtr *writerToReader) WriteTo(w io.Writer) (n int64, err error) {
+	wtr.used = true
+	return wtr.Reader.WriteTo(w)
+}
+
+func TestCopyWithOptions_FastPath(t *testing.T) {
+	data := []byte("test bytes")
+
+	wtr := &writerToReader{
+		Reader: bytes.NewReader(data),
+	}
+
+	sbTo := NewSeekableBuffer()
+
+	n, err := CopyWithOptions(context.Background(), sbTo, wtr, CopyOptions{})
+	log.PanicIf(err)
+
+	if n != int64(len(data)) {
+		t.Fatalf("Count of copied bytes not correct: (%d)", n)
+	} else if wtr.used != true {
+		t.Fatalf("Fast path not used.")
+	} else if bytes.Equal(sbTo.Bytes(), data) != true {
+		t.Fatalf("Copied bytes not correct.")
+	}
+
+	// Cancellable contexts need the loop.
+
+	wtr = &writerToReader{
+		Reader: bytes.NewReader(data),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = CopyWithOptions(ctx, NewSeekableBuffer(), wtr, CopyOptions{})
+	log.PanicIf(err)
+
+	if wtr.used != false {
+		t.Fatalf("Fast path should not have been used.")
+	}
+}
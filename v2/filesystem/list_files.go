@@ -1,7 +1,9 @@
 package rifs
 
 import (
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 
@@ -18,6 +20,341 @@ type VisitedFile struct {
 	Index    int
 }
 
+// PathErrorAction tells the walker how to proceed after a per-path error.
+type PathErrorAction int
+
+const (
+	// PathErrorSkip skips the failed path and continues the walk.
+	PathErrorSkip PathErrorAction = iota
+
+	// PathErrorRetry retries the failed operation.
+	PathErrorRetry
+
+	// PathErrorAbort terminates the walk.
+	PathErrorAbort
+)
+
+// String returns a descriptive string.
+func (pea PathErrorAction) String() string {
+	if pea == PathErrorSkip {
+		return "SKIP"
+	} else if pea == PathErrorRetry {
+		return "RETRY"
+	} else if pea == PathErrorAbort {
+		return "ABORT"
+	}
+
+	log.Panicf("unknown path-error action: (%d)", pea)
+	return ""
+}
+
+const (
+	// MaxPathErrorAttempts is the most times that one operation will be
+	// attempted, whatever the policy says. Once reached, the path is skipped.
+	MaxPathErrorAttempts = 10
+
+	// listFilesPageSize is the number of directory entries read at a time.
+	listFilesPageSize = 1000
+)
+
+// PathError describes a failure to process one path during a walk.
+type PathError struct {
+	// Filepath is the path that could not be processed.
+	Filepath string
+
+	// Operation is the operation that failed ("lstat" or "readdir").
+	Operation string
+
+	// Attempt is the one-based count of attempts made so far.
+	Attempt int
+
+	// Err is the original, unwrapped error.
+	Err error
+}
+
+// Error returns the error message.
+func (pe *PathError) Error() string {
+	return fmt.Sprintf("%s [%s] failed (attempt %d): %s", pe.Operation, pe.Filepath, pe.Attempt, pe.Err.Error())
+}
+
+// Unwrap returns the original error.
+func (pe *PathError) Unwrap() error {
+	return pe.Err
+}
+
+// IsNotExist returns true if the path disappeared before it could be
+// processed.
+func (pe *PathError) IsNotExist() bool {
+	return os.IsNotExist(pe.Err)
+}
+
+// IsPermission returns true if the path could not be accessed.
+func (pe *PathError) IsPermission() bool {
+	return os.IsPermission(pe.Err)
+}
+
+// PathErrorPolicy decides what to do about a per-path error.
+type PathErrorPolicy func(pe *PathError) PathErrorAction
+
+// SkipPathErrorPolicy skips every path that fails.
+func SkipPathErrorPolicy(pe *PathError) PathErrorAction {
+	return PathErrorSkip
+}
+
+// AbortPathErrorPolicy aborts the walk on the first failure. This is the
+// behavior of `ListFiles`.
+func AbortPathErrorPolicy(pe *PathError) PathErrorAction {
+	return PathErrorAbort
+}
+
+// NewRetryPathErrorPolicy returns a policy that skips paths that have vanished
+// or can not be accessed (retrying won't help either) and retries anything
+// else up to `maxAttempts` times before skipping it. Retries are never made
+// more than `MaxPathErrorAttempts` times regardless.
+func NewRetryPathErrorPolicy(maxAttempts int) PathErrorPolicy {
+	return func(pe *PathError) PathErrorAction {
+		if pe.IsNotExist() == true || pe.IsPermission() == true {
+			return PathErrorSkip
+		} else if pe.Attempt < maxAttempts {
+			return PathErrorRetry
+		}
+
+		return PathErrorSkip
+	}
+}
+
+// ListFilesEvent is one event from a tolerant walk. Exactly one of `File` and
+// `Error` will be set.
+type ListFilesEvent struct {
+	File  *VisitedFile
+	Error *PathError
+}
+
+// ListFilesOptions describes how `ListFilesWithOptions` will walk.
+type ListFilesOptions struct {
+	// Filter is an optional predicate that can exclude paths.
+	Filter FileListFilterPredicate
+
+	// ErrorPolicy decides what happens when a path can not be processed. If
+	// not provided, the walk aborts on the first error.
+	ErrorPolicy PathErrorPolicy
+
+	// Filesystem is the filesystem to walk. If not provided, the walk is done
+	// against the OS.
+	Filesystem Filesystem
+
+	// IncludeSymlinks reports symlinks below the root rather than skipping
+	// them. They are never followed.
+	IncludeSymlinks bool
+}
+
+// fileWalker does the actual recursive scan and forwards what it finds to the
+// given emitters.
+type fileWalker struct {
+	rootPath string
+	options  ListFilesOptions
+
+	emitFile  func(vf VisitedFile)
+	emitError func(pe *PathError)
+}
+
+// attempt runs the given operation until it succeeds, the policy tells us to
+// stop, or `MaxPathErrorAttempts` is reached. `ok` will be false if the path is
+// to be skipped. A non-nil error means that the walk must be aborted.
+func (fw *fileWalker) attempt(filepath, operation string, cb func() error) (ok bool, err error) {
+	policy := fw.options.ErrorPolicy
+	if policy == nil {
+		policy = AbortPathErrorPolicy
+	}
+
+	for i := 1; ; i++ {
+		errRaw := cb()
+		if errRaw == nil {
+			return true, nil
+		}
+
+		pe := &PathError{
+			Filepath:  filepath,
+			Operation: operation,
+			Attempt:   i,
+			Err:       errRaw,
+		}
+
+		action := policy(pe)
+
+		if action == PathErrorRetry && i >= MaxPathErrorAttempts {
+			action = PathErrorSkip
+		}
+
+		if action == PathErrorRetry {
+			continue
+		} else if action == PathErrorSkip {
+			if fw.emitError != nil {
+				fw.emitError(pe)
+			}
+
+			return false, nil
+		}
+
+		return false, pe
+	}
+}
+
+// readDirectory passes the entries of the given directory to `cb` a page at a
+// time so that large directories are never loaded all at once. `ok` will be
+// false if the directory is to be skipped.
+func (fw *fileWalker) readDirectory(fsys Filesystem, folderPath string, cb func(children []fs.DirEntry)) (ok bool, err error) {
+	var f fs.File
+
+	ok, err = fw.attempt(folderPath, "readdir", func() (err error) {
+		f, err = fsys.Open(folderPath)
+		return err
+	})
+
+	if ok == false {
+		return false, err
+	}
+
+	defer f.Close()
+
+	rdf, isReadDirFile := f.(fs.ReadDirFile)
+	if isReadDirFile == false {
+		var children []fs.DirEntry
+
+		ok, err = fw.attempt(folderPath, "readdir", func() (err error) {
+			children, err = fsys.ReadDir(folderPath)
+			return err
+		})
+
+		if ok == true {
+			cb(children)
+		}
+
+		return ok, err
+	}
+
+	for {
+		var children []fs.DirEntry
+		done := false
+
+		ok, err = fw.attempt(folderPath, "readdir", func() (err error) {
+			children, err = rdf.ReadDir(listFilesPageSize)
+			if err == io.EOF {
+				done = true
+				return nil
+			}
+
+			return err
+		})
+
+		if ok == false {
+			return false, err
+		}
+
+		cb(children)
+
+		if done == true {
+			return true, nil
+		}
+	}
+}
+
+func (fw *fileWalker) walk() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	fsys := filesystemOrDefault(fw.options.Filesystem)
+
+	index := 0
+
+	queue := []string{fw.rootPath}
+	for len(queue) > 0 {
+		// Pop the next folder to process off the queue.
+		var thisPath string
+		thisPath, queue = queue[0], queue[1:]
+
+		// Skip path if a symlink.
+
+		var fi os.FileInfo
+
+		ok, err := fw.attempt(thisPath, "lstat", func() (err error) {
+			fi, err = fsys.Lstat(thisPath)
+			return err
+		})
+
+		log.PanicIf(err)
+
+		if ok == false {
+			continue
+		}
+
+		if (fi.Mode() & os.ModeSymlink) > 0 {
+			continue
+		}
+
+		// Read information and iterate through children.
+
+		_, err = fw.readDirectory(fsys, thisPath, func(children []fs.DirEntry) {
+			for _, child := range children {
+				filepath := path.Join(thisPath, child.Name())
+
+				// Skip if a file symlink (unless requested).
+
+				ok, err := fw.attempt(filepath, "lstat", func() (err error) {
+					fi, err = fsys.Lstat(filepath)
+					return err
+				})
+
+				log.PanicIf(err)
+
+				if ok == false {
+					continue
+				}
+
+				if (fi.Mode()&os.ModeSymlink) > 0 && fw.options.IncludeSymlinks == false {
+					continue
+				}
+
+				// If a predicate was given, determine if this child will be
+				// left behind.
+				if fw.options.Filter != nil {
+					hit, err := fw.options.Filter(thisPath, fi)
+					log.PanicIf(err)
+
+					if hit == false {
+						continue
+					}
+				}
+
+				index++
+
+				// Push file to channel.
+
+				vf := VisitedFile{
+					Filepath: filepath,
+					Info:     fi,
+					Index:    index,
+				}
+
+				fw.emitFile(vf)
+
+				// If a folder, queue for later processing.
+
+				if fi.IsDir() == true {
+					queue = append(queue, filepath)
+				}
+			}
+		})
+
+		log.PanicIf(err)
+	}
+
+	return nil
+}
+
 // ListFiles feeds a continuous list of files from a recursive folder scan. An
 // optional predicate can be provided in order to filter. When done, the
 // `filesC` channel is closed. If there's an error, the `errC` channel will
@@ -41,103 +378,83 @@ func ListFiles(rootPath string, cb FileListFilterPredicate) (filesC chan Visited
 
 	filesC = make(chan VisitedFile, 100)
 	errC = make(chan error, 1)
-	index := 0
 
-	go func() {
-		defer func() {
-			if state := recover(); state != nil {
-				err := log.Wrap(state.(error))
-				errC <- err
-			}
-		}()
-
-		queue := []string{rootPath}
-		for len(queue) > 0 {
-			// Pop the next folder to process off the queue.
-			var thisPath string
-			thisPath, queue = queue[0], queue[1:]
-
-			// Skip path if a symlink.
-
-			fi, err := os.Lstat(thisPath)
-			log.PanicIf(err)
-
-			if (fi.Mode() & os.ModeSymlink) > 0 {
-				continue
-			}
-
-			// Read information.
-
-			folderF, err := os.Open(thisPath)
-			if err != nil {
-				errC <- log.Wrap(err)
-				return
-			}
+	fw := &fileWalker{
+		rootPath: rootPath,
+		options: ListFilesOptions{
+			Filter: cb,
+		},
+		emitFile: func(vf VisitedFile) {
+			filesC <- vf
+		},
+	}
 
-			// Iterate through children.
-
-			for {
-				children, err := folderF.Readdir(1000)
-				if err == io.EOF {
-					break
-				} else if err != nil {
-					errC <- log.Wrap(err)
-					return
-				}
-
-				for _, child := range children {
-					filepath := path.Join(thisPath, child.Name())
-
-					// Skip if a file symlink.
-
-					fi, err := os.Lstat(filepath)
-					log.PanicIf(err)
+	go func() {
+		err := fw.walk()
+		if err != nil {
+			errC <- log.Wrap(err)
+			return
+		}
 
-					if (fi.Mode() & os.ModeSymlink) > 0 {
-						continue
-					}
+		close(filesC)
+		close(errC)
+	}()
 
-					// If a predicate was given, determine if this child will be
-					// left behind.
-					if cb != nil {
-						hit, err := cb(thisPath, child)
+	return filesC, 0, errC
+}
 
-						if err != nil {
-							errC <- log.Wrap(err)
-							return
-						}
+// ListFilesWithOptions is a variant of `ListFiles` that can tolerate errors
+// with individual paths. Visited files and any skipped path-errors are both
+// delivered on `eventsC`, in the order that they happen. Whether a path-error
+// is skipped, retried, or aborts the walk is decided by the configured
+// policy. When done, `eventsC` is closed. If the walk is aborted, `errC` will
+// receive the error.
+func ListFilesWithOptions(rootPath string, options ListFilesOptions) (eventsC chan ListFilesEvent, errC chan error, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
 
-						if hit == false {
-							continue
-						}
-					}
+	// Make sure the path exists.
 
-					index++
+	fsys := filesystemOrDefault(options.Filesystem)
 
-					// Push file to channel.
+	f, err := fsys.Open(rootPath)
+	log.PanicIf(err)
 
-					vf := VisitedFile{
-						Filepath: filepath,
-						Info:     child,
-						Index:    index,
-					}
+	f.Close()
 
-					filesC <- vf
+	// Do our thing.
 
-					// If a folder, queue for later processing.
+	eventsC = make(chan ListFilesEvent, 100)
+	errC = make(chan error, 1)
 
-					if child.IsDir() == true {
-						queue = append(queue, filepath)
-					}
-				}
+	fw := &fileWalker{
+		rootPath: rootPath,
+		options:  options,
+		emitFile: func(vf VisitedFile) {
+			eventsC <- ListFilesEvent{
+				File: &vf,
+			}
+		},
+		emitError: func(pe *PathError) {
+			eventsC <- ListFilesEvent{
+				Error: pe,
 			}
+		},
+	}
 
-			folderF.Close()
+	go func() {
+		err := fw.walk()
+		if err != nil {
+			errC <- log.Wrap(err)
+			return
 		}
 
-		close(filesC)
+		close(eventsC)
 		close(errC)
 	}()
 
-	return filesC, index, errC
+	return eventsC, errC, nil
 }
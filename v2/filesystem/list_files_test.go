@@ -1,11 +1,16 @@
 package rifs
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"reflect"
 	"sort"
 	"testing"
 
+	"io/ioutil"
+
 	"github.com/dsoprea/go-logging"
 )
 
@@ -109,3 +114,339 @@ FilesRead:
 		t.Fatalf("We did not visit the paths we expected: %v", visited)
 	}
 }
+
+func TestListFilesWithOptions(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	err = os.MkdirAll(path.Join(tempPath, "aa", "bb"), 0755)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "aa", "file1"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "aa", "bb", "file2"), []byte("def"), 0644)
+	log.PanicIf(err)
+
+	options := ListFilesOptions{
+		ErrorPolicy: SkipPathErrorPolicy,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(tempPath, options)
+	log.PanicIf(err)
+
+	visited := make([]string, 0)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			if event.Error != nil {
+				t.Fatalf("Unexpected path-error: %v", event.Error)
+			}
+
+			visited = append(visited, event.File.Filepath)
+		}
+	}
+
+	sort.Strings(visited)
+
+	expected := []string{
+		path.Join(tempPath, "aa"),
+		path.Join(tempPath, "aa", "bb"),
+		path.Join(tempPath, "aa", "bb", "file2"),
+		path.Join(tempPath, "aa", "file1"),
+	}
+
+	if reflect.DeepEqual(visited, expected) != true {
+		t.Fatalf("Visited paths not correct: %v", visited)
+	}
+}
+
+func TestListFilesWithOptions_LargeDirectory(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	// Spans several pages.
+
+	count := listFilesPageSize*2 + 1
+
+	for i := 0; i < count; i++ {
+		filepath := path.Join(tempPath, fmt.Sprintf("file%d", i))
+
+		err := ioutil.WriteFile(filepath, nil, 0644)
+		log.PanicIf(err)
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(tempPath, ListFilesOptions{})
+	log.PanicIf(err)
+
+	visited := make(map[string]struct{})
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			visited[event.File.Filepath] = struct{}{}
+		}
+	}
+
+	if len(visited) != count {
+		t.Fatalf("Visited count not correct: (%d)", len(visited))
+	}
+}
+
+func TestListFilesWithOptions_IncludeSymlinks(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	err = os.Mkdir(path.Join(tempPath, "aa"), 0755)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "aa", "file1"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	// A link to a directory should be reported but not followed.
+	err = os.Symlink("aa", path.Join(tempPath, "link"))
+	log.PanicIf(err)
+
+	for _, includeSymlinks := range []bool{false, true} {
+		options := ListFilesOptions{
+			IncludeSymlinks: includeSymlinks,
+		}
+
+		eventsC, errC, err := ListFilesWithOptions(tempPath, options)
+		log.PanicIf(err)
+
+		visited := make([]string, 0)
+
+	EventsRead:
+
+		for {
+			select {
+			case err := <-errC:
+				log.PanicIf(err)
+
+			case event, ok := <-eventsC:
+				if ok == false {
+					break EventsRead
+				}
+
+				visited = append(visited, event.File.Filepath)
+			}
+		}
+
+		sort.Strings(visited)
+
+		expected := []string{
+			path.Join(tempPath, "aa"),
+			path.Join(tempPath, "aa", "file1"),
+		}
+
+		if includeSymlinks == true {
+			expected = append(expected, path.Join(tempPath, "link"))
+		}
+
+		if reflect.DeepEqual(visited, expected) != true {
+			t.Fatalf("Visited paths not correct (include-symlinks=%v): %v", includeSymlinks, visited)
+		}
+	}
+}
+
+func TestListFilesWithOptions_UnreadableDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Permissions are not enforced for root.")
+	}
+
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	lockedPath := path.Join(tempPath, "locked")
+
+	err = os.Mkdir(lockedPath, 0000)
+	log.PanicIf(err)
+
+	defer os.Chmod(lockedPath, 0755)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "file1"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	options := ListFilesOptions{
+		ErrorPolicy: SkipPathErrorPolicy,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(tempPath, options)
+	log.PanicIf(err)
+
+	files := 0
+	pathErrors := make([]*PathError, 0)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			if event.Error != nil {
+				pathErrors = append(pathErrors, event.Error)
+			} else {
+				files++
+			}
+		}
+	}
+
+	if files != 2 {
+		t.Fatalf("Visited file count not correct: (%d)", files)
+	} else if len(pathErrors) != 1 {
+		t.Fatalf("Path-error count not correct: (%d)", len(pathErrors))
+	}
+
+	pe := pathErrors[0]
+
+	if pe.Filepath != lockedPath {
+		t.Fatalf("Path-error path not correct: [%s]", pe.Filepath)
+	} else if pe.Operation != "readdir" {
+		t.Fatalf("Path-error operation not correct: [%s]", pe.Operation)
+	} else if pe.IsPermission() != true {
+		t.Fatalf("Path-error is not a permission error: %v", pe.Err)
+	}
+}
+
+func TestFileWalker_attempt_Retry(t *testing.T) {
+	fw := &fileWalker{
+		options: ListFilesOptions{
+			ErrorPolicy: NewRetryPathErrorPolicy(3),
+		},
+	}
+
+	calls := 0
+
+	ok, err := fw.attempt("some/path", "lstat", func() error {
+		calls++
+
+		if calls < 3 {
+			return errors.New("transient failure")
+		}
+
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	if ok != true {
+		t.Fatalf("Operation should have eventually succeeded.")
+	} else if calls != 3 {
+		t.Fatalf("Call count not correct: (%d)", calls)
+	}
+}
+
+func TestFileWalker_attempt_RetryForever(t *testing.T) {
+	skipped := make([]*PathError, 0)
+
+	fw := &fileWalker{
+		options: ListFilesOptions{
+			ErrorPolicy: func(pe *PathError) PathErrorAction {
+				return PathErrorRetry
+			},
+		},
+		emitError: func(pe *PathError) {
+			skipped = append(skipped, pe)
+		},
+	}
+
+	calls := 0
+
+	ok, err := fw.attempt("some/path", "lstat", func() error {
+		calls++
+		return errors.New("permanent failure")
+	})
+
+	log.PanicIf(err)
+
+	if ok != false {
+		t.Fatalf("Operation should have been skipped.")
+	} else if calls != MaxPathErrorAttempts {
+		t.Fatalf("Call count not correct: (%d)", calls)
+	} else if len(skipped) != 1 || skipped[0].Attempt != MaxPathErrorAttempts {
+		t.Fatalf("Skipped path-error not reported correctly: %v", skipped)
+	}
+}
+
+func TestFileWalker_attempt_SkipNotExist(t *testing.T) {
+	skipped := make([]*PathError, 0)
+
+	fw := &fileWalker{
+		options: ListFilesOptions{
+			ErrorPolicy: NewRetryPathErrorPolicy(3),
+		},
+		emitError: func(pe *PathError) {
+			skipped = append(skipped, pe)
+		},
+	}
+
+	calls := 0
+
+	ok, err := fw.attempt("some/path", "lstat", func() error {
+		calls++
+		return os.ErrNotExist
+	})
+
+	log.PanicIf(err)
+
+	if ok != false {
+		t.Fatalf("Operation should have been skipped.")
+	} else if calls != 1 {
+		t.Fatalf("Vanished path should not have been retried: (%d)", calls)
+	} else if len(skipped) != 1 || skipped[0].IsNotExist() != true {
+		t.Fatalf("Skipped path-error not reported correctly: %v", skipped)
+	}
+}
+
+func TestFileWalker_attempt_Abort(t *testing.T) {
+	fw := &fileWalker{}
+
+	ok, err := fw.attempt("some/path", "readdir", func() error {
+		return os.ErrPermission
+	})
+
+	if ok != false {
+		t.Fatalf("Operation should not have succeeded.")
+	}
+
+	pe, isPathError := err.(*PathError)
+	if isPathError != true {
+		t.Fatalf("Abort did not return a path-error: %v", err)
+	} else if pe.Filepath != "some/path" || pe.Operation != "readdir" || pe.Attempt != 1 {
+		t.Fatalf("Path-error not correct: %v", pe)
+	}
+}
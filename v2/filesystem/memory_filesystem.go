@@ -0,0 +1,644 @@
+package rifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errDirectoryNotEmpty = errors.New("directory not empty")
+)
+
+// memoryNode is one file or directory in a `MemoryFilesystem`.
+type memoryNode struct {
+	name    string
+	mode    os.FileMode
+	modTime time.Time
+
+	// children is only set for directories.
+	children map[string]*memoryNode
+
+	// data is only set for files.
+	data *SeekableBuffer
+}
+
+func newMemoryDirectoryNode(name string, perm os.FileMode) *memoryNode {
+	return &memoryNode{
+		name:     name,
+		mode:     os.ModeDir | perm.Perm(),
+		modTime:  time.Now(),
+		children: make(map[string]*memoryNode),
+	}
+}
+
+func newMemoryFileNode(name string, perm os.FileMode) *memoryNode {
+	return &memoryNode{
+		name:    name,
+		mode:    perm.Perm(),
+		modTime: time.Now(),
+		data:    NewSeekableBuffer(),
+	}
+}
+
+// IsDir returns true if a directory.
+func (mn *memoryNode) IsDir() bool {
+	return mn.mode.IsDir()
+}
+
+// Info returns a point-in-time `os.FileInfo` for the node.
+func (mn *memoryNode) Info() os.FileInfo {
+	sfi := &SimpleFileInfo{
+		filename: mn.name,
+		isDir:    mn.IsDir(),
+		mode:     mn.mode,
+		modTime:  mn.modTime,
+	}
+
+	if mn.data != nil {
+		sfi.size = int64(mn.data.Len())
+	}
+
+	return sfi
+}
+
+// sortedChildren returns the children ordered by name.
+func (mn *memoryNode) sortedChildren() []*memoryNode {
+	names := make([]string, 0, len(mn.children))
+	for name := range mn.children {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	children := make([]*memoryNode, len(names))
+	for i, name := range names {
+		children[i] = mn.children[name]
+	}
+
+	return children
+}
+
+// MemoryFilesystem is a `WritableFilesystem` that lives entirely in memory.
+// File content is stored in `SeekableBuffer`s and file information is exposed
+// as `SimpleFileInfo`s. Names follow `fs.FS` conventions: they are unrooted,
+// slash-separated, and "." is the root. It is safe for concurrent use,
+// including through open files.
+type MemoryFilesystem struct {
+	root *memoryNode
+
+	mutex sync.RWMutex
+}
+
+// NewMemoryFilesystem returns a new, empty MemoryFilesystem instance.
+func NewMemoryFilesystem() *MemoryFilesystem {
+	return &MemoryFilesystem{
+		root: newMemoryDirectoryNode(".", 0755),
+	}
+}
+
+// splitMemoryPath splits the given name into its parts. The root has no parts.
+func splitMemoryPath(name string) (parts []string, ok bool) {
+	if fs.ValidPath(name) == false {
+		return nil, false
+	} else if name == "." {
+		return nil, true
+	}
+
+	return strings.Split(name, "/"), true
+}
+
+// lookup finds the node for the given name. The caller must hold the lock.
+func (mfs *MemoryFilesystem) lookup(op, name string) (node *memoryNode, err error) {
+	parts, ok := splitMemoryPath(name)
+	if ok == false {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	node = mfs.root
+	for _, part := range parts {
+		if node.IsDir() == false {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		child, found := node.children[part]
+		if found == false {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+
+		node = child
+	}
+
+	return node, nil
+}
+
+// lookupParent finds the directory that would contain the given name and
+// returns it along with the base name. The caller must hold the lock.
+func (mfs *MemoryFilesystem) lookupParent(op, name string) (parent *memoryNode, filename string, err error) {
+	parts, ok := splitMemoryPath(name)
+	if ok == false || len(parts) == 0 {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	parent, err = mfs.lookup(op, path.Dir(name))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if parent.IsDir() == false {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return parent, parts[len(parts)-1], nil
+}
+
+// Open opens a file or directory for reading.
+func (mfs *MemoryFilesystem) Open(name string) (fs.File, error) {
+	mfs.mutex.RLock()
+	defer mfs.mutex.RUnlock()
+
+	node, err := mfs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	mf := &memoryFile{
+		mfs:        mfs,
+		node:       node,
+		name:       name,
+		isReadable: true,
+	}
+
+	return mf, nil
+}
+
+// OpenFile opens a file with the given `os.O_*` flags. The file is created
+// with the given permissions if `os.O_CREATE` is given and it doesn't already
+// exist.
+func (mfs *MemoryFilesystem) OpenFile(name string, flag int, perm os.FileMode) (ReadWriteSeekCloser, error) {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	parent, filename, err := mfs.lookupParent("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	node, found := parent.children[filename]
+	if found == true {
+		if flag&os.O_CREATE > 0 && flag&os.O_EXCL > 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+	} else {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+
+		node = newMemoryFileNode(filename, perm)
+		parent.children[filename] = node
+		parent.modTime = node.modTime
+	}
+
+	accessMode := flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+
+	mf := &memoryFile{
+		mfs:        mfs,
+		node:       node,
+		name:       name,
+		isReadable: accessMode == os.O_RDONLY || accessMode == os.O_RDWR,
+		isWritable: accessMode == os.O_WRONLY || accessMode == os.O_RDWR,
+		isAppend:   flag&os.O_APPEND > 0,
+	}
+
+	if node.IsDir() == true && mf.isWritable == true {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if flag&os.O_TRUNC > 0 && mf.isWritable == true {
+		err := node.data.Truncate(0)
+		if err != nil {
+			return nil, err
+		}
+
+		node.modTime = time.Now()
+	}
+
+	return mf, nil
+}
+
+// Stat returns information for the given path.
+func (mfs *MemoryFilesystem) Stat(name string) (os.FileInfo, error) {
+	mfs.mutex.RLock()
+	defer mfs.mutex.RUnlock()
+
+	node, err := mfs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Info(), nil
+}
+
+// Lstat returns information for the given path. There are no symlinks, so
+// this is the same as `Stat`.
+func (mfs *MemoryFilesystem) Lstat(name string) (os.FileInfo, error) {
+	mfs.mutex.RLock()
+	defer mfs.mutex.RUnlock()
+
+	node, err := mfs.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Info(), nil
+}
+
+// ReadDir returns the sorted entries of the given directory.
+func (mfs *MemoryFilesystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	mfs.mutex.RLock()
+	defer mfs.mutex.RUnlock()
+
+	node, err := mfs.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	} else if node.IsDir() == false {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	children := node.sortedChildren()
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = simpleDirEntry{
+			info: child.Info(),
+		}
+	}
+
+	return entries, nil
+}
+
+// Mkdir creates a single directory. The parent must already exist.
+func (mfs *MemoryFilesystem) Mkdir(name string, perm os.FileMode) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	parent, filename, err := mfs.lookupParent("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	if _, found := parent.children[filename]; found == true {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	node := newMemoryDirectoryNode(filename, perm)
+	parent.children[filename] = node
+	parent.modTime = node.modTime
+
+	return nil
+}
+
+// MkdirAll creates a directory and any missing parents.
+func (mfs *MemoryFilesystem) MkdirAll(name string, perm os.FileMode) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	parts, ok := splitMemoryPath(name)
+	if ok == false {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	node := mfs.root
+	for _, part := range parts {
+		child, found := node.children[part]
+		if found == false {
+			child = newMemoryDirectoryNode(part, perm)
+			node.children[part] = child
+			node.modTime = child.modTime
+		} else if child.IsDir() == false {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+
+		node = child
+	}
+
+	return nil
+}
+
+// WriteFile creates or replaces a file with the given content. The parent
+// directory must already exist.
+func (mfs *MemoryFilesystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	parent, filename, err := mfs.lookupParent("open", name)
+	if err != nil {
+		return err
+	}
+
+	node, found := parent.children[filename]
+	if found == true && node.IsDir() == true {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	} else if found == false {
+		node = newMemoryFileNode(filename, perm)
+		parent.children[filename] = node
+		parent.modTime = node.modTime
+	}
+
+	node.data = NewSeekableBufferWithBytes(data)
+	node.modTime = time.Now()
+
+	return nil
+}
+
+// Remove removes a file or an empty directory.
+func (mfs *MemoryFilesystem) Remove(name string) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	parent, filename, err := mfs.lookupParent("remove", name)
+	if err != nil {
+		return err
+	}
+
+	node, found := parent.children[filename]
+	if found == false {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	} else if node.IsDir() == true && len(node.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errDirectoryNotEmpty}
+	}
+
+	delete(parent.children, filename)
+	parent.modTime = time.Now()
+
+	return nil
+}
+
+// Rename moves a file or directory. An existing file at the destination is
+// replaced, as is an existing empty directory if a directory is being moved.
+func (mfs *MemoryFilesystem) Rename(oldName, newName string) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	oldParent, oldFilename, err := mfs.lookupParent("rename", oldName)
+	if err != nil {
+		return err
+	}
+
+	node, found := oldParent.children[oldFilename]
+	if found == false {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+
+	newParent, newFilename, err := mfs.lookupParent("rename", newName)
+	if err != nil {
+		return err
+	}
+
+	if node.IsDir() == true && (newName == oldName || strings.HasPrefix(newName, oldName+"/") == true) {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+
+	if existing, found := newParent.children[newFilename]; found == true && existing != node {
+		if existing.IsDir() != node.IsDir() {
+			return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+		} else if existing.IsDir() == true && len(existing.children) > 0 {
+			return &fs.PathError{Op: "rename", Path: newName, Err: errDirectoryNotEmpty}
+		}
+	}
+
+	now := time.Now()
+
+	delete(oldParent.children, oldFilename)
+	oldParent.modTime = now
+
+	node.name = newFilename
+	newParent.children[newFilename] = node
+	newParent.modTime = now
+
+	return nil
+}
+
+// Chmod changes the permission bits of a file or directory.
+func (mfs *MemoryFilesystem) Chmod(name string, mode os.FileMode) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	node, err := mfs.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	node.mode = node.mode.Type() | mode.Perm()
+
+	return nil
+}
+
+// Chtimes changes the modification time of a file or directory. Access times
+// are not tracked.
+func (mfs *MemoryFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	mfs.mutex.Lock()
+	defer mfs.mutex.Unlock()
+
+	node, err := mfs.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	node.modTime = mtime
+
+	return nil
+}
+
+// memoryFile is an open file or directory in a `MemoryFilesystem`. It has its
+// own position.
+type memoryFile struct {
+	mfs  *MemoryFilesystem
+	node *memoryNode
+	name string
+
+	isReadable bool
+	isWritable bool
+	isAppend   bool
+
+	position int64
+	closed   bool
+
+	// dirPosition is the number of entries already returned by ReadDir.
+	dirPosition int
+}
+
+// checkFile returns an error if the file can not be used for the given
+// content operation. The caller must hold the lock.
+func (mf *memoryFile) checkFile(op string) error {
+	if mf.closed == true {
+		return os.ErrClosed
+	} else if mf.node.IsDir() == true {
+		return &fs.PathError{Op: op, Path: mf.name, Err: fs.ErrInvalid}
+	}
+
+	return nil
+}
+
+// Stat returns information for the file.
+func (mf *memoryFile) Stat() (os.FileInfo, error) {
+	mf.mfs.mutex.RLock()
+	defer mf.mfs.mutex.RUnlock()
+
+	if mf.closed == true {
+		return nil, os.ErrClosed
+	}
+
+	return mf.node.Info(), nil
+}
+
+// Read reads from the current position.
+func (mf *memoryFile) Read(p []byte) (n int, err error) {
+	// The buffer has its own position that we have to move, so this is not a
+	// read-only operation.
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if err := mf.checkFile("read"); err != nil {
+		return 0, err
+	} else if mf.isReadable == false {
+		return 0, &fs.PathError{Op: "read", Path: mf.name, Err: fs.ErrPermission}
+	}
+
+	_, err = mf.node.data.Seek(mf.position, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = mf.node.data.Read(p)
+	mf.position += int64(n)
+
+	return n, err
+}
+
+// Write writes at the current position, or at the end if opened for appending.
+func (mf *memoryFile) Write(p []byte) (n int, err error) {
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if err := mf.checkFile("write"); err != nil {
+		return 0, err
+	} else if mf.isWritable == false {
+		return 0, &fs.PathError{Op: "write", Path: mf.name, Err: fs.ErrPermission}
+	}
+
+	if mf.isAppend == true {
+		mf.position = int64(mf.node.data.Len())
+	}
+
+	_, err = mf.node.data.Seek(mf.position, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = mf.node.data.Write(p)
+	mf.position += int64(n)
+
+	mf.node.modTime = time.Now()
+
+	return n, err
+}
+
+// Seek moves the current position.
+func (mf *memoryFile) Seek(offset int64, whence int) (int64, error) {
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if err := mf.checkFile("seek"); err != nil {
+		return 0, err
+	}
+
+	position, err := CalculateSeek(mf.position, offset, whence, int64(mf.node.data.Len()))
+	if err != nil {
+		return 0, err
+	}
+
+	mf.position = position
+
+	return position, nil
+}
+
+// Truncate changes the size of the file. The position is not changed.
+func (mf *memoryFile) Truncate(size int64) error {
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if err := mf.checkFile("truncate"); err != nil {
+		return err
+	} else if mf.isWritable == false {
+		return &fs.PathError{Op: "truncate", Path: mf.name, Err: fs.ErrPermission}
+	}
+
+	err := mf.node.data.Truncate(size)
+	if err != nil {
+		return err
+	}
+
+	mf.node.modTime = time.Now()
+
+	return nil
+}
+
+// ReadDir returns up to `count` entries, or all remaining entries if `count`
+// is not positive.
+func (mf *memoryFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if mf.closed == true {
+		return nil, os.ErrClosed
+	} else if mf.node.IsDir() == false {
+		return nil, &fs.PathError{Op: "readdir", Path: mf.name, Err: fs.ErrInvalid}
+	}
+
+	children := mf.node.sortedChildren()
+	if mf.dirPosition < len(children) {
+		children = children[mf.dirPosition:]
+	} else {
+		children = nil
+	}
+
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		} else if len(children) > count {
+			children = children[:count]
+		}
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = simpleDirEntry{
+			info: child.Info(),
+		}
+	}
+
+	mf.dirPosition += len(entries)
+
+	return entries, nil
+}
+
+// Close closes the file.
+func (mf *memoryFile) Close() error {
+	mf.mfs.mutex.Lock()
+	defer mf.mfs.mutex.Unlock()
+
+	if mf.closed == true {
+		return os.ErrClosed
+	}
+
+	mf.closed = true
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package rifs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestMemoryFilesystem_WriteFile(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb/file1", []byte("abcdef"), 0644)
+	log.PanicIf(err)
+
+	fi, err := mfs.Stat("aa/bb/file1")
+	log.PanicIf(err)
+
+	if fi.Name() != "file1" {
+		t.Fatalf("Name not correct: [%s]", fi.Name())
+	} else if fi.Size() != 6 {
+		t.Fatalf("Size not correct: (%d)", fi.Size())
+	} else if fi.Mode() != 0644 {
+		t.Fatalf("Mode not correct: %v", fi.Mode())
+	} else if fi.IsDir() != false {
+		t.Fatalf("File looks like a directory.")
+	}
+
+	f, err := mfs.Open("aa/bb/file1")
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	_, err = f.(io.Seeker).Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(f)
+	log.PanicIf(err)
+
+	if string(recovered) != "cdef" {
+		t.Fatalf("Content not correct: [%s]", string(recovered))
+	}
+}
+
+func TestMemoryFilesystem_WriteFile_NoParent(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.WriteFile("aa/file1", []byte("abc"), 0644)
+	if os.IsNotExist(err) != true {
+		t.Fatalf("Expected not-exist error: [%v]", err)
+	}
+}
+
+func TestMemoryFilesystem_ReadDir(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/cc", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	entries, err := mfs.ReadDir("aa")
+	log.PanicIf(err)
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+
+	if reflect.DeepEqual(names, []string{"bb", "cc"}) != true {
+		t.Fatalf("Entries not correct: %v", names)
+	} else if entries[1].IsDir() != true {
+		t.Fatalf("Directory entry not correct.")
+	}
+}
+
+func TestMemoryFilesystem_FsCompliance(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb/file2", []byte("defg"), 0644)
+	log.PanicIf(err)
+
+	err = fstest.TestFS(mfs, "aa/file1", "aa/bb/file2")
+	log.PanicIf(err)
+}
+
+func TestMemoryFilesystem_OpenFile(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	f, err := mfs.OpenFile("file1", os.O_CREATE|os.O_RDWR, 0600)
+	log.PanicIf(err)
+
+	_, err = f.Write([]byte("abcdef"))
+	log.PanicIf(err)
+
+	_, err = f.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = f.Write([]byte("XY"))
+	log.PanicIf(err)
+
+	_, err = f.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(f)
+	log.PanicIf(err)
+
+	if string(recovered) != "abXYef" {
+		t.Fatalf("Content not correct: [%s]", string(recovered))
+	}
+
+	err = f.Close()
+	log.PanicIf(err)
+
+	// Append.
+
+	f, err = mfs.OpenFile("file1", os.O_WRONLY|os.O_APPEND, 0)
+	log.PanicIf(err)
+
+	_, err = f.Write([]byte("gh"))
+	log.PanicIf(err)
+
+	_, err = f.Read(make([]byte, 1))
+	if os.IsPermission(err) != true {
+		t.Fatalf("Expected permission error for read on write-only file: [%v]", err)
+	}
+
+	f.Close()
+
+	// Truncate.
+
+	f, err = mfs.OpenFile("file1", os.O_RDWR|os.O_TRUNC, 0)
+	log.PanicIf(err)
+
+	fi, err := mfs.Stat("file1")
+	log.PanicIf(err)
+
+	if fi.Size() != 0 {
+		t.Fatalf("File was not truncated: (%d)", fi.Size())
+	} else if fi.Mode() != 0600 {
+		t.Fatalf("Mode not correct: %v", fi.Mode())
+	}
+
+	f.Close()
+
+	// Exclusive.
+
+	_, err = mfs.OpenFile("file1", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) != true {
+		t.Fatalf("Expected exist error: [%v]", err)
+	}
+
+	_, err = mfs.OpenFile("file2", os.O_RDONLY, 0)
+	if os.IsNotExist(err) != true {
+		t.Fatalf("Expected not-exist error: [%v]", err)
+	}
+}
+
+func TestMemoryFilesystem_Rename(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.Mkdir("cc", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb/file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.Rename("aa/bb", "cc/dd")
+	log.PanicIf(err)
+
+	if DoesExistWithFilesystem(mfs, "aa/bb") != false {
+		t.Fatalf("Old path still exists.")
+	}
+
+	fi, err := mfs.Stat("cc/dd/file1")
+	log.PanicIf(err)
+
+	if fi.Size() != 3 {
+		t.Fatalf("Moved file not correct: (%d)", fi.Size())
+	}
+
+	fi, err = mfs.Stat("cc/dd")
+	log.PanicIf(err)
+
+	if fi.Name() != "dd" {
+		t.Fatalf("Moved directory name not correct: [%s]", fi.Name())
+	}
+
+	err = mfs.Rename("cc", "cc/dd/ee")
+	if err == nil {
+		t.Fatalf("Expected error moving a directory into itself.")
+	}
+
+	// Replace a file.
+
+	err = mfs.WriteFile("cc/file2", []byte("defgh"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.Rename("cc/file2", "cc/dd/file1")
+	log.PanicIf(err)
+
+	fi, err = mfs.Stat("cc/dd/file1")
+	log.PanicIf(err)
+
+	if fi.Size() != 5 {
+		t.Fatalf("Replaced file not correct: (%d)", fi.Size())
+	}
+}
+
+func TestMemoryFilesystem_Remove(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.Remove("aa")
+	if err == nil {
+		t.Fatalf("Expected error removing a non-empty directory.")
+	}
+
+	err = mfs.Remove("aa/file1")
+	log.PanicIf(err)
+
+	err = mfs.Remove("aa")
+	log.PanicIf(err)
+
+	err = mfs.Remove("aa")
+	if os.IsNotExist(err) != true {
+		t.Fatalf("Expected not-exist error: [%v]", err)
+	}
+}
+
+func TestMemoryFilesystem_ChmodChtimes(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.WriteFile("file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.Chmod("file1", 0400)
+	log.PanicIf(err)
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	err = mfs.Chtimes("file1", mtime, mtime)
+	log.PanicIf(err)
+
+	fi, err := mfs.Stat("file1")
+	log.PanicIf(err)
+
+	if fi.Mode() != 0400 {
+		t.Fatalf("Mode not correct: %v", fi.Mode())
+	} else if fi.ModTime().Equal(mtime) != true {
+		t.Fatalf("Modification-time not correct: %v", fi.ModTime())
+	}
+}
+
+func TestMemoryFilesystem_Concurrent(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			dirPath := fmt.Sprintf("dir%d", i)
+
+			err := mfs.Mkdir(dirPath, 0755)
+			log.PanicIf(err)
+
+			f, err := mfs.OpenFile(path.Join(dirPath, "file"), os.O_CREATE|os.O_RDWR, 0644)
+			log.PanicIf(err)
+
+			defer f.Close()
+
+			for j := 0; j < 100; j++ {
+				_, err := f.Write([]byte("abc"))
+				log.PanicIf(err)
+
+				_, err = mfs.ReadDir(".")
+				log.PanicIf(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		fi, err := mfs.Stat(fmt.Sprintf("dir%d/file", i))
+		log.PanicIf(err)
+
+		if fi.Size() != 300 {
+			t.Fatalf("File (%d) size not correct: (%d)", i, fi.Size())
+		}
+	}
+}
+
+func TestMemoryFilesystem_ListFiles(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb/image.png", []byte("\x89PNG\x0D\x0A\x1A\x0A"), 0644)
+	log.PanicIf(err)
+
+	options := ListFilesOptions{
+		Filesystem: mfs,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(".", options)
+	log.PanicIf(err)
+
+	visited := make([]string, 0)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			visited = append(visited, event.File.Filepath)
+		}
+	}
+
+	expected := []string{
+		"aa",
+		"aa/bb",
+		"aa/bb/image.png",
+	}
+
+	if reflect.DeepEqual(visited, expected) != true {
+		t.Fatalf("Visited paths not correct: %v", visited)
+	}
+}
@@ -0,0 +1,379 @@
+package rifs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrOverlayCanNotShrink is returned when committing a shrunken overlay to
+	// a target that can not be truncated.
+	ErrOverlayCanNotShrink = errors.New("commit target can not be truncated")
+)
+
+// DirtyRange is a range that was written or truncated in an overlay.
+type DirtyRange struct {
+	Offset int64
+	Length int64
+}
+
+// String returns a descriptive string.
+func (dr DirtyRange) String() string {
+	return fmt.Sprintf("DirtyRange<OFFSET=(%d) LENGTH=(%d)>", dr.Offset, dr.Length)
+}
+
+// end returns the offset just past the range.
+func (dr DirtyRange) end() int64 {
+	return dr.Offset + dr.Length
+}
+
+// overlayRange is a dirty range along with its data, which is always exactly
+// as long as the range.
+type overlayRange struct {
+	DirtyRange
+
+	data *SeekableBuffer
+}
+
+// OverlayReadWriteSeeker is a copy-on-write `ReadWriteSeeker` over a base
+// `ReaderAt`. Reads go through to the base except where there were writes,
+// which are kept in memory. The base is never written unless `Commit` is
+// called. Any region that is exposed by extending the stream (by writing past
+// the end or truncating to a larger size) reads as zeros.
+//
+// Every dirty range has its own `SeekableBuffer`, so memory use follows how
+// much was written rather than where.
+type OverlayReadWriteSeeker struct {
+	base     io.ReaderAt
+	baseSize int64
+
+	// dirty is sorted and never has overlapping or adjacent ranges.
+	dirty []*overlayRange
+
+	size     int64
+	position int64
+}
+
+// NewOverlayReadWriteSeeker returns a new OverlayReadWriteSeeker instance.
+func NewOverlayReadWriteSeeker(base io.ReaderAt, baseSize int64) *OverlayReadWriteSeeker {
+	return &OverlayReadWriteSeeker{
+		base:     base,
+		baseSize: baseSize,
+		dirty:    make([]*overlayRange, 0),
+		size:     baseSize,
+	}
+}
+
+// Size returns the current size of the stream.
+func (orws *OverlayReadWriteSeeker) Size() int64 {
+	return orws.size
+}
+
+// DirtyRanges returns the ranges that differ from the base, in order.
+// Truncations below the size of the base are not included but are reflected
+// by `Size`.
+func (orws *OverlayReadWriteSeeker) DirtyRanges() []DirtyRange {
+	dirty := make([]DirtyRange, len(orws.dirty))
+	for i, or := range orws.dirty {
+		dirty[i] = or.DirtyRange
+	}
+
+	return dirty
+}
+
+// IsDirty returns true if the stream differs from the base.
+func (orws *OverlayReadWriteSeeker) IsDirty() bool {
+	return len(orws.dirty) > 0 || orws.size != orws.baseSize
+}
+
+// addDirty records a range as dirty, merging it with its neighbors, and
+// returns the range that now covers it. Whatever part of the range was not
+// already dirty reads as zeros until it is written.
+func (orws *OverlayReadWriteSeeker) addDirty(dr DirtyRange) (or *overlayRange, err error) {
+	// The first range that ends at or after the new one starts.
+	i := sort.Search(len(orws.dirty), func(i int) bool {
+		return orws.dirty[i].end() >= dr.Offset
+	})
+
+	// Find everything that overlaps or touches.
+
+	j := i
+	for ; j < len(orws.dirty) && orws.dirty[j].Offset <= dr.end(); j++ {
+		if orws.dirty[j].Offset < dr.Offset {
+			dr.Length += dr.Offset - orws.dirty[j].Offset
+			dr.Offset = orws.dirty[j].Offset
+		}
+
+		if orws.dirty[j].end() > dr.end() {
+			dr.Length = orws.dirty[j].end() - dr.Offset
+		}
+	}
+
+	// Reuse the buffer of the first range if it starts where the merged
+	// range does, which is the case when appending to it.
+
+	absorbed := orws.dirty[i:j]
+
+	or = &overlayRange{
+		DirtyRange: dr,
+	}
+
+	if len(absorbed) > 0 && absorbed[0].Offset == dr.Offset {
+		or.data = absorbed[0].data
+		absorbed = absorbed[1:]
+	} else {
+		or.data = NewSeekableBuffer()
+	}
+
+	err = or.data.Truncate(dr.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, neighbor := range absorbed {
+		_, err := or.data.WriteAt(neighbor.data.Bytes(), neighbor.Offset-dr.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dirty := make([]*overlayRange, 0, len(orws.dirty)-(j-i)+1)
+	dirty = append(dirty, orws.dirty[:i]...)
+	dirty = append(dirty, or)
+	dirty = append(dirty, orws.dirty[j:]...)
+
+	orws.dirty = dirty
+
+	return or, nil
+}
+
+// extend grows the stream to the given size with zeros.
+func (orws *OverlayReadWriteSeeker) extend(size int64) (err error) {
+	if size <= orws.size {
+		return nil
+	}
+
+	_, err = orws.addDirty(DirtyRange{Offset: orws.size, Length: size - orws.size})
+	if err != nil {
+		return err
+	}
+
+	orws.size = size
+
+	return nil
+}
+
+// ReadAt reads the base where it is clean and the overlay where it is dirty.
+// A short read returns `io.EOF`.
+func (orws *OverlayReadWriteSeeker) ReadAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	} else if offset >= orws.size {
+		return 0, io.EOF
+	}
+
+	end := offset + int64(len(p))
+	if end > orws.size {
+		end = orws.size
+	}
+
+	// The first dirty range that ends after the offset.
+	i := sort.Search(len(orws.dirty), func(i int) bool {
+		return orws.dirty[i].end() > offset
+	})
+
+	for current := offset; current < end; {
+		chunkEnd := end
+
+		source := orws.base
+		sourceOffset := current
+
+		if i < len(orws.dirty) && orws.dirty[i].Offset <= current {
+			source = orws.dirty[i].data
+			sourceOffset = current - orws.dirty[i].Offset
+
+			if orws.dirty[i].end() < chunkEnd {
+				chunkEnd = orws.dirty[i].end()
+			}
+
+			i++
+		} else if i < len(orws.dirty) && orws.dirty[i].Offset < chunkEnd {
+			chunkEnd = orws.dirty[i].Offset
+		}
+
+		buffer := p[current-offset : chunkEnd-offset]
+
+		readCount, err := source.ReadAt(buffer, sourceOffset)
+		n += readCount
+
+		if readCount < len(buffer) {
+			if err == nil || err == io.EOF {
+				log.Panicf("source is shorter than expected at (%d)", current+int64(readCount))
+			}
+
+			log.Panic(err)
+		}
+
+		current = chunkEnd
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Read reads from the current position.
+func (orws *OverlayReadWriteSeeker) Read(p []byte) (n int, err error) {
+	n, err = orws.ReadAt(p, orws.position)
+	orws.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// WriteAt writes to the overlay without moving the position.
+func (orws *OverlayReadWriteSeeker) WriteAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	err = orws.extend(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	or, err := orws.addDirty(DirtyRange{Offset: offset, Length: int64(len(p))})
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = or.data.WriteAt(p, offset-or.Offset)
+	if err != nil {
+		return 0, err
+	}
+
+	if end := offset + int64(n); end > orws.size {
+		orws.size = end
+	}
+
+	return n, nil
+}
+
+// Write writes to the overlay at the current position.
+func (orws *OverlayReadWriteSeeker) Write(p []byte) (n int, err error) {
+	n, err = orws.WriteAt(p, orws.position)
+	orws.position += int64(n)
+
+	return n, err
+}
+
+// Seek seeks within the stream.
+func (orws *OverlayReadWriteSeeker) Seek(offset int64, whence int) (newOffset int64, err error) {
+	position, err := CalculateSeek(orws.position, offset, whence, orws.size)
+	if err != nil {
+		return orws.position, err
+	}
+
+	orws.position = position
+
+	return orws.position, nil
+}
+
+// Truncate shrinks or extends (with zeros) the stream. The position is not
+// changed.
+func (orws *OverlayReadWriteSeeker) Truncate(size int64) (err error) {
+	if size < 0 {
+		return ErrNegativeOffset
+	} else if size >= orws.size {
+		return orws.extend(size)
+	}
+
+	orws.size = size
+
+	// Drop or trim the ranges past the end.
+
+	i := sort.Search(len(orws.dirty), func(i int) bool {
+		return orws.dirty[i].end() > size
+	})
+
+	if i < len(orws.dirty) && orws.dirty[i].Offset < size {
+		or := orws.dirty[i]
+
+		or.Length = size - or.Offset
+
+		err = or.data.Truncate(or.Length)
+		if err != nil {
+			return err
+		}
+
+		i++
+	}
+
+	orws.dirty = orws.dirty[:i]
+
+	return nil
+}
+
+// Render writes the whole stream to the given writer. The position is not
+// changed.
+func (orws *OverlayReadWriteSeeker) Render(w io.Writer) (n int64, err error) {
+	sr := io.NewSectionReader(orws, 0, orws.size)
+	return io.Copy(w, sr)
+}
+
+// Commit writes the dirty ranges to the given target and truncates it if the
+// stream is smaller than the base. The overlay is then emptied, so the base
+// must reflect the target afterward (e.g. they are the same file).
+func (orws *OverlayReadWriteSeeker) Commit(target io.WriterAt) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	// Make sure that we can finish before changing anything.
+
+	var t truncater
+	if orws.size < orws.baseSize {
+		var ok bool
+
+		t, ok = target.(truncater)
+		if ok == false {
+			log.Panic(ErrOverlayCanNotShrink)
+		}
+	}
+
+	for _, or := range orws.dirty {
+		_, err := target.WriteAt(or.data.Bytes(), or.Offset)
+		log.PanicIf(err)
+	}
+
+	if t != nil {
+		err := t.Truncate(orws.size)
+		log.PanicIf(err)
+	}
+
+	orws.baseSize = orws.size
+	orws.dirty = make([]*overlayRange, 0)
+
+	return nil
+}
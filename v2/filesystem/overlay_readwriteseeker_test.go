@@ -0,0 +1,315 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+func TestOverlayReadWriteSeeker_Write(t *testing.T) {
+	base := []byte("0123456789")
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+
+	_, err := orws.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = orws.Write([]byte("ab"))
+	log.PanicIf(err)
+
+	_, err = orws.WriteAt([]byte("c"), 4)
+	log.PanicIf(err)
+
+	_, err = orws.WriteAt([]byte("X"), 8)
+	log.PanicIf(err)
+
+	_, err = orws.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(orws)
+	log.PanicIf(err)
+
+	if string(recovered) != "01abc567X9" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	}
+
+	// The adjacent writes were merged.
+
+	expected := []DirtyRange{
+		{Offset: 2, Length: 3},
+		{Offset: 8, Length: 1},
+	}
+
+	if reflect.DeepEqual(orws.DirtyRanges(), expected) != true {
+		t.Fatalf("Dirty ranges not correct: %v", orws.DirtyRanges())
+	} else if string(base) != "0123456789" {
+		t.Fatalf("Base was modified.")
+	}
+}
+
+func TestOverlayReadWriteSeeker_Write_PastEnd(t *testing.T) {
+	base := []byte("0123456789")
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+
+	// Shrink and then write past the end. The gap must not show the base.
+
+	err := orws.Truncate(4)
+	log.PanicIf(err)
+
+	_, err = orws.WriteAt([]byte("Z"), 7)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	_, err = orws.Render(b)
+	log.PanicIf(err)
+
+	expected := []byte("0123\000\000\000Z")
+
+	if bytes.Equal(b.Bytes(), expected) != true {
+		t.Fatalf("Rendered data not correct: %v", b.Bytes())
+	}
+
+	expectedRanges := []DirtyRange{
+		{Offset: 4, Length: 4},
+	}
+
+	if reflect.DeepEqual(orws.DirtyRanges(), expectedRanges) != true {
+		t.Fatalf("Dirty ranges not correct: %v", orws.DirtyRanges())
+	}
+}
+
+func TestOverlayReadWriteSeeker_Write_LargeBase(t *testing.T) {
+	// The base is never read, so it doesn't need to actually be this large.
+
+	baseSize := int64(4 * 1024 * 1024 * 1024)
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(nil), baseSize)
+
+	_, err := orws.WriteAt([]byte("a"), baseSize-1)
+	log.PanicIf(err)
+
+	err = orws.Truncate(baseSize + 1)
+	log.PanicIf(err)
+
+	_, err = orws.WriteAt([]byte("b"), 10)
+	log.PanicIf(err)
+
+	buffered := 0
+	for _, or := range orws.dirty {
+		buffered += or.data.Len()
+	}
+
+	if buffered != 3 {
+		t.Fatalf("Buffered size not correct: (%d)", buffered)
+	}
+
+	p := make([]byte, 2)
+
+	_, err = orws.ReadAt(p, baseSize-1)
+	log.PanicIf(err)
+
+	if string(p) != "a\x00" {
+		t.Fatalf("Data not correct: %v", p)
+	}
+
+	expected := []DirtyRange{
+		{Offset: 10, Length: 1},
+		{Offset: baseSize - 1, Length: 2},
+	}
+
+	if reflect.DeepEqual(orws.DirtyRanges(), expected) != true {
+		t.Fatalf("Dirty ranges not correct: %v", orws.DirtyRanges())
+	}
+}
+
+func TestOverlayReadWriteSeeker_Truncate(t *testing.T) {
+	base := []byte("0123456789")
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+
+	_, err := orws.WriteAt([]byte("abcd"), 4)
+	log.PanicIf(err)
+
+	err = orws.Truncate(6)
+	log.PanicIf(err)
+
+	err = orws.Truncate(9)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	_, err = orws.Render(b)
+	log.PanicIf(err)
+
+	expected := []byte("0123ab\000\000\000")
+
+	if bytes.Equal(b.Bytes(), expected) != true {
+		t.Fatalf("Rendered data not correct: %v", b.Bytes())
+	} else if orws.Size() != 9 {
+		t.Fatalf("Size not correct: (%d)", orws.Size())
+	}
+}
+
+func TestOverlayReadWriteSeeker_Commit(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file")
+
+	err = ioutil.WriteFile(filepath, []byte("0123456789"), 0644)
+	log.PanicIf(err)
+
+	f, err := os.OpenFile(filepath, os.O_RDWR, 0)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	orws := NewOverlayReadWriteSeeker(f, 10)
+
+	_, err = orws.WriteAt([]byte("ab"), 1)
+	log.PanicIf(err)
+
+	err = orws.Truncate(6)
+	log.PanicIf(err)
+
+	// Nothing happens to the file until we commit.
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "0123456789" {
+		t.Fatalf("File was changed early: [%s]", string(data))
+	}
+
+	err = orws.Commit(f)
+	log.PanicIf(err)
+
+	data, err = ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if string(data) != "0ab345" {
+		t.Fatalf("Committed data not correct: [%s]", string(data))
+	} else if orws.IsDirty() != false {
+		t.Fatalf("Should not be dirty after commit.")
+	}
+
+	// It keeps working against the updated file.
+
+	_, err = orws.WriteAt([]byte("c"), 5)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	_, err = orws.Render(b)
+	log.PanicIf(err)
+
+	if b.String() != "0ab34c" {
+		t.Fatalf("Data after commit not correct: [%s]", b.String())
+	}
+}
+
+func TestOverlayReadWriteSeeker_Commit_CanNotShrink(t *testing.T) {
+	base := []byte("0123456789")
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+
+	err := orws.Truncate(5)
+	log.PanicIf(err)
+
+	err = orws.Commit(NewSeekableBuffer())
+	log.PanicIf(err)
+
+	// A target without Truncate.
+
+	orws = NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+
+	err = orws.Truncate(5)
+	log.PanicIf(err)
+
+	_, err = orws.WriteAt([]byte("ab"), 1)
+	log.PanicIf(err)
+
+	target := NewSeekableBufferWithBytes(base)
+
+	err = orws.Commit(struct{ io.WriterAt }{target})
+	if log.Is(err, ErrOverlayCanNotShrink) != true {
+		t.Fatalf("Expected can-not-shrink error: %v", err)
+	}
+
+	// Nothing was committed.
+
+	if string(target.Bytes()) != "0123456789" {
+		t.Fatalf("Target was modified: [%s]", string(target.Bytes()))
+	} else if orws.IsDirty() != true {
+		t.Fatalf("Overlay should still be dirty.")
+	}
+}
+
+// TestOverlayReadWriteSeeker_MatchesSeekableBuffer does the same random
+// operations on an overlay and on a buffer with the same initial data.
+func TestOverlayReadWriteSeeker_MatchesSeekableBuffer(t *testing.T) {
+	base := make([]byte, 200)
+	for i := range base {
+		base[i] = byte(i)
+	}
+
+	orws := NewOverlayReadWriteSeeker(bytes.NewReader(base), int64(len(base)))
+	sb := NewSeekableBufferWithBytes(base)
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		offset := r.Int63n(int64(sb.Len()) + 20)
+
+		operation := r.Intn(3)
+
+		if operation == 0 {
+			p := make([]byte, r.Intn(16))
+			r.Read(p)
+
+			_, err := orws.WriteAt(p, offset)
+			log.PanicIf(err)
+
+			_, err = sb.WriteAt(p, offset)
+			log.PanicIf(err)
+		} else if operation == 1 {
+			err := orws.Truncate(offset)
+			log.PanicIf(err)
+
+			err = sb.Truncate(offset)
+			log.PanicIf(err)
+		} else {
+			length := r.Intn(32)
+
+			buffer1 := make([]byte, length)
+			buffer2 := make([]byte, length)
+
+			n1, err1 := orws.ReadAt(buffer1, offset)
+			n2, err2 := sb.ReadAt(buffer2, offset)
+
+			if n1 != n2 || err1 != err2 || bytes.Equal(buffer1, buffer2) != true {
+				t.Fatalf("Read (%d) differs: (%d) [%v] != (%d) [%v]", i, n1, err1, n2, err2)
+			}
+		}
+	}
+
+	b := new(bytes.Buffer)
+
+	_, err := orws.Render(b)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), sb.Bytes()) != true {
+		t.Fatalf("Final content differs.")
+	}
+
+	err = ritesting.CheckReaderAt(orws, sb.Bytes())
+	log.PanicIf(err)
+}
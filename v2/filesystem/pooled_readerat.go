@@ -0,0 +1,184 @@
+package rifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	defaultPooledReaderAtMaxHandles = 4
+)
+
+var (
+	// ErrPooledReaderAtClosed is returned when a `PooledReaderAt` is used
+	// after it was closed.
+	ErrPooledReaderAtClosed = errors.New("pooled reader-at closed")
+)
+
+// ReadSeekerOpener opens a new, independent handle to the same resource.
+type ReadSeekerOpener func() (rs io.ReadSeeker, err error)
+
+// PooledReaderAt is a ReaderAt that keeps a pool of independent handles to
+// the same resource so that concurrent reads do not have to wait on each
+// other. Handles are opened as needed, up to a maximum.
+type PooledReaderAt struct {
+	opener ReadSeekerOpener
+
+	// semaphore limits the number of handles that are in use.
+	semaphore chan struct{}
+
+	maxHandles int
+
+	mutex sync.Mutex
+	idle  []io.ReadSeeker
+	all   []io.ReadSeeker
+
+	// reserved is the number of handles that are being opened.
+	reserved int
+
+	closed bool
+}
+
+// NewPooledReaderAt returns a new PooledReaderAt instance. `maxHandles`
+// defaults to four.
+func NewPooledReaderAt(opener ReadSeekerOpener, maxHandles int) *PooledReaderAt {
+	if maxHandles <= 0 {
+		maxHandles = defaultPooledReaderAtMaxHandles
+	}
+
+	return &PooledReaderAt{
+		opener:     opener,
+		maxHandles: maxHandles,
+		semaphore:  make(chan struct{}, maxHandles),
+		idle:       make([]io.ReadSeeker, 0),
+		all:        make([]io.ReadSeeker, 0),
+	}
+}
+
+// HandleCount returns the number of handles that have been opened.
+func (pra *PooledReaderAt) HandleCount() int {
+	pra.mutex.Lock()
+	defer pra.mutex.Unlock()
+
+	return len(pra.all)
+}
+
+// acquire returns an idle handle or opens a new one. Handles are opened
+// without holding the lock so that a slow open doesn't hold up reads that can
+// use an idle handle.
+func (pra *PooledReaderAt) acquire() (rs io.ReadSeeker, err error) {
+	pra.mutex.Lock()
+
+	if pra.closed == true {
+		pra.mutex.Unlock()
+		return nil, ErrPooledReaderAtClosed
+	}
+
+	if len(pra.idle) > 0 {
+		rs = pra.idle[len(pra.idle)-1]
+		pra.idle = pra.idle[:len(pra.idle)-1]
+
+		pra.mutex.Unlock()
+		return rs, nil
+	}
+
+	// The semaphore guarantees that there's room.
+	if len(pra.all)+pra.reserved >= pra.maxHandles {
+		pra.mutex.Unlock()
+		log.Panicf("no handle available: (%d) open and (%d) reserved", len(pra.all), pra.reserved)
+	}
+
+	pra.reserved++
+	pra.mutex.Unlock()
+
+	rs, err = pra.opener()
+
+	pra.mutex.Lock()
+	defer pra.mutex.Unlock()
+
+	pra.reserved--
+
+	if err != nil {
+		return nil, err
+	}
+
+	// We were closed while opening.
+	if pra.closed == true {
+		if c, ok := rs.(io.Closer); ok == true {
+			c.Close()
+		}
+
+		return nil, ErrPooledReaderAtClosed
+	}
+
+	pra.all = append(pra.all, rs)
+
+	return rs, nil
+}
+
+// release returns a handle to the pool.
+func (pra *PooledReaderAt) release(rs io.ReadSeeker) {
+	pra.mutex.Lock()
+	defer pra.mutex.Unlock()
+
+	pra.idle = append(pra.idle, rs)
+}
+
+// ReadAt reads using whichever handle is free. Files are read with `pread`.
+func (pra *PooledReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	pra.semaphore <- struct{}{}
+	defer func() {
+		<-pra.semaphore
+	}()
+
+	rs, err := pra.acquire()
+	if err == ErrPooledReaderAtClosed {
+		return 0, err
+	}
+
+	log.PanicIf(err)
+
+	defer pra.release(rs)
+
+	if f, ok := rs.(*os.File); ok == true {
+		return f.ReadAt(p, offset)
+	}
+
+	return readSeekerReadAt(rs, p, offset)
+}
+
+// Close closes every handle that is an `io.Closer`. It must not be called
+// while reads are in progress.
+func (pra *PooledReaderAt) Close() (err error) {
+	pra.mutex.Lock()
+	defer pra.mutex.Unlock()
+
+	if pra.closed == true {
+		return nil
+	}
+
+	pra.closed = true
+
+	for _, rs := range pra.all {
+		if c, ok := rs.(io.Closer); ok == true {
+			if closeErr := c.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	pra.all = nil
+	pra.idle = nil
+
+	return err
+}
@@ -0,0 +1,223 @@
+package rifs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+func TestPooledReaderAt_ReadAt_Concurrent(t *testing.T) {
+	b := make([]byte, 1000)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	opener := func() (io.ReadSeeker, error) {
+		return bytes.NewReader(b), nil
+	}
+
+	pra := NewPooledReaderAt(opener, 3)
+
+	defer pra.Close()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				offset := (i*100 + j) % 990
+				fragment := make([]byte, 10)
+
+				_, err := pra.ReadAt(fragment, int64(offset))
+				log.PanicIf(err)
+
+				if bytes.Equal(fragment, b[offset:offset+10]) != true {
+					t.Errorf("Read at (%d) not correct.", offset)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if count := pra.HandleCount(); count < 1 || count > 3 {
+		t.Fatalf("Handle count not correct: (%d)", count)
+	}
+}
+
+func TestPooledReaderAt_ReadAt_SlowOpen(t *testing.T) {
+	b := []byte("abcdef")
+
+	openingC := make(chan struct{})
+	unblockC := make(chan struct{})
+
+	mutex := new(sync.Mutex)
+	opens := 0
+
+	// The first open blocks until we allow it to finish.
+	opener := func() (io.ReadSeeker, error) {
+		mutex.Lock()
+		opens++
+		isFirst := opens == 1
+		mutex.Unlock()
+
+		if isFirst == true {
+			close(openingC)
+			<-unblockC
+		}
+
+		return bytes.NewReader(b), nil
+	}
+
+	pra := NewPooledReaderAt(opener, 2)
+
+	defer pra.Close()
+
+	slowDoneC := make(chan error)
+
+	go func() {
+		_, err := pra.ReadAt(make([]byte, 1), 0)
+		slowDoneC <- err
+	}()
+
+	<-openingC
+
+	// Another read doesn't have to wait for the slow open.
+
+	fastDoneC := make(chan error)
+
+	go func() {
+		_, err := pra.ReadAt(make([]byte, 1), 1)
+		fastDoneC <- err
+	}()
+
+	select {
+	case err := <-fastDoneC:
+		log.PanicIf(err)
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Read waited on the slow open.")
+	}
+
+	close(unblockC)
+
+	err := <-slowDoneC
+	log.PanicIf(err)
+
+	if pra.HandleCount() != 2 {
+		t.Fatalf("Handle count not correct: (%d)", pra.HandleCount())
+	}
+}
+
+func TestPooledReaderAt_ReadAt_OpenFails(t *testing.T) {
+	b := []byte("abcdef")
+
+	fail := true
+
+	opener := func() (io.ReadSeeker, error) {
+		if fail == true {
+			return nil, errors.New("open failed")
+		}
+
+		return bytes.NewReader(b), nil
+	}
+
+	pra := NewPooledReaderAt(opener, 1)
+
+	defer pra.Close()
+
+	_, err := pra.ReadAt(make([]byte, 1), 0)
+	if err == nil {
+		t.Fatalf("Expected open error.")
+	}
+
+	// The reservation was given back, so the only slot can still be used.
+
+	fail = false
+
+	_, err = pra.ReadAt(make([]byte, 1), 0)
+	log.PanicIf(err)
+
+	if pra.HandleCount() != 1 {
+		t.Fatalf("Handle count not correct: (%d)", pra.HandleCount())
+	}
+}
+
+func TestPooledReaderAt_Close(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file")
+
+	err = ioutil.WriteFile(filepath, []byte("abcdef"), 0644)
+	log.PanicIf(err)
+
+	files := make([]*os.File, 0)
+
+	opener := func() (io.ReadSeeker, error) {
+		f, err := os.Open(filepath)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+
+		return f, nil
+	}
+
+	pra := NewPooledReaderAt(opener, 0)
+
+	fragment := make([]byte, 3)
+
+	_, err = pra.ReadAt(fragment, 3)
+	log.PanicIf(err)
+
+	if string(fragment) != "def" {
+		t.Fatalf("Data not correct: [%s]", string(fragment))
+	}
+
+	err = pra.Close()
+	log.PanicIf(err)
+
+	if len(files) != 1 {
+		t.Fatalf("Expected one handle: (%d)", len(files))
+	} else if _, err := files[0].Stat(); err == nil {
+		t.Fatalf("Handle was not closed.")
+	}
+
+	_, err = pra.ReadAt(fragment, 0)
+	if err != ErrPooledReaderAtClosed {
+		t.Fatalf("Expected closed error: %v", err)
+	}
+}
+
+func TestPooledReaderAt_Conformance(t *testing.T) {
+	data := []byte("some test data")
+
+	opener := func() (io.ReadSeeker, error) {
+		return bytes.NewReader(data), nil
+	}
+
+	pra := NewPooledReaderAt(opener, 3)
+
+	defer pra.Close()
+
+	err := ritesting.CheckReaderAt(pra, data)
+	log.PanicIf(err)
+}
@@ -0,0 +1,231 @@
+package rifs
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ProgressEventType identifies the operation that a `ProgressEvent` describes.
+type ProgressEventType int
+
+const (
+	// ProgressEventRead is a sequential read (including reads done through
+	// `WriteTo`).
+	ProgressEventRead ProgressEventType = iota
+
+	// ProgressEventWrite is a sequential write (including writes done
+	// through `ReadFrom`).
+	ProgressEventWrite
+
+	// ProgressEventSeek is a seek. `Offset` is the resulting offset.
+	ProgressEventSeek
+
+	// ProgressEventReadAt is a positional read. `Offset` is where it was
+	// done.
+	ProgressEventReadAt
+
+	// ProgressEventWriteAt is a positional write. `Offset` is where it was
+	// done.
+	ProgressEventWriteAt
+
+	// ProgressEventClose is a close.
+	ProgressEventClose
+)
+
+// String returns a descriptive string.
+func (pet ProgressEventType) String() string {
+	if pet == ProgressEventRead {
+		return "READ"
+	} else if pet == ProgressEventWrite {
+		return "WRITE"
+	} else if pet == ProgressEventSeek {
+		return "SEEK"
+	} else if pet == ProgressEventReadAt {
+		return "READ-AT"
+	} else if pet == ProgressEventWriteAt {
+		return "WRITE-AT"
+	} else if pet == ProgressEventClose {
+		return "CLOSE"
+	}
+
+	log.Panicf("unknown progress-event type: (%d)", pet)
+	return ""
+}
+
+// ProgressEvent describes one operation on a progress-wrapped value.
+type ProgressEvent struct {
+	Type ProgressEventType
+
+	// N is the number of bytes transferred.
+	N int
+
+	// Offset is the resulting offset for seeks and the requested offset for
+	// positional reads and writes.
+	Offset int64
+
+	// Duration is how long the operation took.
+	Duration time.Duration
+
+	// IsEof is set when a read hits EOF and when a writer is closed.
+	IsEof bool
+}
+
+// String returns a descriptive string.
+func (pe ProgressEvent) String() string {
+	return fmt.Sprintf("ProgressEvent<TYPE=[%s] N=(%d) OFFSET=(%d) EOF=[%v]>", pe.Type, pe.N, pe.Offset, pe.IsEof)
+}
+
+// ProgressEventFunc receives progress events. A returned error is returned
+// from the operation (unless the operation itself failed).
+type ProgressEventFunc func(pe ProgressEvent) error
+
+// NewProgressEventAdapter returns a `ProgressEventFunc` that forwards the
+// transfers to a `ProgressFunc`, along with the EOFs of sequential reads and
+// of closed writers. Seeks are dropped. This allows the wrappers to feed a
+// `ProgressTracker`.
+func NewProgressEventAdapter(progressCb ProgressFunc) ProgressEventFunc {
+	return func(pe ProgressEvent) error {
+		isEof := pe.IsEof == true && (pe.Type == ProgressEventRead || pe.Type == ProgressEventClose)
+
+		if pe.Type == ProgressEventSeek || (pe.N == 0 && isEof == false) {
+			return nil
+		}
+
+		return progressCb(pe.N, pe.Duration, isEof)
+	}
+}
+
+func noopProgressEventFunc(pe ProgressEvent) error {
+	return nil
+}
+
+// emitProgress sends an event and decides which error the operation should
+// return.
+func emitProgress(progressCb ProgressEventFunc, pe ProgressEvent, err error) error {
+	cbErr := progressCb(pe)
+	if cbErr != nil && (err == nil || err == io.EOF) {
+		return cbErr
+	}
+
+	return err
+}
+
+// progressSeeker reports seeks.
+type progressSeeker struct {
+	s          io.Seeker
+	progressCb ProgressEventFunc
+}
+
+// Seek seeks and reports the resulting offset.
+func (ps *progressSeeker) Seek(offset int64, whence int) (newOffset int64, err error) {
+	startAt := time.Now()
+
+	newOffset, err = ps.s.Seek(offset, whence)
+	if err != nil {
+		return newOffset, err
+	}
+
+	pe := ProgressEvent{
+		Type:     ProgressEventSeek,
+		Offset:   newOffset,
+		Duration: time.Since(startAt),
+	}
+
+	return newOffset, emitProgress(ps.progressCb, pe, nil)
+}
+
+// progressReaderAt reports positional reads.
+type progressReaderAt struct {
+	ra         io.ReaderAt
+	progressCb ProgressEventFunc
+}
+
+// ReadAt reads and reports.
+func (pra *progressReaderAt) ReadAt(buffer []byte, offset int64) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = pra.ra.ReadAt(buffer, offset)
+
+	pe := ProgressEvent{
+		Type:     ProgressEventReadAt,
+		N:        n,
+		Offset:   offset,
+		Duration: time.Since(startAt),
+		IsEof:    err == io.EOF,
+	}
+
+	return n, emitProgress(pra.progressCb, pe, err)
+}
+
+// progressWriterAt reports positional writes.
+type progressWriterAt struct {
+	wa         io.WriterAt
+	progressCb ProgressEventFunc
+}
+
+// WriteAt writes and reports.
+func (pwa *progressWriterAt) WriteAt(buffer []byte, offset int64) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = pwa.wa.WriteAt(buffer, offset)
+
+	pe := ProgressEvent{
+		Type:     ProgressEventWriteAt,
+		N:        n,
+		Offset:   offset,
+		Duration: time.Since(startAt),
+	}
+
+	return n, emitProgress(pwa.progressCb, pe, err)
+}
+
+// progressCloser reports closes. Closing a writer is reported as its EOF.
+type progressCloser struct {
+	c          io.Closer
+	progressCb ProgressEventFunc
+	isWriter   bool
+}
+
+// Close closes and reports.
+func (pc *progressCloser) Close() (err error) {
+	startAt := time.Now()
+
+	err = pc.c.Close()
+	if err != nil {
+		return err
+	}
+
+	pe := ProgressEvent{
+		Type:     ProgressEventClose,
+		Duration: time.Since(startAt),
+		IsEof:    pc.isWriter,
+	}
+
+	return emitProgress(pc.progressCb, pe, nil)
+}
+
+// progressReadWriteSeekCloser is the variant for `ReadWriteSeekCloser`.
+type progressReadWriteSeekCloser struct {
+	*progressReaderCore
+	*progressWriterCore
+	*progressSeeker
+	*progressCloser
+}
+
+// NewProgressReadWriteSeekCloser wraps a `ReadWriteSeekCloser` and reports
+// every operation. Closing is reported as EOF.
+func NewProgressReadWriteSeekCloser(rwsc ReadWriteSeekCloser, progressCb ProgressEventFunc) ReadWriteSeekCloser {
+	if progressCb == nil {
+		progressCb = noopProgressEventFunc
+	}
+
+	return progressReadWriteSeekCloser{
+		progressReaderCore: &progressReaderCore{r: rwsc, progressCb: progressCb},
+		progressWriterCore: &progressWriterCore{w: rwsc, progressCb: progressCb},
+		progressSeeker:     &progressSeeker{s: rwsc, progressCb: progressCb},
+		progressCloser:     &progressCloser{c: rwsc, progressCb: progressCb, isWriter: true},
+	}
+}
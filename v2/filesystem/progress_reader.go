@@ -0,0 +1,187 @@
+package rifs
+
+import (
+	"io"
+	"time"
+)
+
+// progressReaderCore reports sequential reads. It always implements
+// `io.WriterTo`, using the wrapped reader's own if it has one.
+type progressReaderCore struct {
+	r          io.Reader
+	progressCb ProgressEventFunc
+}
+
+// Read reads and reports.
+func (prc *progressReaderCore) Read(buffer []byte) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = prc.r.Read(buffer)
+
+	pe := ProgressEvent{
+		Type:     ProgressEventRead,
+		N:        n,
+		Duration: time.Since(startAt),
+		IsEof:    err == io.EOF,
+	}
+
+	return n, emitProgress(prc.progressCb, pe, err)
+}
+
+// WriteTo writes everything to the given writer and reports each chunk as a
+// read, followed by EOF.
+func (prc *progressReaderCore) WriteTo(w io.Writer) (n int64, err error) {
+	wt, ok := prc.r.(io.WriterTo)
+	if ok == false {
+		// Our `Read` reports everything, including EOF.
+		return io.Copy(w, readerOnly{prc})
+	}
+
+	pw := &progressChunkWriter{
+		w:          w,
+		progressCb: prc.progressCb,
+		eventType:  ProgressEventRead,
+	}
+
+	n, err = wt.WriteTo(pw)
+	if err != nil {
+		return n, err
+	}
+
+	pe := ProgressEvent{
+		Type:  ProgressEventRead,
+		IsEof: true,
+	}
+
+	return n, emitProgress(prc.progressCb, pe, nil)
+}
+
+// readerOnly hides everything but `Read`.
+type readerOnly struct {
+	io.Reader
+}
+
+// progressChunkWriter reports each write to the given writer as the given
+// event type.
+type progressChunkWriter struct {
+	w          io.Writer
+	progressCb ProgressEventFunc
+	eventType  ProgressEventType
+}
+
+// Write writes and reports.
+func (pcw *progressChunkWriter) Write(buffer []byte) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = pcw.w.Write(buffer)
+
+	pe := ProgressEvent{
+		Type:     pcw.eventType,
+		N:        n,
+		Duration: time.Since(startAt),
+	}
+
+	return n, emitProgress(pcw.progressCb, pe, err)
+}
+
+type progressReader struct {
+	*progressReaderCore
+}
+
+type progressReadSeeker struct {
+	*progressReaderCore
+	*progressSeeker
+}
+
+type progressReadCloser struct {
+	*progressReaderCore
+	*progressCloser
+}
+
+type progressReadSeekCloser struct {
+	*progressReaderCore
+	*progressSeeker
+	*progressCloser
+}
+
+type progressReadReaderAt struct {
+	*progressReaderCore
+	*progressReaderAt
+}
+
+type progressReadSeekReaderAt struct {
+	*progressReaderCore
+	*progressSeeker
+	*progressReaderAt
+}
+
+type progressReadCloseReaderAt struct {
+	*progressReaderCore
+	*progressCloser
+	*progressReaderAt
+}
+
+type progressReadSeekCloseReaderAt struct {
+	*progressReaderCore
+	*progressSeeker
+	*progressCloser
+	*progressReaderAt
+}
+
+// NewProgressReader wraps a reader and reports every operation through the
+// callback. Unlike `NewReadProgressWrapper`, the returned value implements
+// `io.Seeker`, `io.ReaderAt`, and `io.Closer` if the given reader does, so it
+// can be type-asserted back to, for example, an `io.ReadSeeker`. It always
+// implements `io.WriterTo`.
+func NewProgressReader(r io.Reader, progressCb ProgressEventFunc) io.Reader {
+	if progressCb == nil {
+		progressCb = noopProgressEventFunc
+	}
+
+	prc := &progressReaderCore{
+		r:          r,
+		progressCb: progressCb,
+	}
+
+	var ps *progressSeeker
+	if s, ok := r.(io.Seeker); ok == true {
+		ps = &progressSeeker{
+			s:          s,
+			progressCb: progressCb,
+		}
+	}
+
+	var pc *progressCloser
+	if c, ok := r.(io.Closer); ok == true {
+		pc = &progressCloser{
+			c:          c,
+			progressCb: progressCb,
+		}
+	}
+
+	var pra *progressReaderAt
+	if ra, ok := r.(io.ReaderAt); ok == true {
+		pra = &progressReaderAt{
+			ra:         ra,
+			progressCb: progressCb,
+		}
+	}
+
+	if ps != nil && pc != nil && pra != nil {
+		return progressReadSeekCloseReaderAt{prc, ps, pc, pra}
+	} else if ps != nil && pc != nil {
+		return progressReadSeekCloser{prc, ps, pc}
+	} else if ps != nil && pra != nil {
+		return progressReadSeekReaderAt{prc, ps, pra}
+	} else if pc != nil && pra != nil {
+		return progressReadCloseReaderAt{prc, pc, pra}
+	} else if ps != nil {
+		return progressReadSeeker{prc, ps}
+	} else if pc != nil {
+		return progressReadCloser{prc, pc}
+	} else if pra != nil {
+		return progressReadReaderAt{prc, pra}
+	}
+
+	return progressReader{prc}
+}
@@ -0,0 +1,208 @@
+package rifs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Readers with each combination of optional interfaces.
+
+type testReadOnly struct{ io.Reader }
+
+type testReadSeeker struct {
+	io.Reader
+	io.Seeker
+}
+
+type testReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type testReadSeekCloser struct {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+type testReadReaderAt struct {
+	io.Reader
+	io.ReaderAt
+}
+
+type testReadSeekReaderAt struct {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+}
+
+type testReadCloseReaderAt struct {
+	io.Reader
+	io.Closer
+	io.ReaderAt
+}
+
+type testReadSeekCloseReaderAt struct {
+	io.Reader
+	io.Seeker
+	io.Closer
+	io.ReaderAt
+}
+
+type testNoopCloser struct{}
+
+func (testNoopCloser) Close() error {
+	return nil
+}
+
+func TestNewProgressReader_PreservesInterfaces(t *testing.T) {
+	br := bytes.NewReader([]byte("abc"))
+	c := testNoopCloser{}
+
+	cases := []struct {
+		r          io.Reader
+		isSeeker   bool
+		isCloser   bool
+		isReaderAt bool
+	}{
+		{testReadOnly{br}, false, false, false},
+		{testReadSeeker{br, br}, true, false, false},
+		{testReadCloser{br, c}, false, true, false},
+		{testReadSeekCloser{br, br, c}, true, true, false},
+		{testReadReaderAt{br, br}, false, false, true},
+		{testReadSeekReaderAt{br, br, br}, true, false, true},
+		{testReadCloseReaderAt{br, c, br}, false, true, true},
+		{testReadSeekCloseReaderAt{br, br, c, br}, true, true, true},
+	}
+
+	for i, testCase := range cases {
+		r := NewProgressReader(testCase.r, nil)
+
+		_, isSeeker := r.(io.Seeker)
+		_, isCloser := r.(io.Closer)
+		_, isReaderAt := r.(io.ReaderAt)
+		_, isWriterTo := r.(io.WriterTo)
+
+		if isSeeker != testCase.isSeeker || isCloser != testCase.isCloser || isReaderAt != testCase.isReaderAt {
+			t.Fatalf("Interfaces not correct for case (%d): SEEKER=[%v] CLOSER=[%v] READER-AT=[%v]", i, isSeeker, isCloser, isReaderAt)
+		} else if isWriterTo != true {
+			t.Fatalf("Case (%d) should always be a WriterTo.", i)
+		}
+	}
+}
+
+func TestNewProgressReader_Events(t *testing.T) {
+	events := make([]ProgressEvent, 0)
+	progressCb := func(pe ProgressEvent) error {
+		pe.Duration = 0
+		events = append(events, pe)
+
+		return nil
+	}
+
+	r := NewProgressReader(bytes.NewReader([]byte("abcdef")), progressCb)
+
+	buffer := make([]byte, 4)
+
+	_, err := r.Read(buffer)
+	log.PanicIf(err)
+
+	rs := r.(io.ReadSeeker)
+
+	_, err = rs.Seek(1, io.SeekStart)
+	log.PanicIf(err)
+
+	ra := r.(io.ReaderAt)
+
+	_, err = ra.ReadAt(buffer[:2], 3)
+	log.PanicIf(err)
+
+	_, err = ra.ReadAt(buffer, 4)
+	if err != io.EOF {
+		t.Fatalf("Expected EOF from ReadAt: %v", err)
+	}
+
+	_, err = ioutil.ReadAll(r)
+	log.PanicIf(err)
+
+	expected := []ProgressEvent{
+		{Type: ProgressEventRead, N: 4},
+		{Type: ProgressEventSeek, Offset: 1},
+		{Type: ProgressEventReadAt, N: 2, Offset: 3},
+		{Type: ProgressEventReadAt, N: 2, Offset: 4, IsEof: true},
+		{Type: ProgressEventRead, N: 5},
+		{Type: ProgressEventRead, N: 0, IsEof: true},
+	}
+
+	if reflect.DeepEqual(events, expected) != true {
+		t.Fatalf("Events not correct: %v", events)
+	}
+}
+
+func TestNewProgressReader_WriteTo(t *testing.T) {
+	events := make([]ProgressEvent, 0)
+	progressCb := func(pe ProgressEvent) error {
+		pe.Duration = 0
+		events = append(events, pe)
+
+		return nil
+	}
+
+	// bytes.Reader has its own WriteTo, which should be used.
+
+	r := NewProgressReader(bytes.NewReader([]byte("abcdef")), progressCb)
+	b := new(bytes.Buffer)
+
+	n, err := r.(io.WriterTo).WriteTo(b)
+	log.PanicIf(err)
+
+	expected := []ProgressEvent{
+		{Type: ProgressEventRead, N: 6},
+		{Type: ProgressEventRead, IsEof: true},
+	}
+
+	if n != 6 || b.String() != "abcdef" {
+		t.Fatalf("Copy not correct: (%d) [%s]", n, b.String())
+	} else if reflect.DeepEqual(events, expected) != true {
+		t.Fatalf("Events not correct: %v", events)
+	}
+
+	// Without one, our reads should be used.
+
+	events = events[:0]
+
+	r = NewProgressReader(testReadOnly{bytes.NewReader([]byte("abcdef"))}, progressCb)
+	b = new(bytes.Buffer)
+
+	n, err = r.(io.WriterTo).WriteTo(b)
+	log.PanicIf(err)
+
+	if n != 6 || b.String() != "abcdef" {
+		t.Fatalf("Copy not correct: (%d) [%s]", n, b.String())
+	} else if len(events) < 2 || events[len(events)-1].IsEof != true {
+		t.Fatalf("Events not correct: %v", events)
+	}
+}
+
+func TestNewProgressReader_CallbackError(t *testing.T) {
+	errStop := errors.New("stop")
+
+	progressCb := func(pe ProgressEvent) error {
+		return errStop
+	}
+
+	r := NewProgressReader(bytes.NewReader([]byte("abc")), progressCb)
+
+	n, err := r.Read(make([]byte, 2))
+	if err != errStop {
+		t.Fatalf("Expected callback error: %v", err)
+	} else if n != 2 {
+		t.Fatalf("Count should still be returned: (%d)", n)
+	}
+}
@@ -0,0 +1,240 @@
+package rifs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProgressSmoothingFactor = 0.3
+)
+
+// ProgressSnapshot is the state of a `ProgressTracker` at one point in time.
+type ProgressSnapshot struct {
+	// Bytes is the number of bytes transferred across all streams.
+	Bytes int64
+
+	// Total is the expected number of bytes. Zero if not known.
+	Total int64
+
+	// Elapsed is the time since the tracker was created.
+	Elapsed time.Duration
+
+	// InstantaneousRate is the rate, in bytes per second, since the previous
+	// report.
+	InstantaneousRate float64
+
+	// AverageRate is the exponentially-weighted moving average of the rate,
+	// in bytes per second.
+	AverageRate float64
+
+	// Percent is the percentage complete. Zero if the total is not known.
+	Percent float64
+
+	// Eta is the estimated time remaining. It is -1 if it can not be
+	// estimated.
+	Eta time.Duration
+
+	// ActiveStreams is the number of streams that have not reached EOF.
+	ActiveStreams int
+
+	// IsComplete is true once every stream has reached EOF.
+	IsComplete bool
+}
+
+// String returns a descriptive string.
+func (ps ProgressSnapshot) String() string {
+	return fmt.Sprintf("ProgressSnapshot<BYTES=(%d) TOTAL=(%d) RATE=(%.0f/s) PERCENT=(%.1f) ETA=[%s] ACTIVE=(%d)>", ps.Bytes, ps.Total, ps.AverageRate, ps.Percent, ps.Eta, ps.ActiveStreams)
+}
+
+// ProgressTrackerOptions describes how a `ProgressTracker` reports.
+type ProgressTrackerOptions struct {
+	// Total is the expected number of bytes across all streams, if known.
+	Total int64
+
+	// MinimumInterval, if given, is the time that has to pass between
+	// reports.
+	MinimumInterval time.Duration
+
+	// MinimumBytes, if given, is the number of bytes that have to be
+	// transferred between reports. If both minimums are given, a report is
+	// made when either is reached.
+	MinimumBytes int64
+
+	// SmoothingFactor is the weight (0, 1] given to the newest sample when
+	// calculating the average rate. Defaults to 0.3.
+	SmoothingFactor float64
+
+	// Callback receives the reports. It is called synchronously and must not
+	// call back into the tracker. Completion is always reported.
+	Callback func(ps ProgressSnapshot)
+}
+
+// ProgressTracker consumes the updates from any number of progress-wrapped
+// streams, which may be used concurrently, and reports rates, percentages,
+// and ETAs.
+type ProgressTracker struct {
+	options ProgressTrackerOptions
+
+	mutex sync.Mutex
+
+	startedAt time.Time
+	bytes     int64
+	streams   int
+	active    int
+
+	lastReportAt    time.Time
+	lastReportBytes int64
+	averageRate     float64
+	hasAverage      bool
+	last            ProgressSnapshot
+
+	// now is replaced in testing.
+	now func() time.Time
+}
+
+// NewProgressTracker returns a new ProgressTracker instance.
+func NewProgressTracker(options ProgressTrackerOptions) *ProgressTracker {
+	if options.SmoothingFactor <= 0 || options.SmoothingFactor > 1 {
+		options.SmoothingFactor = defaultProgressSmoothingFactor
+	}
+
+	pt := &ProgressTracker{
+		options: options,
+		now:     time.Now,
+	}
+
+	pt.startedAt = pt.now()
+	pt.lastReportAt = pt.startedAt
+
+	pt.last = ProgressSnapshot{
+		Total: options.Total,
+		Eta:   -1,
+	}
+
+	return pt
+}
+
+// NewStream registers a new stream and returns the `ProgressFunc` to give to
+// its wrapper. The stream is finished when the function is called with
+// `isEof` set.
+func (pt *ProgressTracker) NewStream() ProgressFunc {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	pt.streams++
+	pt.active++
+
+	finished := false
+
+	return func(n int, duration time.Duration, isEof bool) error {
+		pt.mutex.Lock()
+		defer pt.mutex.Unlock()
+
+		pt.bytes += int64(n)
+
+		// EOF may be reported more than once.
+		if isEof == true && finished == false {
+			finished = true
+			pt.active--
+		}
+
+		pt.update(isEof == true && pt.active == 0)
+
+		return nil
+	}
+}
+
+// Add counts bytes that were transferred outside of any stream.
+func (pt *ProgressTracker) Add(n int64) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	pt.bytes += n
+	pt.update(false)
+}
+
+// Snapshot returns the most recent report.
+func (pt *ProgressTracker) Snapshot() ProgressSnapshot {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	return pt.last
+}
+
+// isDue returns true if a report is due based on the configured minimums.
+func (pt *ProgressTracker) isDue(now time.Time) bool {
+	if pt.options.MinimumInterval <= 0 && pt.options.MinimumBytes <= 0 {
+		return true
+	}
+
+	if pt.options.MinimumInterval > 0 && now.Sub(pt.lastReportAt) >= pt.options.MinimumInterval {
+		return true
+	}
+
+	if pt.options.MinimumBytes > 0 && pt.bytes-pt.lastReportBytes >= pt.options.MinimumBytes {
+		return true
+	}
+
+	return false
+}
+
+// update reports if due or if forced. The lock must be held.
+func (pt *ProgressTracker) update(force bool) {
+	now := pt.now()
+
+	if force == false && pt.isDue(now) == false {
+		return
+	}
+
+	ps := ProgressSnapshot{
+		Bytes:         pt.bytes,
+		Total:         pt.options.Total,
+		Elapsed:       now.Sub(pt.startedAt),
+		Eta:           -1,
+		ActiveStreams: pt.active,
+		IsComplete:    pt.streams > 0 && pt.active == 0,
+	}
+
+	sinceLast := now.Sub(pt.lastReportAt)
+	if sinceLast > 0 {
+		ps.InstantaneousRate = float64(pt.bytes-pt.lastReportBytes) / sinceLast.Seconds()
+
+		if pt.hasAverage == false {
+			pt.averageRate = ps.InstantaneousRate
+			pt.hasAverage = true
+		} else {
+			alpha := pt.options.SmoothingFactor
+			pt.averageRate = alpha*ps.InstantaneousRate + (1-alpha)*pt.averageRate
+		}
+	} else {
+		// Too close together to measure. Carry the last rate forward.
+		ps.InstantaneousRate = pt.last.InstantaneousRate
+	}
+
+	ps.AverageRate = pt.averageRate
+
+	if ps.Total > 0 {
+		ps.Percent = float64(ps.Bytes) / float64(ps.Total) * 100
+
+		if ps.Bytes >= ps.Total {
+			ps.Eta = 0
+		} else if ps.AverageRate > 0 {
+			remaining := float64(ps.Total-ps.Bytes) / ps.AverageRate
+			ps.Eta = time.Duration(remaining * float64(time.Second))
+		}
+	}
+
+	if ps.IsComplete == true {
+		ps.Eta = 0
+	}
+
+	pt.lastReportAt = now
+	pt.lastReportBytes = pt.bytes
+	pt.last = ps
+
+	if pt.options.Callback != nil {
+		pt.options.Callback(ps)
+	}
+}
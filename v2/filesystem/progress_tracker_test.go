@@ -0,0 +1,203 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// fakeClock is a manually-advanced time source.
+type fakeClock struct {
+	current time.Time
+}
+
+func (fc *fakeClock) now() time.Time {
+	return fc.current
+}
+
+func (fc *fakeClock) advance(duration time.Duration) {
+	fc.current = fc.current.Add(duration)
+}
+
+func newTestProgressTracker(options ProgressTrackerOptions) (*ProgressTracker, *fakeClock) {
+	fc := &fakeClock{
+		current: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	pt := NewProgressTracker(options)
+
+	pt.now = fc.now
+	pt.startedAt = fc.now()
+	pt.lastReportAt = pt.startedAt
+
+	return pt, fc
+}
+
+func TestProgressTracker_Rates(t *testing.T) {
+	reports := make([]ProgressSnapshot, 0)
+
+	options := ProgressTrackerOptions{
+		Total:           1000,
+		SmoothingFactor: 0.5,
+		Callback: func(ps ProgressSnapshot) {
+			reports = append(reports, ps)
+		},
+	}
+
+	pt, fc := newTestProgressTracker(options)
+	progressCb := pt.NewStream()
+
+	fc.advance(time.Second)
+
+	err := progressCb(100, 0, false)
+	log.PanicIf(err)
+
+	fc.advance(time.Second)
+
+	err = progressCb(300, 0, false)
+	log.PanicIf(err)
+
+	if len(reports) != 2 {
+		t.Fatalf("Report count not correct: (%d)", len(reports))
+	}
+
+	ps := reports[1]
+
+	if ps.Bytes != 400 || ps.Elapsed != time.Second*2 {
+		t.Fatalf("Totals not correct: %s", ps)
+	} else if ps.InstantaneousRate != 300 {
+		t.Fatalf("Instantaneous rate not correct: (%f)", ps.InstantaneousRate)
+	} else if ps.AverageRate != 200 {
+		t.Fatalf("Average rate not correct: (%f)", ps.AverageRate)
+	} else if ps.Percent != 40 {
+		t.Fatalf("Percent not correct: (%f)", ps.Percent)
+	} else if ps.Eta != time.Second*3 {
+		t.Fatalf("ETA not correct: %s", ps.Eta)
+	} else if ps.ActiveStreams != 1 || ps.IsComplete != false {
+		t.Fatalf("Stream state not correct: %s", ps)
+	}
+
+	fc.advance(time.Second)
+
+	err = progressCb(600, 0, false)
+	log.PanicIf(err)
+
+	err = progressCb(0, 0, true)
+	log.PanicIf(err)
+
+	ps = pt.Snapshot()
+
+	if ps.IsComplete != true || ps.Eta != 0 || ps.Percent != 100 {
+		t.Fatalf("Completion not correct: %s", ps)
+	}
+}
+
+func TestProgressTracker_UnknownTotal(t *testing.T) {
+	pt, fc := newTestProgressTracker(ProgressTrackerOptions{})
+	progressCb := pt.NewStream()
+
+	fc.advance(time.Second)
+
+	err := progressCb(100, 0, false)
+	log.PanicIf(err)
+
+	ps := pt.Snapshot()
+
+	if ps.Percent != 0 || ps.Eta != -1 {
+		t.Fatalf("Percent and ETA should not be known: %s", ps)
+	} else if ps.AverageRate != 100 {
+		t.Fatalf("Rate not correct: (%f)", ps.AverageRate)
+	}
+}
+
+func TestProgressTracker_Throttle(t *testing.T) {
+	reports := make([]ProgressSnapshot, 0)
+
+	options := ProgressTrackerOptions{
+		MinimumInterval: time.Second,
+		MinimumBytes:    1000,
+		Callback: func(ps ProgressSnapshot) {
+			reports = append(reports, ps)
+		},
+	}
+
+	pt, fc := newTestProgressTracker(options)
+	progressCb := pt.NewStream()
+
+	// Neither minimum reached.
+	fc.advance(time.Millisecond * 100)
+
+	err := progressCb(10, 0, false)
+	log.PanicIf(err)
+
+	// The byte minimum.
+	fc.advance(time.Millisecond * 100)
+
+	err = progressCb(1000, 0, false)
+	log.PanicIf(err)
+
+	// The time minimum.
+	fc.advance(time.Second)
+
+	err = progressCb(10, 0, false)
+	log.PanicIf(err)
+
+	// Neither, but completion is always reported.
+	err = progressCb(0, 0, true)
+	log.PanicIf(err)
+
+	if len(reports) != 3 {
+		t.Fatalf("Report count not correct: %v", reports)
+	}
+
+	if reports[0].Bytes != 1010 || reports[1].Bytes != 1020 || reports[2].IsComplete != true {
+		t.Fatalf("Reports not correct: %v", reports)
+	}
+}
+
+func TestProgressTracker_Concurrent(t *testing.T) {
+	data := []byte(strings.Repeat("test bytes", 10000))
+
+	options := ProgressTrackerOptions{
+		Total: int64(len(data)) * 10,
+	}
+
+	pt := NewProgressTracker(options)
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 5; i++ {
+		readCb := pt.NewStream()
+		writeCb := pt.NewStream()
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			r := NewReadProgressWrapper(bytes.NewReader(data), readCb)
+			w := NewWriteProgressWrapper(new(bytes.Buffer), writeCb)
+
+			_, err := GracefulCopy(w, r, make([]byte, 100))
+			log.PanicIf(err)
+
+			err = w.(io.Closer).Close()
+			log.PanicIf(err)
+		}()
+	}
+
+	wg.Wait()
+
+	ps := pt.Snapshot()
+
+	if ps.Bytes != int64(len(data))*10 {
+		t.Fatalf("Byte count not correct: (%d)", ps.Bytes)
+	} else if ps.IsComplete != true || ps.ActiveStreams != 0 {
+		t.Fatalf("Streams should all be done: %s", ps)
+	}
+}
@@ -15,6 +15,8 @@ type ProgressFunc func(n int, duration time.Duration, isEof bool) error
 type WriteProgressWrapper struct {
 	w          io.Writer
 	progressCb ProgressFunc
+
+	closed bool
 }
 
 // NewWriteProgressWrapper returns a new WPW instance.
@@ -46,6 +48,33 @@ func (wpw *WriteProgressWrapper) Write(buffer []byte) (n int, err error) {
 	return n, nil
 }
 
+// Close reports EOF to the callback and closes the underlying writer if it is
+// an `io.Closer`. Since writers have no EOF of their own, this is the only way
+// for the callback to find out that the stream is done.
+func (wpw *WriteProgressWrapper) Close() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if wpw.closed == true {
+		return nil
+	}
+
+	wpw.closed = true
+
+	if wc, ok := wpw.w.(io.Closer); ok == true {
+		err := wc.Close()
+		log.PanicIf(err)
+	}
+
+	err = wpw.progressCb(0, 0, true)
+	log.PanicIf(err)
+
+	return nil
+}
+
 // ReadProgressWrapper wraps a reader and calls a callback after each read with
 // count and duration info.
 type ReadProgressWrapper struct {
@@ -97,3 +97,35 @@ func TestReadProgressWrapper_Read(t *testing.T) {
 		t.Fatalf("Steps not correct.")
 	}
 }
+
+func TestWriteProgressWrapper_Close(t *testing.T) {
+	steps := make([][]interface{}, 0)
+	progressCb := func(n int, duration time.Duration, isEof bool) error {
+		steps = append(steps, []interface{}{n, isEof})
+		return nil
+	}
+
+	sb := NewSeekableBuffer()
+	wpw := NewWriteProgressWrapper(sb, progressCb)
+
+	_, err := wpw.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	wc := wpw.(io.Closer)
+
+	err = wc.Close()
+	log.PanicIf(err)
+
+	// A second close should not report again.
+	err = wc.Close()
+	log.PanicIf(err)
+
+	expectedSteps := [][]interface{}{
+		{3, false},
+		{0, true},
+	}
+
+	if reflect.DeepEqual(steps, expectedSteps) != true {
+		t.Fatalf("Steps not correct: %v", steps)
+	}
+}
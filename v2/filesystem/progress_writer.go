@@ -0,0 +1,180 @@
+package rifs
+
+import (
+	"io"
+	"time"
+)
+
+// progressWriterCore reports sequential writes. It always implements
+// `io.ReaderFrom`, using the wrapped writer's own if it has one.
+type progressWriterCore struct {
+	w          io.Writer
+	progressCb ProgressEventFunc
+}
+
+// Write writes and reports.
+func (pwc *progressWriterCore) Write(buffer []byte) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = pwc.w.Write(buffer)
+
+	pe := ProgressEvent{
+		Type:     ProgressEventWrite,
+		N:        n,
+		Duration: time.Since(startAt),
+	}
+
+	return n, emitProgress(pwc.progressCb, pe, err)
+}
+
+// ReadFrom reads everything from the given reader and reports each chunk as a
+// write.
+func (pwc *progressWriterCore) ReadFrom(r io.Reader) (n int64, err error) {
+	rf, ok := pwc.w.(io.ReaderFrom)
+	if ok == false {
+		// Our `Write` reports everything.
+		return io.Copy(writerOnly{pwc}, r)
+	}
+
+	// Whatever the wrapped writer reads, it writes.
+	pr := &progressChunkReader{
+		r:          r,
+		progressCb: pwc.progressCb,
+		eventType:  ProgressEventWrite,
+	}
+
+	return rf.ReadFrom(pr)
+}
+
+// writerOnly hides everything but `Write`.
+type writerOnly struct {
+	io.Writer
+}
+
+// progressChunkReader reports each read from the given reader as the given
+// event type.
+type progressChunkReader struct {
+	r          io.Reader
+	progressCb ProgressEventFunc
+	eventType  ProgressEventType
+}
+
+// Read reads and reports.
+func (pcr *progressChunkReader) Read(buffer []byte) (n int, err error) {
+	startAt := time.Now()
+
+	n, err = pcr.r.Read(buffer)
+	if n == 0 {
+		return n, err
+	}
+
+	pe := ProgressEvent{
+		Type:     pcr.eventType,
+		N:        n,
+		Duration: time.Since(startAt),
+	}
+
+	return n, emitProgress(pcr.progressCb, pe, err)
+}
+
+type progressWriter struct {
+	*progressWriterCore
+}
+
+type progressWriteSeeker struct {
+	*progressWriterCore
+	*progressSeeker
+}
+
+type progressWriteCloser struct {
+	*progressWriterCore
+	*progressCloser
+}
+
+type progressWriteSeekCloser struct {
+	*progressWriterCore
+	*progressSeeker
+	*progressCloser
+}
+
+type progressWriteWriterAt struct {
+	*progressWriterCore
+	*progressWriterAt
+}
+
+type progressWriteSeekWriterAt struct {
+	*progressWriterCore
+	*progressSeeker
+	*progressWriterAt
+}
+
+type progressWriteCloseWriterAt struct {
+	*progressWriterCore
+	*progressCloser
+	*progressWriterAt
+}
+
+type progressWriteSeekCloseWriterAt struct {
+	*progressWriterCore
+	*progressSeeker
+	*progressCloser
+	*progressWriterAt
+}
+
+// NewProgressWriter wraps a writer and reports every operation through the
+// callback. Unlike `NewWriteProgressWrapper`, the returned value implements
+// `io.Seeker`, `io.WriterAt`, and `io.Closer` if the given writer does. It
+// always implements `io.ReaderFrom`. Closing is reported as EOF.
+func NewProgressWriter(w io.Writer, progressCb ProgressEventFunc) io.Writer {
+	if progressCb == nil {
+		progressCb = noopProgressEventFunc
+	}
+
+	pwc := &progressWriterCore{
+		w:          w,
+		progressCb: progressCb,
+	}
+
+	var ps *progressSeeker
+	if s, ok := w.(io.Seeker); ok == true {
+		ps = &progressSeeker{
+			s:          s,
+			progressCb: progressCb,
+		}
+	}
+
+	var pc *progressCloser
+	if c, ok := w.(io.Closer); ok == true {
+		pc = &progressCloser{
+			c:          c,
+			progressCb: progressCb,
+			isWriter:   true,
+		}
+	}
+
+	var pwa *progressWriterAt
+	if wa, ok := w.(io.WriterAt); ok == true {
+		pwa = &progressWriterAt{
+			wa:         wa,
+			progressCb: progressCb,
+		}
+	}
+
+	if ps != nil && pc != nil && pwa != nil {
+		return progressWriteSeekCloseWriterAt{pwc, ps, pc, pwa}
+	} else if ps != nil && pc != nil {
+		return progressWriteSeekCloser{pwc, ps, pc}
+	} else if ps != nil && pwa != nil {
+		return progressWriteSeekWriterAt{pwc, ps, pwa}
+	} else if pc != nil && pwa != nil {
+		return progressWriteCloseWriterAt{pwc, pc, pwa}
+	} else if ps != nil {
+		return progressWriteSeeker{pwc, ps}
+	} else if pc != nil {
+		return progressWriteCloser{pwc, pc}
+	} else if pwa != nil {
+		return progressWriteWriterAt{pwc, pwa}
+	}
+
+	return progressWriter{pwc}
+}
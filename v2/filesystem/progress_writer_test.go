@@ -0,0 +1,208 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestNewProgressWriter_PreservesInterfaces(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	w := NewProgressWriter(f, nil)
+
+	_, isSeeker := w.(io.Seeker)
+	_, isCloser := w.(io.Closer)
+	_, isWriterAt := w.(io.WriterAt)
+	_, isReaderFrom := w.(io.ReaderFrom)
+
+	if isSeeker != true || isCloser != true || isWriterAt != true || isReaderFrom != true {
+		t.Fatalf("Interfaces not correct for file: SEEKER=[%v] CLOSER=[%v] WRITER-AT=[%v] READER-FROM=[%v]", isSeeker, isCloser, isWriterAt, isReaderFrom)
+	}
+
+	w = NewProgressWriter(new(bytes.Buffer), nil)
+
+	_, isSeeker = w.(io.Seeker)
+	_, isCloser = w.(io.Closer)
+	_, isWriterAt = w.(io.WriterAt)
+
+	if isSeeker != false || isCloser != false || isWriterAt != false {
+		t.Fatalf("Interfaces not correct for buffer: SEEKER=[%v] CLOSER=[%v] WRITER-AT=[%v]", isSeeker, isCloser, isWriterAt)
+	}
+
+	sb := NewSeekableBuffer()
+	w = NewProgressWriter(sb, nil)
+
+	_, isSeeker = w.(io.Seeker)
+	if isSeeker != true {
+		t.Fatalf("SeekableBuffer should still be a seeker.")
+	}
+}
+
+func TestNewProgressWriter_Events(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	events := make([]ProgressEvent, 0)
+	progressCb := func(pe ProgressEvent) error {
+		pe.Duration = 0
+		events = append(events, pe)
+
+		return nil
+	}
+
+	w := NewProgressWriter(f, progressCb)
+
+	_, err = w.Write([]byte("abcdef"))
+	log.PanicIf(err)
+
+	_, err = w.(io.Seeker).Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = w.(io.WriterAt).WriteAt([]byte("XY"), 4)
+	log.PanicIf(err)
+
+	err = w.(io.Closer).Close()
+	log.PanicIf(err)
+
+	expected := []ProgressEvent{
+		{Type: ProgressEventWrite, N: 6},
+		{Type: ProgressEventSeek, Offset: 2},
+		{Type: ProgressEventWriteAt, N: 2, Offset: 4},
+		{Type: ProgressEventClose, IsEof: true},
+	}
+
+	if reflect.DeepEqual(events, expected) != true {
+		t.Fatalf("Events not correct: %v", events)
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if string(data) != "abcdXY" {
+		t.Fatalf("Content not correct: [%s]", string(data))
+	}
+}
+
+func TestNewProgressWriter_ReadFrom(t *testing.T) {
+	total := 0
+	progressCb := func(pe ProgressEvent) error {
+		if pe.Type != ProgressEventWrite {
+			t.Fatalf("Event type not correct: %s", pe)
+		}
+
+		total += pe.N
+
+		return nil
+	}
+
+	// bytes.Buffer has its own ReadFrom.
+
+	b := new(bytes.Buffer)
+	w := NewProgressWriter(b, progressCb)
+
+	n, err := io.Copy(w, testReadOnly{bytes.NewReader([]byte("abcdef"))})
+	log.PanicIf(err)
+
+	if n != 6 || total != 6 || b.String() != "abcdef" {
+		t.Fatalf("Copy not correct: (%d) (%d) [%s]", n, total, b.String())
+	}
+}
+
+func TestNewProgressReadWriteSeekCloser(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	types := make([]ProgressEventType, 0)
+	progressCb := func(pe ProgressEvent) error {
+		types = append(types, pe.Type)
+		return nil
+	}
+
+	rwsc := NewProgressReadWriteSeekCloser(f, progressCb)
+
+	_, err = rwsc.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	_, err = rwsc.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(rwsc)
+	log.PanicIf(err)
+
+	err = rwsc.Close()
+	log.PanicIf(err)
+
+	if string(data) != "abc" {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	}
+
+	expected := []ProgressEventType{
+		ProgressEventWrite,
+		ProgressEventSeek,
+		ProgressEventRead,
+		ProgressEventRead,
+		ProgressEventClose,
+	}
+
+	if reflect.DeepEqual(types, expected) != true {
+		t.Fatalf("Event types not correct: %v", types)
+	}
+}
+
+func TestNewProgressEventAdapter(t *testing.T) {
+	pt := NewProgressTracker(ProgressTrackerOptions{Total: 6})
+	progressCb := NewProgressEventAdapter(pt.NewStream())
+
+	r := NewProgressReader(bytes.NewReader([]byte("abcdef")), progressCb)
+
+	// Seeks and positional reads hitting the end must not finish the stream.
+
+	_, err := r.(io.Seeker).Seek(3, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = r.(io.ReaderAt).ReadAt(make([]byte, 10), 4)
+	if err != io.EOF {
+		t.Fatalf("Expected EOF from ReadAt: %v", err)
+	}
+
+	ps := pt.Snapshot()
+	if ps.IsComplete != false || ps.Bytes != 2 {
+		t.Fatalf("Snapshot not correct: %s", ps)
+	}
+
+	_, err = r.(io.Seeker).Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = ioutil.ReadAll(r)
+	log.PanicIf(err)
+
+	ps = pt.Snapshot()
+	if ps.IsComplete != true || ps.Bytes != 8 {
+		t.Fatalf("Snapshot not correct after read: %s", ps)
+	}
+}
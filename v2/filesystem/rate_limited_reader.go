@@ -0,0 +1,47 @@
+package rifs
+
+import (
+	"context"
+	"io"
+)
+
+// RateLimitedReader throttles reads using a `RateLimiter`, which may be shared
+// with other streams.
+type RateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+// NewRateLimitedReader returns a new RateLimitedReader instance. Waits are
+// abandoned when the context is done.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, limiter *RateLimiter) *RateLimitedReader {
+	return &RateLimitedReader{
+		ctx:     ctx,
+		r:       r,
+		limiter: limiter,
+	}
+}
+
+// Read reads at most a burst's worth and then waits until the limiter allows
+// what was read.
+func (rlr *RateLimitedReader) Read(buffer []byte) (n int, err error) {
+	err = rlr.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	burst := rlr.limiter.Burst()
+	if burst > 0 && int64(len(buffer)) > burst {
+		buffer = buffer[:burst]
+	}
+
+	n, err = rlr.r.Read(buffer)
+
+	waitErr := rlr.limiter.WaitN(rlr.ctx, n)
+	if waitErr != nil && (err == nil || err == io.EOF) {
+		return n, waitErr
+	}
+
+	return n, err
+}
@@ -0,0 +1,62 @@
+package rifs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestRateLimitedReader_Read(t *testing.T) {
+	data := make([]byte, 300)
+	rl := NewRateLimiter(1000, 100)
+
+	r := NewRateLimitedReader(context.Background(), bytes.NewReader(data), rl)
+
+	startAt := time.Now()
+
+	recovered, err := ioutil.ReadAll(r)
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, data) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	// The first 100 bytes are free.
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Read was not throttled: %s", duration)
+	}
+}
+
+func TestRateLimitedReader_Read_Unlimited(t *testing.T) {
+	data := make([]byte, 10000)
+	rl := NewRateLimiter(0, 0)
+
+	r := NewRateLimitedReader(context.Background(), bytes.NewReader(data), rl)
+
+	n, err := r.Read(make([]byte, len(data)))
+	log.PanicIf(err)
+
+	if n != len(data) {
+		t.Fatalf("Read should not have been limited: (%d)", n)
+	}
+}
+
+func TestRateLimitedReader_Read_Cancel(t *testing.T) {
+	rl := NewRateLimiter(10, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	r := NewRateLimitedReader(ctx, bytes.NewReader(make([]byte, 100)), rl)
+
+	n, err := ioutil.ReadAll(r)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline: %v", err)
+	} else if len(n) != 20 {
+		t.Fatalf("Read count not correct: (%d)", len(n))
+	}
+}
@@ -0,0 +1,51 @@
+package rifs
+
+import (
+	"context"
+	"io"
+)
+
+// RateLimitedWriter throttles writes using a `RateLimiter`, which may be
+// shared with other streams.
+type RateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *RateLimiter
+}
+
+// NewRateLimitedWriter returns a new RateLimitedWriter instance. Waits are
+// abandoned when the context is done.
+func NewRateLimitedWriter(ctx context.Context, w io.Writer, limiter *RateLimiter) *RateLimitedWriter {
+	return &RateLimitedWriter{
+		ctx:     ctx,
+		w:       w,
+		limiter: limiter,
+	}
+}
+
+// Write writes in chunks of at most a burst's worth, waiting for the limiter
+// before each one.
+func (rlw *RateLimitedWriter) Write(buffer []byte) (n int, err error) {
+	for len(buffer) > 0 {
+		chunk := buffer
+		if burst := rlw.limiter.Burst(); burst > 0 && int64(len(chunk)) > burst {
+			chunk = chunk[:burst]
+		}
+
+		err = rlw.limiter.WaitN(rlw.ctx, len(chunk))
+		if err != nil {
+			return n, err
+		}
+
+		writtenCount, err := rlw.w.Write(chunk)
+		n += writtenCount
+
+		if err != nil {
+			return n, err
+		}
+
+		buffer = buffer[writtenCount:]
+	}
+
+	return n, nil
+}
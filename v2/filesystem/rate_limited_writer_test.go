@@ -0,0 +1,65 @@
+package rifs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestRateLimitedWriter_Write(t *testing.T) {
+	data := make([]byte, 300)
+	rl := NewRateLimiter(1000, 100)
+
+	b := new(bytes.Buffer)
+	w := NewRateLimitedWriter(context.Background(), b, rl)
+
+	startAt := time.Now()
+
+	n, err := w.Write(data)
+	log.PanicIf(err)
+
+	if n != 300 || b.Len() != 300 {
+		t.Fatalf("Write not correct: (%d) (%d)", n, b.Len())
+	}
+
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Write was not throttled: %s", duration)
+	}
+}
+
+func TestRateLimitedWriter_Write_Unlimited(t *testing.T) {
+	data := make([]byte, 10000)
+	rl := NewRateLimiter(0, 0)
+
+	rwscc := NewReadWriteSeekCloseCounter(ReadWriteSeekNoopCloser(NewSeekableBuffer()))
+	w := NewRateLimitedWriter(context.Background(), rwscc, rl)
+
+	n, err := w.Write(data)
+	log.PanicIf(err)
+
+	if n != len(data) {
+		t.Fatalf("Write not correct: (%d)", n)
+	} else if rwscc.Stats().Writes != 1 {
+		t.Fatalf("Write should not have been split: %s", rwscc.Stats())
+	}
+}
+
+func TestRateLimitedWriter_Write_Cancel(t *testing.T) {
+	rl := NewRateLimiter(10, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	b := new(bytes.Buffer)
+	w := NewRateLimitedWriter(ctx, b, rl)
+
+	n, err := w.Write(make([]byte, 100))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline: %v", err)
+	} else if n != 10 || b.Len() != 10 {
+		t.Fatalf("Only the burst should have been written: (%d) (%d)", n, b.Len())
+	}
+}
@@ -0,0 +1,171 @@
+package rifs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token-bucket limiter for bytes. A single limiter can be
+// shared by any number of streams in order to enforce a global cap, and the
+// rate can be changed while it is in use.
+type RateLimiter struct {
+	mutex sync.Mutex
+
+	bytesPerSecond int64
+	burst          int64
+
+	tokens   float64
+	lastFill time.Time
+
+	// changedC is closed (and replaced) whenever the rate changes, so that
+	// waiters can recalculate.
+	changedC chan struct{}
+
+	// now is replaced in testing.
+	now func() time.Time
+}
+
+// NewRateLimiter returns a new RateLimiter instance. `burst` is the most that
+// can be transferred at once after the limiter has been idle and defaults to
+// one second's worth. A rate of zero is unlimited (and has no burst). The
+// bucket starts full.
+func NewRateLimiter(bytesPerSecond int64, burst int64) *RateLimiter {
+	rl := &RateLimiter{
+		changedC: make(chan struct{}),
+		now:      time.Now,
+	}
+
+	rl.lastFill = rl.now()
+	rl.setRate(bytesPerSecond, burst)
+	rl.tokens = float64(rl.burst)
+
+	return rl
+}
+
+// String returns a descriptive string.
+func (rl *RateLimiter) String() string {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return fmt.Sprintf("RateLimiter<RATE=(%d) BURST=(%d)>", rl.bytesPerSecond, rl.burst)
+}
+
+// setRate applies a new rate. The lock must be held.
+func (rl *RateLimiter) setRate(bytesPerSecond int64, burst int64) {
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+
+	if bytesPerSecond == 0 {
+		// There's no bucket.
+		burst = 0
+	} else if burst <= 0 {
+		burst = bytesPerSecond
+	}
+
+	wasUnlimited := rl.bytesPerSecond == 0
+
+	rl.bytesPerSecond = bytesPerSecond
+	rl.burst = burst
+
+	if wasUnlimited == true || rl.tokens > float64(burst) {
+		rl.tokens = float64(burst)
+	}
+}
+
+// SetRate changes the rate and burst. Waiting streams pick up the change
+// immediately.
+func (rl *RateLimiter) SetRate(bytesPerSecond int64, burst int64) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	// Credit the time so far at the old rate.
+	rl.fill(rl.now())
+
+	rl.setRate(bytesPerSecond, burst)
+
+	close(rl.changedC)
+	rl.changedC = make(chan struct{})
+}
+
+// Rate returns the current rate in bytes per second. Zero is unlimited.
+func (rl *RateLimiter) Rate() int64 {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return rl.bytesPerSecond
+}
+
+// Burst returns the current burst. Zero if the limiter is unlimited, in which
+// case there's no need to limit the size of transfers.
+func (rl *RateLimiter) Burst() int64 {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return rl.burst
+}
+
+// fill adds the tokens that have accrued since the last fill. The lock must be
+// held.
+func (rl *RateLimiter) fill(now time.Time) {
+	elapsed := now.Sub(rl.lastFill)
+	rl.lastFill = now
+
+	if elapsed <= 0 || rl.bytesPerSecond == 0 {
+		return
+	}
+
+	rl.tokens += elapsed.Seconds() * float64(rl.bytesPerSecond)
+
+	if rl.tokens > float64(rl.burst) {
+		rl.tokens = float64(rl.burst)
+	}
+}
+
+// WaitN blocks until `n` bytes may be transferred or the context is done.
+// Requests larger than the burst are allowed once a full burst is available
+// and put the limiter into debt, which later requests wait out.
+func (rl *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return ctx.Err()
+	}
+
+	for {
+		rl.mutex.Lock()
+
+		if rl.bytesPerSecond == 0 {
+			rl.mutex.Unlock()
+			return ctx.Err()
+		}
+
+		rl.fill(rl.now())
+
+		needed := math.Min(float64(n), float64(rl.burst))
+		if rl.tokens >= needed {
+			rl.tokens -= float64(n)
+			rl.mutex.Unlock()
+
+			return nil
+		}
+
+		seconds := (needed - rl.tokens) / float64(rl.bytesPerSecond)
+		delay := time.Duration(math.Ceil(seconds * float64(time.Second)))
+		changedC := rl.changedC
+
+		rl.mutex.Unlock()
+
+		t := time.NewTimer(delay)
+
+		select {
+		case <-t.C:
+		case <-changedC:
+			t.Stop()
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package rifs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestNewRateLimiter_DefaultBurst(t *testing.T) {
+	rl := NewRateLimiter(1000, 0)
+
+	if rl.Rate() != 1000 {
+		t.Fatalf("Rate not correct: (%d)", rl.Rate())
+	} else if rl.Burst() != 1000 {
+		t.Fatalf("Burst not correct: (%d)", rl.Burst())
+	}
+}
+
+func TestNewRateLimiter_Unlimited(t *testing.T) {
+	rl := NewRateLimiter(0, 100)
+
+	if rl.Rate() != 0 {
+		t.Fatalf("Rate not correct: (%d)", rl.Rate())
+	} else if rl.Burst() != 0 {
+		t.Fatalf("Unlimited limiter should not have a burst: (%d)", rl.Burst())
+	}
+
+	// A limit can still be applied later, and the bucket starts full.
+
+	rl.SetRate(1000, 500)
+
+	startAt := time.Now()
+
+	err := rl.WaitN(context.Background(), 500)
+	log.PanicIf(err)
+
+	if rl.Burst() != 500 {
+		t.Fatalf("Burst not correct: (%d)", rl.Burst())
+	} else if time.Since(startAt) > time.Millisecond*50 {
+		t.Fatalf("Burst should not have waited: %s", time.Since(startAt))
+	}
+}
+
+func TestRateLimiter_WaitN_Burst(t *testing.T) {
+	rl := NewRateLimiter(1000, 500)
+
+	// The bucket starts full.
+
+	startAt := time.Now()
+
+	err := rl.WaitN(context.Background(), 500)
+	log.PanicIf(err)
+
+	if time.Since(startAt) > time.Millisecond*50 {
+		t.Fatalf("Burst should not have waited: %s", time.Since(startAt))
+	}
+
+	// Now we have to wait for the tokens to come back.
+
+	err = rl.WaitN(context.Background(), 200)
+	log.PanicIf(err)
+
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Did not wait: %s", duration)
+	}
+}
+
+func TestRateLimiter_WaitN_LargerThanBurst(t *testing.T) {
+	rl := NewRateLimiter(1000, 100)
+
+	err := rl.WaitN(context.Background(), 300)
+	log.PanicIf(err)
+
+	// The debt has to be paid off before anything else goes.
+
+	startAt := time.Now()
+
+	err = rl.WaitN(context.Background(), 1)
+	log.PanicIf(err)
+
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Debt was not paid: %s", duration)
+	}
+}
+
+func TestRateLimiter_WaitN_Cancel(t *testing.T) {
+	rl := NewRateLimiter(10, 10)
+
+	err := rl.WaitN(context.Background(), 10)
+	log.PanicIf(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err = rl.WaitN(ctx, 10)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline: %v", err)
+	}
+}
+
+func TestRateLimiter_SetRate(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+
+	err := rl.WaitN(context.Background(), 1)
+	log.PanicIf(err)
+
+	doneC := make(chan error)
+
+	go func() {
+		doneC <- rl.WaitN(context.Background(), 1)
+	}()
+
+	// Otherwise, this would take a second.
+	time.Sleep(time.Millisecond * 20)
+	rl.SetRate(0, 0)
+
+	select {
+	case err := <-doneC:
+		log.PanicIf(err)
+	case <-time.After(time.Millisecond * 500):
+		t.Fatalf("Waiter did not see the new rate.")
+	}
+
+	if rl.Rate() != 0 {
+		t.Fatalf("Rate not correct: (%d)", rl.Rate())
+	}
+}
+
+func TestRateLimiter_Shared(t *testing.T) {
+	rl := NewRateLimiter(2000, 100)
+
+	err := rl.WaitN(context.Background(), 100)
+	log.PanicIf(err)
+
+	startAt := time.Now()
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 5; j++ {
+				err := rl.WaitN(context.Background(), 20)
+				log.PanicIf(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// 400 bytes at 2000/s across all streams.
+	if duration := time.Since(startAt); duration < time.Millisecond*150 {
+		t.Fatalf("Streams were not limited together: %s", duration)
+	}
+}
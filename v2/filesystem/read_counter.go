@@ -2,12 +2,17 @@ package rifs
 
 import (
 	"io"
+	"sync/atomic"
 )
 
-// ReadCounter proxies read requests and maintains a counter of bytes read.
+// ReadCounter proxies read requests and maintains a counter of bytes read. The
+// count may be read from another goroutine while reads are in progress.
 type ReadCounter struct {
-	r       io.Reader
-	counter int
+	// counter is first so that it is aligned for atomic access on 32-bit
+	// platforms.
+	counter int64
+
+	r io.Reader
 }
 
 // NewReadCounter returns a new `ReadCounter` struct wrapping a `Reader`.
@@ -18,19 +23,19 @@ func NewReadCounter(r io.Reader) *ReadCounter {
 }
 
 // Count returns the total number of bytes read.
-func (rc *ReadCounter) Count() int {
-	return rc.counter
+func (rc *ReadCounter) Count() int64 {
+	return atomic.LoadInt64(&rc.counter)
 }
 
 // Reset resets the counter to zero.
 func (rc *ReadCounter) Reset() {
-	rc.counter = 0
+	atomic.StoreInt64(&rc.counter, 0)
 }
 
 // Read forwards a read to the underlying `Reader` while bumping the counter.
 func (rc *ReadCounter) Read(b []byte) (n int, err error) {
 	n, err = rc.r.Read(b)
-	rc.counter += n
+	atomic.AddInt64(&rc.counter, int64(n))
 
 	return n, err
 }
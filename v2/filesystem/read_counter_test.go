@@ -22,7 +22,7 @@ func TestReadCounter_Read(t *testing.T) {
 		t.Fatalf("Recovered data not correct:\nACTUAL:\n%v\nEXPECTED:\n%v", recovered, []byte(s))
 	}
 
-	if rc.Count() != len(s) {
+	if rc.Count() != int64(len(s)) {
 		t.Fatalf("Counter not correct: (%d)", rc.Count())
 	}
 
@@ -2,39 +2,55 @@ package rifs
 
 import (
 	"io"
+	"os"
+	"sync"
 
 	"github.com/dsoprea/go-logging"
 )
 
 // ReadSeekerToReaderAt is a wrapper that allows a ReadSeeker to masquerade as a
-// ReaderAt.
+// ReaderAt. It is safe for concurrent use.
 type ReadSeekerToReaderAt struct {
 	rs io.ReadSeeker
+
+	// f is set if the ReadSeeker is a file, which can read at an offset
+	// without seeking.
+	f *os.File
+
+	mutex sync.Mutex
 }
 
 // NewReadSeekerToReaderAt returns a new ReadSeekerToReaderAt instance.
 func NewReadSeekerToReaderAt(rs io.ReadSeeker) *ReadSeekerToReaderAt {
+	f, _ := rs.(*os.File)
+
 	return &ReadSeekerToReaderAt{
 		rs: rs,
+		f:  f,
 	}
 }
 
-// ReadAt is a wrapper that satisfies the ReaderAt interface.
+// ReadAt is a wrapper that satisfies the ReaderAt interface. A short read
+// returns `io.EOF`.
 //
-// Note that a requirement of ReadAt is that it doesn't have an effect on the
-// offset in the underlying resource as well as that concurrent calls can be
-// made to it. Since we're capturing the current offset in the underlying
-// resource and then seeking back to it before returning, it is the
-// responsibility of the caller to serialize (i.e. use a mutex with) these
-// requests in order to eliminate race-conditions in the parallel-usage
-// scenario.
-//
-// Note also that, since ReadAt() is going to be called on a particular
-// instance, that instance is going to internalize a file resource, that file-
-// resource is provided by the OS, and [most] OSs are only gonna support one
-// file-position per resource, locking is already going to be a necessary
-// internal semantic of a ReaderAt implementation.
+// The offset of the underlying resource is restored before returning and
+// concurrent calls are serialized internally, as the ReaderAt contract
+// requires. Files are read with `pread` and neither seek nor need to be
+// serialized.
 func (rstra *ReadSeekerToReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	if rstra.f != nil {
+		return rstra.f.ReadAt(p, offset)
+	}
+
+	rstra.mutex.Lock()
+	defer rstra.mutex.Unlock()
+
+	return rstra.readAt(p, offset)
+}
+
+// readAt does the read and restores the original offset. The lock must be
+// held.
+func (rstra *ReadSeekerToReaderAt) readAt(p []byte, offset int64) (n int, err error) {
 	defer func() {
 		if state := recover(); state != nil {
 			err = log.Wrap(state.(error))
@@ -44,19 +60,44 @@ func (rstra *ReadSeekerToReaderAt) ReadAt(p []byte, offset int64) (n int, err er
 	originalOffset, err := rstra.rs.Seek(0, io.SeekCurrent)
 	log.PanicIf(err)
 
+	n, readErr := readSeekerReadAt(rstra.rs, p, offset)
+
+	// Always try to go back, even if the read failed, but the read error
+	// takes precedence.
+
+	_, seekErr := rstra.rs.Seek(originalOffset, io.SeekStart)
+
+	if readErr != nil && readErr != io.EOF {
+		log.Panic(readErr)
+	}
+
+	log.PanicIf(seekErr)
+
+	return n, readErr
+}
+
+// readSeekerReadAt seeks to the given offset and reads, with the semantics of
+// `io.ReaderAt`: a short read returns `io.EOF`, unwrapped. The offset is not
+// restored.
+func readSeekerReadAt(rs io.ReadSeeker, p []byte, offset int64) (n int, err error) {
 	defer func() {
-		_, err := rstra.rs.Seek(originalOffset, io.SeekStart)
-		log.PanicIf(err)
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
 	}()
 
-	_, err = rstra.rs.Seek(offset, io.SeekStart)
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	_, err = rs.Seek(offset, io.SeekStart)
 	log.PanicIf(err)
 
-	// Note that all errors will be wrapped, here. The usage of this method is
-	// such that typically no specific errors would be expected as part of
-	// normal operation (in which case we'd check for those first and return
-	// them directly).
-	n, err = io.ReadFull(rstra.rs, p)
+	n, err = io.ReadFull(rs, p)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, io.EOF
+	}
+
 	log.PanicIf(err)
 
 	return n, nil
@@ -2,10 +2,17 @@ package rifs
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
 	"testing"
 
 	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
 )
 
 func TestNewReadSeekerToReaderAt(t *testing.T) {
@@ -56,3 +63,154 @@ func TestReadSeekerToReaderAt_ReadAt(t *testing.T) {
 		}
 	}
 }
+
+func TestReadSeekerToReaderAt_ReadAt_Concurrent(t *testing.T) {
+	b := make([]byte, 1000)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	rstra := NewReadSeekerToReaderAt(bytes.NewReader(b))
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				offset := (i*100 + j) % 990
+				fragment := make([]byte, 10)
+
+				_, err := rstra.ReadAt(fragment, int64(offset))
+				log.PanicIf(err)
+
+				if bytes.Equal(fragment, b[offset:offset+10]) != true {
+					t.Errorf("Read at (%d) not correct.", offset)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+// seekFailer fails every seek after the given number.
+type seekFailer struct {
+	io.ReadSeeker
+	seeksLeft int
+}
+
+func (sf *seekFailer) Seek(offset int64, whence int) (int64, error) {
+	if sf.seeksLeft == 0 {
+		return 0, errors.New("seek failed")
+	}
+
+	sf.seeksLeft--
+
+	return sf.ReadSeeker.Seek(offset, whence)
+}
+
+func TestReadSeekerToReaderAt_ReadAt_SeekBackError(t *testing.T) {
+	sf := &seekFailer{
+		ReadSeeker: bytes.NewReader([]byte("abcdef")),
+		seeksLeft:  2,
+	}
+
+	rstra := NewReadSeekerToReaderAt(sf)
+
+	_, err := rstra.ReadAt(make([]byte, 2), 1)
+	if err == nil || err.Error() != "seek failed" {
+		t.Fatalf("Expected seek-back error: %v", err)
+	}
+}
+
+func TestReadSeekerToReaderAt_ReadAt_File(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file")
+
+	err = ioutil.WriteFile(filepath, []byte("abcdef"), 0644)
+	log.PanicIf(err)
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	_, err = f.Seek(1, io.SeekStart)
+	log.PanicIf(err)
+
+	rstra := NewReadSeekerToReaderAt(f)
+
+	fragment := make([]byte, 3)
+
+	_, err = rstra.ReadAt(fragment, 2)
+	log.PanicIf(err)
+
+	if string(fragment) != "cde" {
+		t.Fatalf("Data not correct: [%s]", string(fragment))
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if offset != 1 {
+		t.Fatalf("File offset changed: (%d)", offset)
+	}
+}
+
+func TestReadSeekerToReaderAt_ReadAt_Eof(t *testing.T) {
+	rstra := NewReadSeekerToReaderAt(bytes.NewReader([]byte("abcdef")))
+
+	fragment := make([]byte, 4)
+
+	n, err := rstra.ReadAt(fragment, 4)
+	if err != io.EOF {
+		t.Fatalf("Expected an unwrapped EOF: %v", err)
+	} else if n != 2 || string(fragment[:n]) != "ef" {
+		t.Fatalf("Short read not correct: (%d) [%s]", n, string(fragment[:n]))
+	}
+}
+
+func TestReadSeekerToReaderAt_Conformance(t *testing.T) {
+	data := []byte("some test data")
+
+	rstra := NewReadSeekerToReaderAt(bytes.NewReader(data))
+
+	err := ritesting.CheckReaderAt(rstra, data)
+	log.PanicIf(err)
+
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	rstra = NewReadSeekerToReaderAt(bytes.NewReader(large))
+
+	err = ritesting.CheckReaderAt(rstra, large)
+	log.PanicIf(err)
+}
+
+func TestReadSeekerToReaderAt_Conformance_File(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filepath := path.Join(tempPath, "file")
+	data := []byte("some test data")
+
+	err = ioutil.WriteFile(filepath, data, 0644)
+	log.PanicIf(err)
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	err = ritesting.CheckReaderAt(NewReadSeekerToReaderAt(f), data)
+	log.PanicIf(err)
+}
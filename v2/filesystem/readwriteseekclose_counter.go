@@ -0,0 +1,262 @@
+package rifs
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// CounterStats has the byte- and call-counts for each operation type.
+type CounterStats struct {
+	ReadBytes    int64
+	ReadAtBytes  int64
+	WriteBytes   int64
+	WriteAtBytes int64
+
+	Reads    int64
+	ReadAts  int64
+	Writes   int64
+	WriteAts int64
+	Seeks    int64
+}
+
+// String returns a descriptive string.
+func (cs CounterStats) String() string {
+	return fmt.Sprintf("CounterStats<READ=(%d) READ-AT=(%d) WRITE=(%d) WRITE-AT=(%d) SEEKS=(%d)>", cs.ReadBytes, cs.ReadAtBytes, cs.WriteBytes, cs.WriteAtBytes, cs.Seeks)
+}
+
+// operationCounters are the atomic counters behind `CounterStats`.
+type operationCounters struct {
+	stats CounterStats
+}
+
+func (oc *operationCounters) addRead(n int) {
+	atomic.AddInt64(&oc.stats.Reads, 1)
+	atomic.AddInt64(&oc.stats.ReadBytes, int64(n))
+}
+
+func (oc *operationCounters) addReadAt(n int) {
+	atomic.AddInt64(&oc.stats.ReadAts, 1)
+	atomic.AddInt64(&oc.stats.ReadAtBytes, int64(n))
+}
+
+func (oc *operationCounters) addWrite(n int) {
+	atomic.AddInt64(&oc.stats.Writes, 1)
+	atomic.AddInt64(&oc.stats.WriteBytes, int64(n))
+}
+
+func (oc *operationCounters) addWriteAt(n int) {
+	atomic.AddInt64(&oc.stats.WriteAts, 1)
+	atomic.AddInt64(&oc.stats.WriteAtBytes, int64(n))
+}
+
+func (oc *operationCounters) addSeek() {
+	atomic.AddInt64(&oc.stats.Seeks, 1)
+}
+
+// Stats returns the current counts. Each count is read atomically, but the
+// counts are not read together.
+func (oc *operationCounters) Stats() CounterStats {
+	return CounterStats{
+		ReadBytes:    atomic.LoadInt64(&oc.stats.ReadBytes),
+		ReadAtBytes:  atomic.LoadInt64(&oc.stats.ReadAtBytes),
+		WriteBytes:   atomic.LoadInt64(&oc.stats.WriteBytes),
+		WriteAtBytes: atomic.LoadInt64(&oc.stats.WriteAtBytes),
+		Reads:        atomic.LoadInt64(&oc.stats.Reads),
+		ReadAts:      atomic.LoadInt64(&oc.stats.ReadAts),
+		Writes:       atomic.LoadInt64(&oc.stats.Writes),
+		WriteAts:     atomic.LoadInt64(&oc.stats.WriteAts),
+		Seeks:        atomic.LoadInt64(&oc.stats.Seeks),
+	}
+}
+
+// Reset resets all counts to zero.
+func (oc *operationCounters) Reset() {
+	atomic.StoreInt64(&oc.stats.ReadBytes, 0)
+	atomic.StoreInt64(&oc.stats.ReadAtBytes, 0)
+	atomic.StoreInt64(&oc.stats.WriteBytes, 0)
+	atomic.StoreInt64(&oc.stats.WriteAtBytes, 0)
+	atomic.StoreInt64(&oc.stats.Reads, 0)
+	atomic.StoreInt64(&oc.stats.ReadAts, 0)
+	atomic.StoreInt64(&oc.stats.Writes, 0)
+	atomic.StoreInt64(&oc.stats.WriteAts, 0)
+	atomic.StoreInt64(&oc.stats.Seeks, 0)
+}
+
+// counterReaderAt counts positional reads.
+type counterReaderAt struct {
+	ra       io.ReaderAt
+	counters *operationCounters
+}
+
+// ReadAt forwards a positional read while counting it.
+func (cra *counterReaderAt) ReadAt(b []byte, offset int64) (n int, err error) {
+	n, err = cra.ra.ReadAt(b, offset)
+	cra.counters.addReadAt(n)
+
+	return n, err
+}
+
+// counterWriterAt counts positional writes.
+type counterWriterAt struct {
+	wa       io.WriterAt
+	counters *operationCounters
+}
+
+// WriteAt forwards a positional write while counting it.
+func (cwa *counterWriterAt) WriteAt(b []byte, offset int64) (n int, err error) {
+	n, err = cwa.wa.WriteAt(b, offset)
+	cwa.counters.addWriteAt(n)
+
+	return n, err
+}
+
+// ReadSeekCounter proxies reads and seeks and counts them. The counts may be
+// read from another goroutine while I/O is in progress.
+type ReadSeekCounter interface {
+	io.ReadSeeker
+
+	// Stats returns the current counts.
+	Stats() CounterStats
+
+	// Reset resets all counts to zero.
+	Reset()
+}
+
+type readSeekCounter struct {
+	// operationCounters is first so that it is aligned for atomic access on
+	// 32-bit platforms.
+	operationCounters
+
+	rs io.ReadSeeker
+}
+
+// Read forwards a read while counting it.
+func (rsc *readSeekCounter) Read(b []byte) (n int, err error) {
+	n, err = rsc.rs.Read(b)
+	rsc.addRead(n)
+
+	return n, err
+}
+
+// Seek forwards a seek while counting it.
+func (rsc *readSeekCounter) Seek(offset int64, whence int) (newOffset int64, err error) {
+	newOffset, err = rsc.rs.Seek(offset, whence)
+	rsc.addSeek()
+
+	return newOffset, err
+}
+
+type readSeekReaderAtCounter struct {
+	*readSeekCounter
+	*counterReaderAt
+}
+
+// NewReadSeekCounter wraps a `ReadSeeker`. The returned value also implements
+// (and counts) `io.ReaderAt` if the given value does.
+func NewReadSeekCounter(rs io.ReadSeeker) ReadSeekCounter {
+	rsc := &readSeekCounter{
+		rs: rs,
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok == true {
+		return readSeekReaderAtCounter{rsc, &counterReaderAt{ra, &rsc.operationCounters}}
+	}
+
+	return rsc
+}
+
+// ReadWriteSeekCloseCounter proxies reads, writes, seeks, and closes and
+// counts them. It is suitable for wrapping files. The counts may be read from
+// another goroutine while I/O is in progress.
+type ReadWriteSeekCloseCounter interface {
+	ReadWriteSeekCloser
+
+	// Stats returns the current counts.
+	Stats() CounterStats
+
+	// Reset resets all counts to zero.
+	Reset()
+}
+
+type readWriteSeekCloseCounter struct {
+	// operationCounters is first so that it is aligned for atomic access on
+	// 32-bit platforms.
+	operationCounters
+
+	rwsc ReadWriteSeekCloser
+}
+
+// Read forwards a read while counting it.
+func (rwscc *readWriteSeekCloseCounter) Read(b []byte) (n int, err error) {
+	n, err = rwscc.rwsc.Read(b)
+	rwscc.addRead(n)
+
+	return n, err
+}
+
+// Write forwards a write while counting it.
+func (rwscc *readWriteSeekCloseCounter) Write(b []byte) (n int, err error) {
+	n, err = rwscc.rwsc.Write(b)
+	rwscc.addWrite(n)
+
+	return n, err
+}
+
+// Seek forwards a seek while counting it.
+func (rwscc *readWriteSeekCloseCounter) Seek(offset int64, whence int) (newOffset int64, err error) {
+	newOffset, err = rwscc.rwsc.Seek(offset, whence)
+	rwscc.addSeek()
+
+	return newOffset, err
+}
+
+// Close closes the wrapped value. The counts are still available afterward.
+func (rwscc *readWriteSeekCloseCounter) Close() error {
+	return rwscc.rwsc.Close()
+}
+
+type readWriteSeekCloseReaderAtCounter struct {
+	*readWriteSeekCloseCounter
+	*counterReaderAt
+}
+
+type readWriteSeekCloseWriterAtCounter struct {
+	*readWriteSeekCloseCounter
+	*counterWriterAt
+}
+
+type readWriteSeekCloseReaderAtWriterAtCounter struct {
+	*readWriteSeekCloseCounter
+	*counterReaderAt
+	*counterWriterAt
+}
+
+// NewReadWriteSeekCloseCounter wraps a `ReadWriteSeekCloser`. The returned
+// value also implements (and counts) `io.ReaderAt` and `io.WriterAt` if the
+// given value does.
+func NewReadWriteSeekCloseCounter(rwsc ReadWriteSeekCloser) ReadWriteSeekCloseCounter {
+	rwscc := &readWriteSeekCloseCounter{
+		rwsc: rwsc,
+	}
+
+	var cra *counterReaderAt
+	if ra, ok := rwsc.(io.ReaderAt); ok == true {
+		cra = &counterReaderAt{ra, &rwscc.operationCounters}
+	}
+
+	var cwa *counterWriterAt
+	if wa, ok := rwsc.(io.WriterAt); ok == true {
+		cwa = &counterWriterAt{wa, &rwscc.operationCounters}
+	}
+
+	if cra != nil && cwa != nil {
+		return readWriteSeekCloseReaderAtWriterAtCounter{rwscc, cra, cwa}
+	} else if cra != nil {
+		return readWriteSeekCloseReaderAtCounter{rwscc, cra}
+	} else if cwa != nil {
+		return readWriteSeekCloseWriterAtCounter{rwscc, cwa}
+	}
+
+	return rwscc
+}
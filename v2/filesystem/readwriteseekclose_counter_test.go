@@ -0,0 +1,169 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestReadSeekCounter(t *testing.T) {
+	rsc := NewReadSeekCounter(bytes.NewReader([]byte("abcdef")))
+
+	_, err := rsc.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(rsc)
+	log.PanicIf(err)
+
+	if string(data) != "cdef" {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	}
+
+	_, err = rsc.(io.ReaderAt).ReadAt(make([]byte, 3), 1)
+	log.PanicIf(err)
+
+	cs := rsc.Stats()
+	if cs.ReadBytes != 4 || cs.ReadAtBytes != 3 || cs.ReadAts != 1 || cs.Seeks != 1 {
+		t.Fatalf("Stats not correct: %s", cs)
+	}
+
+	rsc.Reset()
+
+	if rsc.Stats() != (CounterStats{}) {
+		t.Fatalf("Stats not reset: %s", rsc.Stats())
+	}
+}
+
+func TestReadSeekCounter_ReadAt_NotSupported(t *testing.T) {
+	br := bytes.NewReader([]byte("abc"))
+	rsc := NewReadSeekCounter(testReadSeeker{br, br})
+
+	if _, ok := rsc.(io.ReaderAt); ok == true {
+		t.Fatalf("Counter should not implement ReaderAt.")
+	}
+}
+
+func TestReadWriteSeekCloseCounter_Interfaces(t *testing.T) {
+	rwscc := NewReadWriteSeekCloseCounter(ReadWriteSeekNoopCloser(NewSeekableBuffer()))
+
+	if _, ok := rwscc.(io.ReaderAt); ok == true {
+		t.Fatalf("Counter should not implement ReaderAt.")
+	} else if _, ok := rwscc.(io.WriterAt); ok == true {
+		t.Fatalf("Counter should not implement WriterAt.")
+	}
+
+	rwscc = NewReadWriteSeekCloseCounter(NewSeekableBuffer())
+
+	if _, ok := rwscc.(io.ReaderAt); ok == false {
+		t.Fatalf("Counter should implement ReaderAt.")
+	} else if _, ok := rwscc.(io.WriterAt); ok == false {
+		t.Fatalf("Counter should implement WriterAt.")
+	}
+}
+
+func TestReadWriteSeekCloseCounter(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	rwscc := NewReadWriteSeekCloseCounter(f)
+
+	_, err = rwscc.Write([]byte("abcdef"))
+	log.PanicIf(err)
+
+	_, err = rwscc.(io.WriterAt).WriteAt([]byte("XY"), 1)
+	log.PanicIf(err)
+
+	_, err = rwscc.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(rwscc)
+	log.PanicIf(err)
+
+	if string(data) != "aXYdef" {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	}
+
+	_, err = rwscc.(io.ReaderAt).ReadAt(make([]byte, 2), 4)
+	log.PanicIf(err)
+
+	err = rwscc.Close()
+	log.PanicIf(err)
+
+	expected := CounterStats{
+		ReadBytes:    6,
+		ReadAtBytes:  2,
+		WriteBytes:   6,
+		WriteAtBytes: 2,
+		Reads:        2,
+		ReadAts:      1,
+		Writes:       1,
+		WriteAts:     1,
+		Seeks:        1,
+	}
+
+	if rwscc.Stats() != expected {
+		t.Fatalf("Stats not correct: %s", rwscc.Stats())
+	}
+}
+
+func TestReadWriteSeekCloseCounter_Concurrent(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	rwscc := NewReadWriteSeekCloseCounter(f)
+
+	defer rwscc.Close()
+
+	// Writes are done at separate offsets while another goroutine monitors.
+
+	wg := new(sync.WaitGroup)
+	doneC := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-doneC:
+				return
+			default:
+				rwscc.Stats()
+			}
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				_, err := rwscc.(io.WriterAt).WriteAt([]byte{'a'}, int64(i*100+j))
+				log.PanicIf(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(doneC)
+
+	cs := rwscc.Stats()
+	if cs.WriteAtBytes != 400 || cs.WriteAts != 400 {
+		t.Fatalf("Stats not correct: %s", cs)
+	}
+}
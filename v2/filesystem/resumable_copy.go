@@ -0,0 +1,288 @@
+package rifs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	defaultResumableCopyCheckpointInterval = 8 * 1024 * 1024
+)
+
+// ResumableCopyCheckpoint is the progress of a copy as recorded in the
+// sidecar file.
+type ResumableCopyCheckpoint struct {
+	// Offset is the number of bytes that were completely written.
+	Offset int64 `json:"offset"`
+
+	// SourceSize is the size of the source at the time. A checkpoint is never
+	// used for a source with a different size.
+	SourceSize int64 `json:"source_size"`
+
+	// HashState is the marshaled state of the SHA-256 hash of the first
+	// `Offset` bytes.
+	HashState []byte `json:"hash_state"`
+}
+
+// String returns a descriptive string.
+func (rcc ResumableCopyCheckpoint) String() string {
+	return fmt.Sprintf("ResumableCopyCheckpoint<OFFSET=(%d) SOURCE-SIZE=(%d)>", rcc.Offset, rcc.SourceSize)
+}
+
+// ResumableCopyOptions describes how `ResumableCopy` will copy.
+type ResumableCopyOptions struct {
+	// CheckpointInterval is the number of bytes copied between checkpoints.
+	// Defaults to 8M.
+	CheckpointInterval int64
+
+	// CopyOptions is used for the copy itself.
+	CopyOptions CopyOptions
+}
+
+// ResumableCopyResult describes what `ResumableCopy` did.
+type ResumableCopyResult struct {
+	// ResumedAt is the offset that the copy resumed from. Zero if it started
+	// from the beginning.
+	ResumedAt int64
+
+	// Restarted is true if a checkpoint was found but could not be used
+	// because the source changed or the destination no longer matched.
+	Restarted bool
+
+	// BytesCopied is the number of bytes copied in this call.
+	BytesCopied int64
+
+	// Size is the final size of the destination.
+	Size int64
+
+	// Hash is the SHA-256 of the whole content.
+	Hash []byte
+}
+
+// String returns a descriptive string.
+func (rcr *ResumableCopyResult) String() string {
+	return fmt.Sprintf("ResumableCopyResult<RESUMED-AT=(%d) RESTARTED=[%v] BYTES-COPIED=(%d) SIZE=(%d)>", rcr.ResumedAt, rcr.Restarted, rcr.BytesCopied, rcr.Size)
+}
+
+// truncater is implemented by destinations that can be shortened.
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// syncer is implemented by destinations that can be flushed to storage.
+type syncer interface {
+	Sync() error
+}
+
+// ReadResumableCopyCheckpoint reads the given sidecar file. It returns nil if
+// the file does not exist.
+func ReadResumableCopyCheckpoint(checkpointFilepath string) (rcc *ResumableCopyCheckpoint, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	data, err := ioutil.ReadFile(checkpointFilepath)
+	if err != nil {
+		if os.IsNotExist(err) == true {
+			return nil, nil
+		}
+
+		log.Panic(err)
+	}
+
+	rcc = new(ResumableCopyCheckpoint)
+
+	err = json.Unmarshal(data, rcc)
+	log.PanicIf(err)
+
+	return rcc, nil
+}
+
+// writeResumableCopyCheckpoint atomically replaces the sidecar file.
+func writeResumableCopyCheckpoint(checkpointFilepath string, rcc ResumableCopyCheckpoint) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	af, err := NewAtomicFile(checkpointFilepath, 0644)
+	log.PanicIf(err)
+
+	defer af.Close()
+
+	e := json.NewEncoder(af)
+
+	err = e.Encode(rcc)
+	log.PanicIf(err)
+
+	err = af.Commit()
+	log.PanicIf(err)
+
+	return nil
+}
+
+// restoreCheckpoint returns the hash to continue with if the checkpoint is
+// usable with the given source and destination, or nil otherwise.
+func restoreCheckpoint(rcc *ResumableCopyCheckpoint, sourceSize int64, destination io.ReadSeeker) (h hash.Hash, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if rcc.SourceSize != sourceSize || rcc.Offset > sourceSize || rcc.Offset < 0 {
+		return nil, nil
+	}
+
+	h = sha256.New()
+
+	err = h.(encoding.BinaryUnmarshaler).UnmarshalBinary(rcc.HashState)
+	if err != nil {
+		// Corrupt or from a different algorithm.
+		return nil, nil
+	}
+
+	expected := h.Sum(nil)
+
+	// Make sure that the destination still has what the checkpoint says it
+	// has.
+
+	_, err = destination.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	prefixHash := sha256.New()
+
+	n, err := io.Copy(prefixHash, io.LimitReader(destination, rcc.Offset))
+	log.PanicIf(err)
+
+	if n != rcc.Offset || bytes.Equal(prefixHash.Sum(nil), expected) == false {
+		return nil, nil
+	}
+
+	return h, nil
+}
+
+// ResumableCopy copies the source to the destination, recording its progress
+// in a sidecar checkpoint file as it goes. If the copy is interrupted, calling
+// it again with the same arguments resumes from the last checkpoint, as long
+// as the source has the same size and the destination still has the same
+// prefix. Otherwise, the copy starts over. The checkpoint is removed when the
+// copy completes.
+//
+// If the destination can be truncated (e.g. `*os.File`), it is truncated to
+// the size of the source. If it can be synced, it is synced before every
+// checkpoint.
+func ResumableCopy(ctx context.Context, destination io.ReadWriteSeeker, source io.ReadSeeker, checkpointFilepath string, options ResumableCopyOptions) (result *ResumableCopyResult, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if options.CheckpointInterval <= 0 {
+		options.CheckpointInterval = defaultResumableCopyCheckpointInterval
+	}
+
+	sourceSize, err := source.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	result = new(ResumableCopyResult)
+
+	var h hash.Hash
+
+	rcc, err := ReadResumableCopyCheckpoint(checkpointFilepath)
+	log.PanicIf(err)
+
+	if rcc != nil {
+		h, err = restoreCheckpoint(rcc, sourceSize, destination)
+		log.PanicIf(err)
+
+		if h != nil {
+			result.ResumedAt = rcc.Offset
+		} else {
+			result.Restarted = true
+		}
+	}
+
+	if h == nil {
+		h = sha256.New()
+	}
+
+	offset := result.ResumedAt
+
+	// Read the source through a ReaderAt so that every chunk can be bounded
+	// and still be seeked, which the retry policy requires.
+
+	ra, ok := source.(io.ReaderAt)
+	if ok == false {
+		ra = NewReadSeekerToReaderAt(source)
+	}
+
+	// The verified prefix is off-limits.
+	brws, err := NewBoundedReadWriteSeeker(destination, offset, 0)
+	log.PanicIf(err)
+
+	w := io.MultiWriter(brws, h)
+
+	for offset < sourceSize {
+		r := io.NewSectionReader(ra, offset, options.CheckpointInterval)
+
+		n, err := CopyWithOptions(ctx, w, r, options.CopyOptions)
+		result.BytesCopied += n
+
+		// The last checkpoint stands. Anything that was written after it will
+		// just be written again.
+		log.PanicIf(err)
+
+		if n == 0 {
+			log.Panicf("source ended early: (%d) < (%d)", offset, sourceSize)
+		}
+
+		offset += n
+
+		if s, ok := destination.(syncer); ok == true {
+			err := s.Sync()
+			log.PanicIf(err)
+		}
+
+		hashState, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+		log.PanicIf(err)
+
+		rcc := ResumableCopyCheckpoint{
+			Offset:     offset,
+			SourceSize: sourceSize,
+			HashState:  hashState,
+		}
+
+		err = writeResumableCopyCheckpoint(checkpointFilepath, rcc)
+		log.PanicIf(err)
+	}
+
+	if t, ok := destination.(truncater); ok == true {
+		err := t.Truncate(sourceSize)
+		log.PanicIf(err)
+	}
+
+	err = os.Remove(checkpointFilepath)
+	if err != nil && os.IsNotExist(err) == false {
+		log.Panic(err)
+	}
+
+	result.Size = sourceSize
+	result.Hash = h.Sum(nil)
+
+	return result, nil
+}
@@ -0,0 +1,248 @@
+package rifs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// interruptedFile fails writes once a certain number of bytes have been
+// written, as if the mount had gone away.
+type interruptedFile struct {
+	*os.File
+	remaining int
+}
+
+func (inf *interruptedFile) Write(buffer []byte) (n int, err error) {
+	if len(buffer) > inf.remaining {
+		n, err = inf.File.Write(buffer[:inf.remaining])
+		inf.remaining -= n
+
+		if err == nil {
+			err = errors.New("interrupted")
+		}
+
+		return n, err
+	}
+
+	n, err = inf.File.Write(buffer)
+	inf.remaining -= n
+
+	return n, err
+}
+
+type resumableCopyTest struct {
+	tempPath           string
+	data               []byte
+	destinationPath    string
+	checkpointFilepath string
+}
+
+func newResumableCopyTest() *resumableCopyTest {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	return &resumableCopyTest{
+		tempPath:           tempPath,
+		data:               data,
+		destinationPath:    path.Join(tempPath, "destination"),
+		checkpointFilepath: path.Join(tempPath, "destination.checkpoint"),
+	}
+}
+
+func (rct *resumableCopyTest) openDestination() *os.File {
+	f, err := os.OpenFile(rct.destinationPath, os.O_RDWR|os.O_CREATE, 0644)
+	log.PanicIf(err)
+
+	return f
+}
+
+// interrupt runs a copy that fails after the given number of bytes.
+func (rct *resumableCopyTest) interrupt(limit int, options ResumableCopyOptions) {
+	f := rct.openDestination()
+	defer f.Close()
+
+	inf := &interruptedFile{
+		File:      f,
+		remaining: limit,
+	}
+
+	_, err := ResumableCopy(context.Background(), inf, bytes.NewReader(rct.data), rct.checkpointFilepath, options)
+	if err == nil {
+		log.Panicf("expected interruption")
+	}
+}
+
+func (rct *resumableCopyTest) finish(source []byte, options ResumableCopyOptions) *ResumableCopyResult {
+	f := rct.openDestination()
+	defer f.Close()
+
+	result, err := ResumableCopy(context.Background(), f, bytes.NewReader(source), rct.checkpointFilepath, options)
+	log.PanicIf(err)
+
+	return result
+}
+
+func (rct *resumableCopyTest) check(t *testing.T, source []byte, result *ResumableCopyResult) {
+	written, err := ioutil.ReadFile(rct.destinationPath)
+	log.PanicIf(err)
+
+	expectedHash := sha256.Sum256(source)
+
+	if bytes.Equal(written, source) != true {
+		t.Fatalf("Destination not correct.")
+	} else if bytes.Equal(result.Hash, expectedHash[:]) != true {
+		t.Fatalf("Hash not correct.")
+	} else if result.Size != int64(len(source)) {
+		t.Fatalf("Size not correct: (%d)", result.Size)
+	} else if DoesExist(rct.checkpointFilepath) != false {
+		t.Fatalf("Checkpoint should have been removed.")
+	}
+}
+
+func TestResumableCopy(t *testing.T) {
+	rct := newResumableCopyTest()
+	defer os.RemoveAll(rct.tempPath)
+
+	options := ResumableCopyOptions{
+		CheckpointInterval: 1000,
+	}
+
+	result := rct.finish(rct.data, options)
+
+	if result.ResumedAt != 0 || result.Restarted != false || result.BytesCopied != 10000 {
+		t.Fatalf("Result not correct: %s", result)
+	}
+
+	rct.check(t, rct.data, result)
+}
+
+func TestResumableCopy_Retry(t *testing.T) {
+	rct := newResumableCopyTest()
+	defer os.RemoveAll(rct.tempPath)
+
+	f := rct.openDestination()
+	defer f.Close()
+
+	fr := &flakyReader{
+		rs: bytes.NewReader(rct.data),
+	}
+
+	options := ResumableCopyOptions{
+		CheckpointInterval: 1000,
+		CopyOptions: CopyOptions{
+			Buffer:      make([]byte, 300),
+			RetryPolicy: NewCopyRetryPolicy(2, time.Millisecond),
+		},
+	}
+
+	result, err := ResumableCopy(context.Background(), f, fr, rct.checkpointFilepath, options)
+	log.PanicIf(err)
+
+	if result.BytesCopied != 10000 {
+		t.Fatalf("Result not correct: %s", result)
+	}
+
+	rct.check(t, rct.data, result)
+}
+
+func TestResumableCopy_Resume(t *testing.T) {
+	rct := newResumableCopyTest()
+	defer os.RemoveAll(rct.tempPath)
+
+	options := ResumableCopyOptions{
+		CheckpointInterval: 1000,
+		CopyOptions: CopyOptions{
+			Buffer: make([]byte, 300),
+		},
+	}
+
+	rct.interrupt(3500, options)
+
+	rcc, err := ReadResumableCopyCheckpoint(rct.checkpointFilepath)
+	log.PanicIf(err)
+
+	if rcc == nil || rcc.Offset != 3000 || rcc.SourceSize != 10000 {
+		t.Fatalf("Checkpoint not correct: %v", rcc)
+	}
+
+	result := rct.finish(rct.data, options)
+
+	if result.ResumedAt != 3000 || result.Restarted != false || result.BytesCopied != 7000 {
+		t.Fatalf("Result not correct: %s", result)
+	}
+
+	rct.check(t, rct.data, result)
+}
+
+func TestResumableCopy_PrefixMismatch(t *testing.T) {
+	rct := newResumableCopyTest()
+	defer os.RemoveAll(rct.tempPath)
+
+	options := ResumableCopyOptions{
+		CheckpointInterval: 1000,
+	}
+
+	rct.interrupt(3500, options)
+
+	// Damage the part that was already copied.
+
+	f := rct.openDestination()
+
+	_, err := f.WriteAt([]byte{rct.data[100] + 1}, 100)
+	log.PanicIf(err)
+
+	f.Close()
+
+	result := rct.finish(rct.data, options)
+
+	if result.ResumedAt != 0 || result.Restarted != true || result.BytesCopied != 10000 {
+		t.Fatalf("Result not correct: %s", result)
+	}
+
+	rct.check(t, rct.data, result)
+}
+
+func TestResumableCopy_SourceChanged(t *testing.T) {
+	rct := newResumableCopyTest()
+	defer os.RemoveAll(rct.tempPath)
+
+	options := ResumableCopyOptions{
+		CheckpointInterval: 1000,
+	}
+
+	rct.interrupt(3500, options)
+
+	// A shorter source. The stale tail should also be truncated.
+
+	source := rct.data[:2000]
+
+	result := rct.finish(source, options)
+
+	if result.ResumedAt != 0 || result.Restarted != true {
+		t.Fatalf("Result not correct: %s", result)
+	}
+
+	rct.check(t, source, result)
+}
+
+func TestReadResumableCopyCheckpoint_Missing(t *testing.T) {
+	rcc, err := ReadResumableCopyCheckpoint("/does/not/exist")
+	log.PanicIf(err)
+
+	if rcc != nil {
+		t.Fatalf("Expected no checkpoint.")
+	}
+}
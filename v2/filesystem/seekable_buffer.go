@@ -1,14 +1,28 @@
 package rifs
 
 import (
+	"errors"
 	"io"
 	"os"
 
 	"github.com/dsoprea/go-logging"
 )
 
+var (
+	// ErrNegativeOffset is returned for a negative offset or size.
+	ErrNegativeOffset = errors.New("negative offset")
+)
+
+const (
+	// minimumSeekableBufferReadSize is the least amount of free capacity that
+	// `ReadFrom` reads into.
+	minimumSeekableBufferReadSize = 512
+)
+
 // SeekableBuffer is a simple memory structure that satisfies
-// `io.ReadWriteSeeker`.
+// `io.ReadWriteSeeker` as well as `io.ReaderAt`, `io.WriterAt`,
+// `io.ReaderFrom`, `io.WriterTo`, `io.ByteReader`, `io.ByteWriter`,
+// `io.StringWriter`, and `io.Closer`.
 type SeekableBuffer struct {
 	data     []byte
 	position int64
@@ -37,17 +51,35 @@ func len64(data []byte) int64 {
 	return int64(len(data))
 }
 
-// Bytes returns the underlying slice.
+// Bytes returns the underlying slice. It is only valid until the next
+// modification and changes to it will change the buffer. Use `CopyBytes` for
+// a copy.
 func (sb *SeekableBuffer) Bytes() []byte {
 	return sb.data
 }
 
+// CopyBytes returns a copy of the data.
+func (sb *SeekableBuffer) CopyBytes() []byte {
+	data := make([]byte, len(sb.data))
+	copy(data, sb.data)
+
+	return data
+}
+
+// Reset empties the buffer and rewinds it. The capacity is kept.
+func (sb *SeekableBuffer) Reset() {
+	sb.data = sb.data[:0]
+	sb.position = 0
+}
+
 // Len returns the number of bytes currently stored.
 func (sb *SeekableBuffer) Len() int {
 	return len(sb.data)
 }
 
-// Write does a standard write to the internal slice.
+// Write does a standard write to the internal slice. Overwrites are done in
+// place and appends grow the capacity by doubling, so the cost is proportional
+// to the size of the write.
 func (sb *SeekableBuffer) Write(p []byte) (n int, err error) {
 	defer func() {
 		if state := recover(); state != nil {
@@ -55,27 +87,16 @@ func (sb *SeekableBuffer) Write(p []byte) (n int, err error) {
 		}
 	}()
 
-	// The current position we're already at is past the end of the data we
-	// actually have. Extend our buffer up to our current position.
-	if sb.position > len64(sb.data) {
-		extra := make([]byte, sb.position-len64(sb.data))
-		sb.data = append(sb.data, extra...)
-	}
+	// Unlike a file, even an empty write extends the data up to the
+	// position. This is kept for compatibility.
 
-	positionFromEnd := len64(sb.data) - sb.position
-	tailCount := positionFromEnd - len64(p)
+	end := sb.position + len64(p)
 
-	var tailBytes []byte
-	if tailCount > 0 {
-		tailBytes = sb.data[len64(sb.data)-tailCount:]
-		sb.data = append(sb.data[:sb.position], p...)
-	} else {
-		sb.data = append(sb.data[:sb.position], p...)
+	if end > len64(sb.data) {
+		sb.grow(end)
 	}
 
-	if tailBytes != nil {
-		sb.data = append(sb.data, tailBytes...)
-	}
+	copy(sb.data[sb.position:], p)
 
 	dataSize := len64(p)
 	sb.position += dataSize
@@ -83,6 +104,108 @@ func (sb *SeekableBuffer) Write(p []byte) (n int, err error) {
 	return int(dataSize), nil
 }
 
+// grow extends the data to the given size. The new bytes read as zeros.
+func (sb *SeekableBuffer) grow(size int64) {
+	originalSize := len64(sb.data)
+
+	if size > int64(cap(sb.data)) {
+		capacity := int64(cap(sb.data)) * 2
+		if capacity < size {
+			capacity = size
+		}
+
+		data := make([]byte, size, capacity)
+		copy(data, sb.data)
+
+		sb.data = data
+
+		return
+	}
+
+	// The capacity may still have bytes from before a truncation.
+
+	sb.data = sb.data[:size]
+
+	added := sb.data[originalSize:]
+	for i := range added {
+		added[i] = 0
+	}
+}
+
+// WriteAt writes at the given offset without moving the position. Writing
+// past the end extends the data with zeros.
+func (sb *SeekableBuffer) WriteAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := offset + len64(p)
+
+	if end > len64(sb.data) {
+		sb.grow(end)
+	}
+
+	n = copy(sb.data[offset:], p)
+
+	return n, nil
+}
+
+// WriteByte writes one byte.
+func (sb *SeekableBuffer) WriteByte(c byte) error {
+	_, err := sb.Write([]byte{c})
+	return err
+}
+
+// WriteString writes a string.
+func (sb *SeekableBuffer) WriteString(s string) (n int, err error) {
+	return sb.Write([]byte(s))
+}
+
+// ReadFrom writes everything from the given reader at the current position.
+// EOF is not returned as an error.
+func (sb *SeekableBuffer) ReadFrom(r io.Reader) (n int64, err error) {
+	for {
+		if int64(cap(sb.data))-sb.position < minimumSeekableBufferReadSize {
+			capacity := int64(cap(sb.data)) * 2
+			if capacity < sb.position+minimumSeekableBufferReadSize {
+				capacity = sb.position + minimumSeekableBufferReadSize
+			}
+
+			data := make([]byte, len(sb.data), capacity)
+			copy(data, sb.data)
+
+			sb.data = data
+		}
+
+		readCount, err := r.Read(sb.data[sb.position:cap(sb.data)])
+
+		end := sb.position + int64(readCount)
+		if readCount > 0 && end > len64(sb.data) {
+			originalSize := len64(sb.data)
+			sb.data = sb.data[:end]
+
+			// Clear any gap from having been positioned past the end.
+			if sb.position > originalSize {
+				gap := sb.data[originalSize:sb.position]
+				for i := range gap {
+					gap[i] = 0
+				}
+			}
+		}
+
+		sb.position = end
+		n += int64(readCount)
+
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+	}
+}
+
 // Read does a standard read against the internal slice.
 func (sb *SeekableBuffer) Read(p []byte) (n int, err error) {
 	defer func() {
@@ -91,6 +214,8 @@ func (sb *SeekableBuffer) Read(p []byte) (n int, err error) {
 		}
 	}()
 
+	// Unlike a file, EOF is returned even for an empty read. This is kept
+	// for compatibility.
 	if sb.position >= len64(sb.data) {
 		return 0, io.EOF
 	}
@@ -101,20 +226,73 @@ func (sb *SeekableBuffer) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
-// Truncate either chops or extends the internal buffer.
+// ReadAt reads at the given offset without moving the position. A short read
+// returns `io.EOF`.
+func (sb *SeekableBuffer) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	} else if offset >= len64(sb.data) {
+		return 0, io.EOF
+	}
+
+	n = copy(p, sb.data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// ReadByte reads one byte.
+func (sb *SeekableBuffer) ReadByte() (c byte, err error) {
+	if sb.position >= len64(sb.data) {
+		return 0, io.EOF
+	}
+
+	c = sb.data[sb.position]
+	sb.position++
+
+	return c, nil
+}
+
+// WriteTo writes everything from the current position to the given writer.
+func (sb *SeekableBuffer) WriteTo(w io.Writer) (n int64, err error) {
+	if sb.position >= len64(sb.data) {
+		return 0, nil
+	}
+
+	remaining := sb.data[sb.position:]
+
+	writtenCount, err := w.Write(remaining)
+	sb.position += int64(writtenCount)
+
+	if err != nil {
+		return int64(writtenCount), err
+	} else if writtenCount < len(remaining) {
+		return int64(writtenCount), io.ErrShortWrite
+	}
+
+	return int64(writtenCount), nil
+}
+
+// Close does nothing. The buffer can still be used afterward.
+func (sb *SeekableBuffer) Close() error {
+	return nil
+}
+
+// Truncate either chops or extends (with zeros) the internal buffer. The
+// position is not changed.
 func (sb *SeekableBuffer) Truncate(size int64) (err error) {
-	defer func() {
-		if state := recover(); state != nil {
-			err = log.Wrap(state.(error))
-		}
-	}()
+	if size < 0 {
+		return ErrNegativeOffset
+	}
 
-	sizeInt := int(size)
-	if sizeInt < len(sb.data)-1 {
-		sb.data = sb.data[:sizeInt]
+	if size <= len64(sb.data) {
+		sb.data = sb.data[:size]
 	} else {
-		new := make([]byte, sizeInt-len(sb.data))
-		sb.data = append(sb.data, new...)
+		sb.grow(size)
 	}
 
 	return nil
@@ -3,10 +3,15 @@ package rifs
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"path"
 	"testing"
 
 	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
 )
 
 func TestSeekableBuffer_Write_FromEmpty(t *testing.T) {
@@ -571,3 +576,585 @@ func TestSeekableBuffer_Truncate(t *testing.T) {
 		t.Fatalf("Truncated result was not expected: %v", result)
 	}
 }
+
+func TestSeekableBuffer_Write_TruncateThenSparse(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcdefgh"))
+
+	err := sb.Truncate(2)
+	log.PanicIf(err)
+
+	// Writing past the end within the old capacity must not resurrect the old
+	// bytes.
+
+	_, err = sb.Seek(5, io.SeekStart)
+	log.PanicIf(err)
+
+	_, err = sb.Write([]byte("X"))
+	log.PanicIf(err)
+
+	expected := []byte{'a', 'b', 0, 0, 0, 'X'}
+
+	if bytes.Equal(sb.Bytes(), expected) != true {
+		t.Fatalf("Bytes not correct: %v", sb.Bytes())
+	}
+}
+
+// legacySeekableBuffer is the original implementation, which rebuilt the
+// slice on every write. It is kept to check compatibility and for
+// benchmarking.
+type legacySeekableBuffer struct {
+	data     []byte
+	position int64
+}
+
+func (sb *legacySeekableBuffer) Write(p []byte) (n int, err error) {
+	if sb.position > len64(sb.data) {
+		extra := make([]byte, sb.position-len64(sb.data))
+		sb.data = append(sb.data, extra...)
+	}
+
+	positionFromEnd := len64(sb.data) - sb.position
+	tailCount := positionFromEnd - len64(p)
+
+	var tailBytes []byte
+	if tailCount > 0 {
+		tailBytes = sb.data[len64(sb.data)-tailCount:]
+		sb.data = append(sb.data[:sb.position], p...)
+	} else {
+		sb.data = append(sb.data[:sb.position], p...)
+	}
+
+	if tailBytes != nil {
+		sb.data = append(sb.data, tailBytes...)
+	}
+
+	dataSize := len64(p)
+	sb.position += dataSize
+
+	return int(dataSize), nil
+}
+
+func (sb *legacySeekableBuffer) Read(p []byte) (n int, err error) {
+	if sb.position >= len64(sb.data) {
+		return 0, io.EOF
+	}
+
+	n = copy(p, sb.data[sb.position:])
+	sb.position += int64(n)
+
+	return n, nil
+}
+
+func (sb *legacySeekableBuffer) Seek(offset int64, whence int) (n int64, err error) {
+	if whence == io.SeekStart {
+		sb.position = offset
+	} else if whence == io.SeekEnd {
+		sb.position = len64(sb.data) + offset
+	} else if whence == io.SeekCurrent {
+		sb.position += offset
+	}
+
+	if sb.position < 0 {
+		sb.position = 0
+	}
+
+	return sb.position, nil
+}
+
+func TestSeekableBuffer_Write_MatchesLegacy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	sb := NewSeekableBuffer()
+	legacy := new(legacySeekableBuffer)
+
+	for i := 0; i < 2000; i++ {
+		// Seek somewhere around, or past, the end.
+
+		offset := r.Int63n(len64(legacy.data) + 20)
+
+		_, err := sb.Seek(offset, io.SeekStart)
+		log.PanicIf(err)
+
+		_, err = legacy.Seek(offset, io.SeekStart)
+		log.PanicIf(err)
+
+		// Empty writes are included. They extend the data up to the
+		// position.
+		p := make([]byte, r.Intn(16))
+		r.Read(p)
+
+		n1, err1 := sb.Write(p)
+		n2, err2 := legacy.Write(p)
+
+		if n1 != n2 || err1 != err2 {
+			t.Fatalf("Write (%d) differs: (%d) [%v] != (%d) [%v]", i, n1, err1, n2, err2)
+		} else if bytes.Equal(sb.Bytes(), legacy.data) != true {
+			t.Fatalf("Data diverged after write (%d).", i)
+		}
+
+		// Reads, including empty reads at EOF, behave the same.
+
+		_, err = sb.Seek(offset, io.SeekStart)
+		log.PanicIf(err)
+
+		_, err = legacy.Seek(offset, io.SeekStart)
+		log.PanicIf(err)
+
+		buffer1 := make([]byte, r.Intn(16))
+		buffer2 := make([]byte, len(buffer1))
+
+		n1, err1 = sb.Read(buffer1)
+		n2, err2 = legacy.Read(buffer2)
+
+		if n1 != n2 || err1 != err2 {
+			t.Fatalf("Read (%d) differs: (%d) [%v] != (%d) [%v]", i, n1, err1, n2, err2)
+		} else if bytes.Equal(buffer1, buffer2) != true {
+			t.Fatalf("Read (%d) data differs.", i)
+		}
+	}
+}
+
+func TestSeekableBuffer_Write_Empty_PastEnd(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	_, err := sb.Seek(5, io.SeekStart)
+	log.PanicIf(err)
+
+	n, err := sb.Write(nil)
+	log.PanicIf(err)
+
+	if n != 0 {
+		t.Fatalf("Write count not correct: (%d)", n)
+	} else if bytes.Equal(sb.Bytes(), []byte{'a', 'b', 'c', 0, 0}) != true {
+		t.Fatalf("Empty write did not extend the buffer: %v", sb.Bytes())
+	}
+}
+
+func TestSeekableBuffer_Read_Empty_Eof(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	n, err := sb.Read(nil)
+	if n != 0 || err != nil {
+		t.Fatalf("Empty read before EOF not correct: (%d) [%v]", n, err)
+	}
+
+	_, err = sb.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	n, err = sb.Read(nil)
+	if n != 0 || err != io.EOF {
+		t.Fatalf("Empty read at EOF not correct: (%d) [%v]", n, err)
+	}
+}
+
+func benchmarkSeekableBufferPatches(b *testing.B, ws io.WriteSeeker) {
+	r := rand.New(rand.NewSource(1))
+	patch := make([]byte, 8)
+
+	_, err := ws.Write(make([]byte, 64*1024))
+	log.PanicIf(err)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := ws.Seek(r.Int63n(64*1024-8), io.SeekStart)
+		log.PanicIf(err)
+
+		_, err = ws.Write(patch)
+		log.PanicIf(err)
+	}
+}
+
+func BenchmarkSeekableBuffer_Patch(b *testing.B) {
+	benchmarkSeekableBufferPatches(b, NewSeekableBuffer())
+}
+
+func BenchmarkSeekableBuffer_Patch_Legacy(b *testing.B) {
+	benchmarkSeekableBufferPatches(b, new(legacySeekableBuffer))
+}
+
+func benchmarkSeekableBufferAppends(b *testing.B, w io.Writer) {
+	chunk := make([]byte, 64)
+
+	for i := 0; i < b.N; i++ {
+		_, err := w.Write(chunk)
+		log.PanicIf(err)
+	}
+}
+
+func BenchmarkSeekableBuffer_Append(b *testing.B) {
+	benchmarkSeekableBufferAppends(b, NewSeekableBuffer())
+}
+
+func BenchmarkSeekableBuffer_Append_Legacy(b *testing.B) {
+	benchmarkSeekableBufferAppends(b, new(legacySeekableBuffer))
+}
+
+func benchmarkSeekableBufferSparse(b *testing.B, ws io.WriteSeeker) {
+	chunk := make([]byte, 64)
+
+	for i := 0; i < b.N; i++ {
+		_, err := ws.Seek(128, io.SeekEnd)
+		log.PanicIf(err)
+
+		_, err = ws.Write(chunk)
+		log.PanicIf(err)
+	}
+}
+
+func BenchmarkSeekableBuffer_Sparse(b *testing.B) {
+	benchmarkSeekableBufferSparse(b, NewSeekableBuffer())
+}
+
+func BenchmarkSeekableBuffer_Sparse_Legacy(b *testing.B) {
+	benchmarkSeekableBufferSparse(b, new(legacySeekableBuffer))
+}
+
+func TestSeekableBuffer_Truncate_Extend(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("hello"))
+
+	// This used to fail (one less than the length).
+	err := sb.Truncate(4)
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), []byte("hell")) != true {
+		t.Fatalf("Truncated result was not expected: %v", sb.Bytes())
+	}
+
+	err = sb.Truncate(4)
+	log.PanicIf(err)
+
+	err = sb.Truncate(6)
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), []byte("hell\000\000")) != true {
+		t.Fatalf("Extended result was not expected: %v", sb.Bytes())
+	}
+
+	err = sb.Truncate(-1)
+	if err != ErrNegativeOffset {
+		t.Fatalf("Expected negative-offset error: %v", err)
+	}
+}
+
+func TestSeekableBuffer_ReadAt(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcdef"))
+
+	buffer := make([]byte, 4)
+
+	n, err := sb.ReadAt(buffer, 1)
+	log.PanicIf(err)
+
+	if n != 4 || string(buffer) != "bcde" {
+		t.Fatalf("ReadAt not correct: (%d) [%s]", n, string(buffer))
+	}
+
+	n, err = sb.ReadAt(buffer, 4)
+	if err != io.EOF {
+		t.Fatalf("Expected EOF for short read: %v", err)
+	} else if n != 2 || string(buffer[:n]) != "ef" {
+		t.Fatalf("Short ReadAt not correct: (%d) [%s]", n, string(buffer[:n]))
+	}
+
+	_, err = sb.ReadAt(buffer, -1)
+	if err != ErrNegativeOffset {
+		t.Fatalf("Expected negative-offset error: %v", err)
+	}
+
+	// The position is not affected.
+
+	position, err := sb.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 0 {
+		t.Fatalf("Position moved: (%d)", position)
+	}
+}
+
+func TestSeekableBuffer_WriteAt(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	_, err := sb.WriteAt([]byte("XY"), 1)
+	log.PanicIf(err)
+
+	_, err = sb.WriteAt([]byte("Z"), 5)
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), []byte("aXY\000\000Z")) != true {
+		t.Fatalf("WriteAt result not correct: %v", sb.Bytes())
+	}
+
+	position, err := sb.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 0 {
+		t.Fatalf("Position moved: (%d)", position)
+	}
+}
+
+func TestSeekableBuffer_ReadFrom(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcdef"))
+
+	_, err := sb.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	data := bytes.Repeat([]byte("x"), 2000)
+
+	n, err := sb.ReadFrom(bytes.NewReader(data))
+	log.PanicIf(err)
+
+	expected := append([]byte("ab"), data...)
+
+	if n != 2000 {
+		t.Fatalf("Count not correct: (%d)", n)
+	} else if bytes.Equal(sb.Bytes(), expected) != true {
+		t.Fatalf("Data not correct.")
+	}
+
+	// Past the end.
+
+	_, err = sb.Seek(2, io.SeekEnd)
+	log.PanicIf(err)
+
+	_, err = sb.ReadFrom(bytes.NewReader([]byte("z")))
+	log.PanicIf(err)
+
+	expected = append(expected, 0, 0, 'z')
+
+	if bytes.Equal(sb.Bytes(), expected) != true {
+		t.Fatalf("Data not correct after sparse ReadFrom.")
+	}
+
+	// Like a file, nothing is extended if nothing is read.
+
+	_, err = sb.Seek(10, io.SeekEnd)
+	log.PanicIf(err)
+
+	_, err = sb.ReadFrom(bytes.NewReader(nil))
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), expected) != true {
+		t.Fatalf("Empty ReadFrom changed the data.")
+	}
+}
+
+func TestSeekableBuffer_WriteTo(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcdef"))
+
+	_, err := sb.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	n, err := sb.WriteTo(b)
+	log.PanicIf(err)
+
+	if n != 4 || b.String() != "cdef" {
+		t.Fatalf("WriteTo not correct: (%d) [%s]", n, b.String())
+	}
+
+	n, err = sb.WriteTo(b)
+	log.PanicIf(err)
+
+	if n != 0 {
+		t.Fatalf("Nothing should have been left: (%d)", n)
+	}
+}
+
+func TestSeekableBuffer_Bytes_And_Strings(t *testing.T) {
+	sb := NewSeekableBuffer()
+
+	err := sb.WriteByte('a')
+	log.PanicIf(err)
+
+	_, err = sb.WriteString("bc")
+	log.PanicIf(err)
+
+	_, err = sb.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered := make([]byte, 0)
+
+	for {
+		c, err := sb.ReadByte()
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		recovered = append(recovered, c)
+	}
+
+	if string(recovered) != "abc" {
+		t.Fatalf("Bytes not correct: [%s]", string(recovered))
+	}
+}
+
+func TestSeekableBuffer_CopyBytes(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	data := sb.CopyBytes()
+	data[0] = 'X'
+
+	if bytes.Equal(sb.Bytes(), []byte("abc")) != true {
+		t.Fatalf("Copy aliases the buffer.")
+	}
+}
+
+func TestSeekableBuffer_Reset(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	_, err := sb.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	sb.Reset()
+
+	if sb.Len() != 0 {
+		t.Fatalf("Buffer not empty: (%d)", sb.Len())
+	}
+
+	position, err := sb.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 0 {
+		t.Fatalf("Position not reset: (%d)", position)
+	}
+
+	// Nothing old should come back.
+
+	err = sb.Truncate(3)
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), []byte{0, 0, 0}) != true {
+		t.Fatalf("Old data came back: %v", sb.Bytes())
+	}
+}
+
+// TestSeekableBuffer_Conformance does the same random operations on a
+// SeekableBuffer and a file and checks that they behave the same.
+func TestSeekableBuffer_Conformance(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	f, err := os.Create(path.Join(tempPath, "file"))
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	sb := NewSeekableBuffer()
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 3000; i++ {
+		p := make([]byte, r.Intn(32))
+		r.Read(p)
+
+		offset := r.Int63n(int64(sb.Len()) + 32)
+
+		operation := r.Intn(6)
+
+		var sbN, fN int64
+		var sbErr, fErr error
+
+		// Empty writes and reads keep the buffer's original behavior, which
+		// differs from a file's. `TestSeekableBuffer_Write_MatchesLegacy`
+		// covers them.
+		if (operation == 0 || operation == 1) && len(p) == 0 {
+			continue
+		}
+
+		if operation == 0 {
+			var n int
+
+			n, sbErr = sb.Write(p)
+			sbN = int64(n)
+
+			n, fErr = f.Write(p)
+			fN = int64(n)
+		} else if operation == 1 {
+			sbBuffer := make([]byte, len(p))
+			fBuffer := make([]byte, len(p))
+
+			var n int
+
+			n, sbErr = sb.Read(sbBuffer)
+			sbN = int64(n)
+
+			n, fErr = f.Read(fBuffer)
+			fN = int64(n)
+
+			if bytes.Equal(sbBuffer, fBuffer) != true {
+				t.Fatalf("Read (%d) data differs.", i)
+			}
+		} else if operation == 2 {
+			// The buffer clamps negative positions, which the file rejects,
+			// so only seek relative to the current position or the end when
+			// it stays positive.
+
+			position, err := sb.Seek(0, io.SeekCurrent)
+			log.PanicIf(err)
+
+			whence := io.SeekStart
+			relative := offset
+
+			if r.Intn(2) == 0 && position+offset-16 >= 0 {
+				whence = io.SeekCurrent
+				relative = offset - 16
+			} else if r.Intn(2) == 0 && int64(sb.Len())+offset-16 >= 0 {
+				whence = io.SeekEnd
+				relative = offset - 16
+			}
+
+			sbN, sbErr = sb.Seek(relative, whence)
+			fN, fErr = f.Seek(relative, whence)
+		} else if operation == 3 {
+			sbBuffer := make([]byte, len(p))
+			fBuffer := make([]byte, len(p))
+
+			var n int
+
+			n, sbErr = sb.ReadAt(sbBuffer, offset)
+			sbN = int64(n)
+
+			n, fErr = f.ReadAt(fBuffer, offset)
+			fN = int64(n)
+
+			if bytes.Equal(sbBuffer, fBuffer) != true {
+				t.Fatalf("ReadAt (%d) data differs.", i)
+			}
+		} else if operation == 4 {
+			var n int
+
+			n, sbErr = sb.WriteAt(p, offset)
+			sbN = int64(n)
+
+			n, fErr = f.WriteAt(p, offset)
+			fN = int64(n)
+		} else {
+			sbErr = sb.Truncate(offset)
+			fErr = f.Truncate(offset)
+		}
+
+		if sbN != fN || sbErr != fErr {
+			t.Fatalf("Operation (%d) of type (%d) differs: (%d) [%v] != (%d) [%v]", i, operation, sbN, sbErr, fN, fErr)
+		}
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	log.PanicIf(err)
+
+	if bytes.Equal(sb.Bytes(), data) != true {
+		t.Fatalf("Final content differs.")
+	}
+}
+
+func TestSeekableBuffer_ReadAt_Conformance(t *testing.T) {
+	data := []byte("some test data")
+	sb := NewSeekableBufferWithBytes(data)
+
+	err := ritesting.CheckReaderAt(sb, data)
+	log.PanicIf(err)
+}
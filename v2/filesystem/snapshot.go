@@ -0,0 +1,417 @@
+package rifs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/crypto"
+)
+
+// SnapshotEntry describes one path in a snapshot.
+type SnapshotEntry struct {
+	// Filepath is relative to the root of the snapshot and slash-separated.
+	Filepath string `json:"filepath"`
+
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mtime"`
+	Mode    os.FileMode `json:"mode"`
+
+	// Device and Inode are zero if not supported by the platform or
+	// filesystem.
+	Device uint64 `json:"device,omitempty"`
+	Inode  uint64 `json:"inode,omitempty"`
+
+	// Hash is the content hash. Only populated for regular files and only if
+	// requested.
+	Hash []byte `json:"hash,omitempty"`
+}
+
+// IsDir returns true if the entry is a directory.
+func (se SnapshotEntry) IsDir() bool {
+	return se.Mode.IsDir()
+}
+
+// FileInfo returns a `os.FileInfo` for the entry.
+func (se SnapshotEntry) FileInfo() os.FileInfo {
+	return &SimpleFileInfo{
+		filename: path.Base(se.Filepath),
+		isDir:    se.IsDir(),
+		size:     se.Size,
+		mode:     se.Mode,
+		modTime:  se.ModTime,
+	}
+}
+
+// String returns a descriptive string.
+func (se SnapshotEntry) String() string {
+	return fmt.Sprintf("SnapshotEntry<PATH=[%s] SIZE=(%d) MODE=[%s] INODE=(%d)>", se.Filepath, se.Size, se.Mode, se.Inode)
+}
+
+// Snapshot is a serializable, point-in-time listing of a tree.
+type Snapshot struct {
+	RootPath  string    `json:"root_path"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Entries is keyed by the relative path.
+	Entries map[string]SnapshotEntry `json:"entries"`
+}
+
+// SnapshotOptions describes how to build a snapshot.
+type SnapshotOptions struct {
+	// Filter is an optional predicate that can exclude paths.
+	Filter FileListFilterPredicate
+
+	// ErrorPolicy decides what happens when a path can not be processed. If
+	// not provided, building the snapshot fails on the first error.
+	ErrorPolicy PathErrorPolicy
+
+	// Filesystem is the filesystem to scan. If not provided, the OS is used.
+	Filesystem Filesystem
+
+	// HashFactory, if provided, returns a new hash with which to calculate
+	// the content-hash of each regular file (e.g. `sha1.New`).
+	HashFactory func() hash.Hash
+}
+
+// relativeWalkPath returns the given walked path relative to the root that the
+// walk was started at.
+func relativeWalkPath(rootPath, filepath string) string {
+	if rootPath == "." {
+		return filepath
+	}
+
+	return strings.TrimPrefix(filepath, strings.TrimSuffix(rootPath, "/")+"/")
+}
+
+// BuildSnapshot scans the given path and returns a snapshot.
+func BuildSnapshot(rootPath string, options SnapshotOptions) (snapshot *Snapshot, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	rootPath = path.Clean(rootPath)
+	fsys := filesystemOrDefault(options.Filesystem)
+
+	snapshot = &Snapshot{
+		RootPath:  rootPath,
+		CreatedAt: time.Now(),
+		Entries:   make(map[string]SnapshotEntry),
+	}
+
+	listOptions := ListFilesOptions{
+		Filter:      options.Filter,
+		ErrorPolicy: options.ErrorPolicy,
+		Filesystem:  fsys,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(rootPath, listOptions)
+	log.PanicIf(err)
+
+	visited := make([]VisitedFile, 0)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			if event.File != nil {
+				visited = append(visited, *event.File)
+			}
+		}
+	}
+
+	// Hashing can fail for the same reasons as the scan, so we defer to the
+	// same policy.
+	fw := &fileWalker{
+		options: listOptions,
+	}
+
+	for _, vf := range visited {
+		entry := SnapshotEntry{
+			Filepath: relativeWalkPath(rootPath, vf.Filepath),
+			Size:     vf.Info.Size(),
+			ModTime:  vf.Info.ModTime(),
+			Mode:     vf.Info.Mode(),
+		}
+
+		if fsi, ok := getFileStatInfo(vf.Info); ok == true {
+			entry.Device = fsi.Device
+			entry.Inode = fsi.Inode
+		}
+
+		if options.HashFactory != nil && vf.Info.Mode().IsRegular() == true {
+			ok, err := fw.attempt(vf.Filepath, "hash", func() (err error) {
+				entry.Hash, err = hashFile(fsys, vf.Filepath, options.HashFactory())
+				return err
+			})
+
+			log.PanicIf(err)
+
+			if ok == false {
+				continue
+			}
+		}
+
+		snapshot.Entries[entry.Filepath] = entry
+	}
+
+	return snapshot, nil
+}
+
+// hashFile returns the hash of the content of the given file.
+func hashFile(fsys Filesystem, filepath string, h hash.Hash) (sum []byte, err error) {
+	f, err := fsys.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	rhp := ricrypto.NewReaderHashProxy(f, h)
+
+	_, err = io.Copy(ioutil.Discard, rhp)
+	if err != nil {
+		return nil, err
+	}
+
+	return rhp.Sum(), nil
+}
+
+// Write serializes the snapshot as JSON.
+func (snapshot *Snapshot) Write(w io.Writer) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	e := json.NewEncoder(w)
+
+	err = e.Encode(snapshot)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// ReadSnapshot deserializes a snapshot that was written with `Write`.
+func ReadSnapshot(r io.Reader) (snapshot *Snapshot, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	d := json.NewDecoder(r)
+
+	snapshot = new(Snapshot)
+
+	err = d.Decode(snapshot)
+	log.PanicIf(err)
+
+	return snapshot, nil
+}
+
+// SnapshotModification describes an entry that changed in-place.
+type SnapshotModification struct {
+	Before SnapshotEntry
+	After  SnapshotEntry
+}
+
+// SnapshotRename describes an entry that moved.
+type SnapshotRename struct {
+	From SnapshotEntry
+	To   SnapshotEntry
+}
+
+// SnapshotDiff describes the changes between two snapshots. Each list is
+// sorted by path.
+type SnapshotDiff struct {
+	Added    []SnapshotEntry
+	Removed  []SnapshotEntry
+	Modified []SnapshotModification
+	Renamed  []SnapshotRename
+}
+
+// IsEmpty returns true if there were no changes.
+func (sd *SnapshotDiff) IsEmpty() bool {
+	return len(sd.Added) == 0 && len(sd.Removed) == 0 && len(sd.Modified) == 0 && len(sd.Renamed) == 0
+}
+
+// String returns a descriptive string.
+func (sd *SnapshotDiff) String() string {
+	return fmt.Sprintf("SnapshotDiff<ADDED=(%d) REMOVED=(%d) MODIFIED=(%d) RENAMED=(%d)>", len(sd.Added), len(sd.Removed), len(sd.Modified), len(sd.Renamed))
+}
+
+// isModified returns true if the entry changed in-place. Directory sizes and
+// times change whenever their children do, which we'll already have reported,
+// so only their modes are compared.
+func isModified(before, after SnapshotEntry) bool {
+	if before.Mode != after.Mode {
+		return true
+	} else if after.IsDir() == true {
+		return false
+	}
+
+	if before.Size != after.Size || before.ModTime.Equal(after.ModTime) == false {
+		return true
+	}
+
+	if before.Inode != 0 && after.Inode != 0 && (before.Inode != after.Inode || before.Device != after.Device) {
+		return true
+	}
+
+	if before.Hash != nil && after.Hash != nil && bytes.Equal(before.Hash, after.Hash) == false {
+		return true
+	}
+
+	return false
+}
+
+// renameKeys returns the keys by which a removed entry can be matched with an
+// added one. Since inodes can be recycled, a file must also have the same size
+// and time to be matched by inode. Files can also be matched by size and
+// content-hash if hashes were collected.
+func renameKeys(se SnapshotEntry) (inodeKey, hashKey string) {
+	if se.Inode != 0 {
+		if se.IsDir() == true {
+			inodeKey = fmt.Sprintf("D/%d/%d", se.Device, se.Inode)
+		} else {
+			inodeKey = fmt.Sprintf("F/%d/%d/%d/%d", se.Device, se.Inode, se.Size, se.ModTime.UnixNano())
+		}
+	}
+
+	if se.IsDir() == false && se.Size > 0 && se.Hash != nil {
+		hashKey = fmt.Sprintf("%d/%x", se.Size, se.Hash)
+	}
+
+	return inodeKey, hashKey
+}
+
+// sortedSnapshotPaths returns the keys of the given entries in order.
+func sortedSnapshotPaths(entries map[string]SnapshotEntry) []string {
+	paths := make([]string, 0, len(entries))
+	for filepath := range entries {
+		paths = append(paths, filepath)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// DiffSnapshots returns the changes needed to get from `before` to `after`.
+// Renames are detected by inode or, if hashes were collected, by content.
+func DiffSnapshots(before, after *Snapshot) *SnapshotDiff {
+	sd := &SnapshotDiff{
+		Added:    make([]SnapshotEntry, 0),
+		Removed:  make([]SnapshotEntry, 0),
+		Modified: make([]SnapshotModification, 0),
+		Renamed:  make([]SnapshotRename, 0),
+	}
+
+	removed := make([]SnapshotEntry, 0)
+	for _, filepath := range sortedSnapshotPaths(before.Entries) {
+		beforeEntry := before.Entries[filepath]
+
+		afterEntry, found := after.Entries[filepath]
+		if found == false {
+			removed = append(removed, beforeEntry)
+		} else if isModified(beforeEntry, afterEntry) == true {
+			sm := SnapshotModification{
+				Before: beforeEntry,
+				After:  afterEntry,
+			}
+
+			sd.Modified = append(sd.Modified, sm)
+		}
+	}
+
+	// Pair new entries with removed ones where we can.
+
+	byInode := make(map[string][]int)
+	byHash := make(map[string][]int)
+
+	for i, removedEntry := range removed {
+		inodeKey, hashKey := renameKeys(removedEntry)
+
+		if inodeKey != "" {
+			byInode[inodeKey] = append(byInode[inodeKey], i)
+		}
+
+		if hashKey != "" {
+			byHash[hashKey] = append(byHash[hashKey], i)
+		}
+	}
+
+	matched := make(map[int]bool)
+
+	// findMatch returns the first unmatched, removed entry for the given key.
+	findMatch := func(index map[string][]int, key string) int {
+		if key == "" {
+			return -1
+		}
+
+		for _, i := range index[key] {
+			if matched[i] == false {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	for _, filepath := range sortedSnapshotPaths(after.Entries) {
+		if _, found := before.Entries[filepath]; found == true {
+			continue
+		}
+
+		afterEntry := after.Entries[filepath]
+		inodeKey, hashKey := renameKeys(afterEntry)
+
+		i := findMatch(byInode, inodeKey)
+		if i == -1 {
+			i = findMatch(byHash, hashKey)
+		}
+
+		if i == -1 {
+			sd.Added = append(sd.Added, afterEntry)
+			continue
+		}
+
+		sr := SnapshotRename{
+			From: removed[i],
+			To:   afterEntry,
+		}
+
+		sd.Renamed = append(sd.Renamed, sr)
+		matched[i] = true
+	}
+
+	for i, removedEntry := range removed {
+		if matched[i] == false {
+			sd.Removed = append(sd.Removed, removedEntry)
+		}
+	}
+
+	return sd
+}
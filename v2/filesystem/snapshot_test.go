@@ -0,0 +1,213 @@
+package rifs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"crypto/sha1"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestBuildSnapshot(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.MkdirAll("aa/bb", 0755)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("aa/bb/file2", []byte("defg"), 0600)
+	log.PanicIf(err)
+
+	options := SnapshotOptions{
+		Filesystem:  mfs,
+		HashFactory: sha1.New,
+	}
+
+	snapshot, err := BuildSnapshot("aa", options)
+	log.PanicIf(err)
+
+	paths := sortedSnapshotPaths(snapshot.Entries)
+
+	if reflect.DeepEqual(paths, []string{"bb", "bb/file2", "file1"}) != true {
+		t.Fatalf("Snapshot paths not correct: %v", paths)
+	}
+
+	entry := snapshot.Entries["bb/file2"]
+
+	expectedHash := sha1.Sum([]byte("defg"))
+
+	if entry.Size != 4 {
+		t.Fatalf("Size not correct: (%d)", entry.Size)
+	} else if entry.Mode != 0600 {
+		t.Fatalf("Mode not correct: %v", entry.Mode)
+	} else if bytes.Equal(entry.Hash, expectedHash[:]) != true {
+		t.Fatalf("Hash not correct: %x", entry.Hash)
+	} else if snapshot.Entries["bb"].Hash != nil {
+		t.Fatalf("Directories should not be hashed.")
+	}
+}
+
+func TestSnapshot_WriteAndRead(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.WriteFile("file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	options := SnapshotOptions{
+		Filesystem:  mfs,
+		HashFactory: sha1.New,
+	}
+
+	snapshot, err := BuildSnapshot(".", options)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = snapshot.Write(b)
+	log.PanicIf(err)
+
+	recovered, err := ReadSnapshot(b)
+	log.PanicIf(err)
+
+	if recovered.RootPath != snapshot.RootPath {
+		t.Fatalf("Root path not correct: [%s]", recovered.RootPath)
+	} else if len(recovered.Entries) != 1 {
+		t.Fatalf("Entry count not correct: (%d)", len(recovered.Entries))
+	}
+
+	original := snapshot.Entries["file1"]
+	entry := recovered.Entries["file1"]
+
+	if entry.Filepath != original.Filepath || entry.Size != original.Size || entry.Mode != original.Mode {
+		t.Fatalf("Recovered entry not correct: %s", entry)
+	} else if entry.ModTime.Equal(original.ModTime) != true {
+		t.Fatalf("Recovered time not correct: %v", entry.ModTime)
+	} else if bytes.Equal(entry.Hash, original.Hash) != true {
+		t.Fatalf("Recovered hash not correct.")
+	}
+
+	if DiffSnapshots(snapshot, recovered).IsEmpty() != true {
+		t.Fatalf("Recovered snapshot is not identical.")
+	}
+}
+
+func TestDiffSnapshots_RenameByHash(t *testing.T) {
+	mfs := NewMemoryFilesystem()
+
+	err := mfs.WriteFile("file1", []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("file2", []byte("defg"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("file3", []byte("hij"), 0644)
+	log.PanicIf(err)
+
+	options := SnapshotOptions{
+		Filesystem:  mfs,
+		HashFactory: sha1.New,
+	}
+
+	before, err := BuildSnapshot(".", options)
+	log.PanicIf(err)
+
+	err = mfs.Rename("file1", "file1b")
+	log.PanicIf(err)
+
+	err = mfs.Remove("file2")
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("file3", []byte("hijk"), 0644)
+	log.PanicIf(err)
+
+	err = mfs.WriteFile("file4", []byte("lmn"), 0644)
+	log.PanicIf(err)
+
+	after, err := BuildSnapshot(".", options)
+	log.PanicIf(err)
+
+	sd := DiffSnapshots(before, after)
+
+	if len(sd.Added) != 1 || sd.Added[0].Filepath != "file4" {
+		t.Fatalf("Added not correct: %v", sd.Added)
+	} else if len(sd.Removed) != 1 || sd.Removed[0].Filepath != "file2" {
+		t.Fatalf("Removed not correct: %v", sd.Removed)
+	} else if len(sd.Modified) != 1 || sd.Modified[0].After.Filepath != "file3" {
+		t.Fatalf("Modified not correct: %v", sd.Modified)
+	} else if len(sd.Renamed) != 1 || sd.Renamed[0].From.Filepath != "file1" || sd.Renamed[0].To.Filepath != "file1b" {
+		t.Fatalf("Renamed not correct: %v", sd.Renamed)
+	}
+}
+
+func TestDiffSnapshots_RenameByInode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Inodes are not available.")
+	}
+
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "file1"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "file2"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	before, err := BuildSnapshot(tempPath, SnapshotOptions{})
+	log.PanicIf(err)
+
+	if before.Entries["file1"].Inode == 0 {
+		t.Fatalf("Inode was not collected.")
+	}
+
+	// Without hashes, the content is not considered, so the identical file
+	// that was removed can not be mistaken for the renamed one.
+
+	err = os.Rename(path.Join(tempPath, "file2"), path.Join(tempPath, "file3"))
+	log.PanicIf(err)
+
+	err = os.Remove(path.Join(tempPath, "file1"))
+	log.PanicIf(err)
+
+	after, err := BuildSnapshot(tempPath, SnapshotOptions{})
+	log.PanicIf(err)
+
+	sd := DiffSnapshots(before, after)
+
+	if len(sd.Added) != 0 {
+		t.Fatalf("Added not correct: %v", sd.Added)
+	} else if len(sd.Removed) != 1 || sd.Removed[0].Filepath != "file1" {
+		t.Fatalf("Removed not correct: %v", sd.Removed)
+	} else if len(sd.Renamed) != 1 || sd.Renamed[0].From.Filepath != "file2" || sd.Renamed[0].To.Filepath != "file3" {
+		t.Fatalf("Renamed not correct: %v", sd.Renamed)
+	}
+}
+
+func TestDiffSnapshots_DirectoryTimesIgnored(t *testing.T) {
+	before := &Snapshot{
+		Entries: map[string]SnapshotEntry{
+			"aa": {Filepath: "aa", Mode: os.ModeDir | 0755, ModTime: time.Unix(1, 0)},
+		},
+	}
+
+	after := &Snapshot{
+		Entries: map[string]SnapshotEntry{
+			"aa": {Filepath: "aa", Mode: os.ModeDir | 0755, ModTime: time.Unix(2, 0)},
+		},
+	}
+
+	if DiffSnapshots(before, after).IsEmpty() != true {
+		t.Fatalf("Directory time change should not be reported.")
+	}
+}
@@ -0,0 +1,300 @@
+package rifs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	defaultSpillingBufferThreshold = 4 * 1024 * 1024
+)
+
+var (
+	// ErrSpillingBufferClosed is returned when a `SpillingBuffer` is used
+	// after it was closed.
+	ErrSpillingBufferClosed = errors.New("spilling buffer closed")
+)
+
+// spillingBufferMetrics are shared by all spilling buffers.
+var spillingBufferMetrics struct {
+	buffers      int64
+	spills       int64
+	spilledBytes int64
+}
+
+// SpillingBufferMetrics describes how often buffers have spilled to disk.
+type SpillingBufferMetrics struct {
+	// Buffers is the number of buffers created.
+	Buffers int64
+
+	// Spills is the number of buffers that spilled to disk.
+	Spills int64
+
+	// SpilledBytes is the number of bytes that were in memory at the time
+	// that each buffer spilled.
+	SpilledBytes int64
+}
+
+// String returns a descriptive string.
+func (sbm SpillingBufferMetrics) String() string {
+	return fmt.Sprintf("SpillingBufferMetrics<BUFFERS=(%d) SPILLS=(%d) SPILLED-BYTES=(%d)>", sbm.Buffers, sbm.Spills, sbm.SpilledBytes)
+}
+
+// GetSpillingBufferMetrics returns the metrics for all spilling buffers.
+func GetSpillingBufferMetrics() SpillingBufferMetrics {
+	return SpillingBufferMetrics{
+		Buffers:      atomic.LoadInt64(&spillingBufferMetrics.buffers),
+		Spills:       atomic.LoadInt64(&spillingBufferMetrics.spills),
+		SpilledBytes: atomic.LoadInt64(&spillingBufferMetrics.spilledBytes),
+	}
+}
+
+// SpillingBufferOptions describes when and where a `SpillingBuffer` spills.
+type SpillingBufferOptions struct {
+	// Threshold is the size beyond which the data is moved to a temporary
+	// file. Defaults to 4M.
+	Threshold int64
+
+	// TempPath is the directory to create the temporary file in. Defaults to
+	// the system temporary directory.
+	TempPath string
+}
+
+// spillingBufferBacking is implemented by both `SeekableBuffer` and
+// `*os.File`.
+type spillingBufferBacking interface {
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// SpillingBuffer has the same semantics as `SeekableBuffer` but moves its data
+// to a temporary file once it grows beyond a threshold. `Close` must be called
+// in order to remove the file.
+type SpillingBuffer struct {
+	options SpillingBufferOptions
+
+	backing spillingBufferBacking
+	f       *os.File
+
+	position int64
+	size     int64
+	closed   bool
+}
+
+// NewSpillingBuffer returns a new SpillingBuffer instance.
+func NewSpillingBuffer(options SpillingBufferOptions) *SpillingBuffer {
+	if options.Threshold <= 0 {
+		options.Threshold = defaultSpillingBufferThreshold
+	}
+
+	atomic.AddInt64(&spillingBufferMetrics.buffers, 1)
+
+	return &SpillingBuffer{
+		options: options,
+		backing: NewSeekableBuffer(),
+	}
+}
+
+// String returns a descriptive string.
+func (sb *SpillingBuffer) String() string {
+	return fmt.Sprintf("SpillingBuffer<SIZE=(%d) POSITION=(%d) SPILLED=[%v]>", sb.size, sb.position, sb.IsSpilled())
+}
+
+// IsSpilled returns true if the data has been moved to a file.
+func (sb *SpillingBuffer) IsSpilled() bool {
+	return sb.f != nil
+}
+
+// Filepath returns the path of the temporary file, or an empty string if the
+// data has not spilled.
+func (sb *SpillingBuffer) Filepath() string {
+	if sb.f == nil {
+		return ""
+	}
+
+	return sb.f.Name()
+}
+
+// Len returns the number of bytes currently stored.
+func (sb *SpillingBuffer) Len() int64 {
+	return sb.size
+}
+
+// spillIfNeeded moves the data to a temporary file if the given size is
+// beyond the threshold.
+func (sb *SpillingBuffer) spillIfNeeded(size int64) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if sb.f != nil || size <= sb.options.Threshold {
+		return nil
+	}
+
+	f, err := ioutil.TempFile(sb.options.TempPath, "spilling-buffer-")
+	log.PanicIf(err)
+
+	data := sb.backing.(*SeekableBuffer).Bytes()
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		log.Panic(err)
+	}
+
+	sb.f = f
+	sb.backing = f
+
+	atomic.AddInt64(&spillingBufferMetrics.spills, 1)
+	atomic.AddInt64(&spillingBufferMetrics.spilledBytes, int64(len(data)))
+
+	return nil
+}
+
+// Read reads at the current position.
+func (sb *SpillingBuffer) Read(p []byte) (n int, err error) {
+	if sb.closed == true {
+		return 0, ErrSpillingBufferClosed
+	} else if sb.position >= sb.size {
+		// Like `SeekableBuffer`, even for an empty read.
+		return 0, io.EOF
+	}
+
+	n, err = sb.backing.ReadAt(p, sb.position)
+	sb.position += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads at the given offset without moving the position.
+func (sb *SpillingBuffer) ReadAt(p []byte, offset int64) (n int, err error) {
+	if sb.closed == true {
+		return 0, ErrSpillingBufferClosed
+	}
+
+	return sb.backing.ReadAt(p, offset)
+}
+
+// Write writes at the current position, spilling first if the data would go
+// beyond the threshold.
+func (sb *SpillingBuffer) Write(p []byte) (n int, err error) {
+	// Like `SeekableBuffer`, even an empty write extends the data up to the
+	// position.
+	if len(p) == 0 && sb.position > sb.size {
+		return 0, sb.Truncate(sb.position)
+	}
+
+	n, err = sb.WriteAt(p, sb.position)
+	sb.position += int64(n)
+
+	return n, err
+}
+
+// WriteAt writes at the given offset without moving the position.
+func (sb *SpillingBuffer) WriteAt(p []byte, offset int64) (n int, err error) {
+	if sb.closed == true {
+		return 0, ErrSpillingBufferClosed
+	} else if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := offset + int64(len(p))
+
+	err = sb.spillIfNeeded(end)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = sb.backing.WriteAt(p, offset)
+
+	if written := offset + int64(n); written > sb.size {
+		sb.size = written
+	}
+
+	return n, err
+}
+
+// Seek seeks like `SeekableBuffer`, which clamps negative positions to zero.
+func (sb *SpillingBuffer) Seek(offset int64, whence int) (n int64, err error) {
+	if sb.closed == true {
+		return 0, ErrSpillingBufferClosed
+	}
+
+	position, err := CalculateSeek(sb.position, offset, whence, sb.size)
+	if err != nil {
+		return sb.position, err
+	}
+
+	sb.position = position
+
+	return sb.position, nil
+}
+
+// Truncate either chops or extends (with zeros) the data. The position is not
+// changed.
+func (sb *SpillingBuffer) Truncate(size int64) (err error) {
+	if sb.closed == true {
+		return ErrSpillingBufferClosed
+	} else if size < 0 {
+		return ErrNegativeOffset
+	}
+
+	err = sb.spillIfNeeded(size)
+	if err != nil {
+		return err
+	}
+
+	err = sb.backing.Truncate(size)
+	if err != nil {
+		return err
+	}
+
+	sb.size = size
+
+	return nil
+}
+
+// Close releases the data and removes the temporary file, if any.
+func (sb *SpillingBuffer) Close() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if sb.closed == true {
+		return nil
+	}
+
+	sb.closed = true
+	sb.backing = nil
+
+	if sb.f == nil {
+		return nil
+	}
+
+	err = sb.f.Close()
+	removeErr := os.Remove(sb.f.Name())
+
+	log.PanicIf(err)
+	log.PanicIf(removeErr)
+
+	return nil
+}
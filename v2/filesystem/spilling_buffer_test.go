@@ -0,0 +1,224 @@
+package rifs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+func TestSpillingBuffer_InMemory(t *testing.T) {
+	sb := NewSpillingBuffer(SpillingBufferOptions{Threshold: 10})
+
+	defer sb.Close()
+
+	_, err := sb.Write([]byte("abcdef"))
+	log.PanicIf(err)
+
+	if sb.IsSpilled() != false || sb.Filepath() != "" {
+		t.Fatalf("Should not have spilled.")
+	}
+
+	_, err = sb.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(sb)
+	log.PanicIf(err)
+
+	if string(data) != "abcdef" {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	}
+}
+
+func TestSpillingBuffer_Spill(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	before := GetSpillingBufferMetrics()
+
+	sb := NewSpillingBuffer(SpillingBufferOptions{Threshold: 10, TempPath: tempPath})
+
+	_, err = sb.Write([]byte("abcdef"))
+	log.PanicIf(err)
+
+	_, err = sb.Write([]byte("ghijkl"))
+	log.PanicIf(err)
+
+	if sb.IsSpilled() != true {
+		t.Fatalf("Should have spilled.")
+	}
+
+	filepath := sb.Filepath()
+
+	if _, err := os.Stat(filepath); err != nil {
+		t.Fatalf("Temporary file does not exist: [%s]", filepath)
+	}
+
+	// Keep working against the file.
+
+	_, err = sb.Seek(-4, io.SeekEnd)
+	log.PanicIf(err)
+
+	_, err = sb.Write([]byte("XY"))
+	log.PanicIf(err)
+
+	_, err = sb.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadAll(sb)
+	log.PanicIf(err)
+
+	if string(data) != "abcdefghXYkl" {
+		t.Fatalf("Data not correct: [%s]", string(data))
+	} else if sb.Len() != 12 {
+		t.Fatalf("Length not correct: (%d)", sb.Len())
+	}
+
+	after := GetSpillingBufferMetrics()
+
+	if after.Buffers-before.Buffers != 1 || after.Spills-before.Spills != 1 || after.SpilledBytes-before.SpilledBytes != 6 {
+		t.Fatalf("Metrics not correct: %s => %s", before, after)
+	}
+
+	err = sb.Close()
+	log.PanicIf(err)
+
+	if _, err := os.Stat(filepath); os.IsNotExist(err) != true {
+		t.Fatalf("Temporary file was not removed: [%s]", filepath)
+	}
+
+	_, err = sb.Read(make([]byte, 1))
+	if err != ErrSpillingBufferClosed {
+		t.Fatalf("Expected closed error: %v", err)
+	}
+}
+
+func TestSpillingBuffer_Truncate_Spill(t *testing.T) {
+	sb := NewSpillingBuffer(SpillingBufferOptions{Threshold: 10})
+
+	defer sb.Close()
+
+	_, err := sb.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	err = sb.Truncate(20)
+	log.PanicIf(err)
+
+	if sb.IsSpilled() != true {
+		t.Fatalf("Should have spilled.")
+	}
+
+	buffer := make([]byte, 20)
+
+	_, err = sb.ReadAt(buffer, 0)
+	log.PanicIf(err)
+
+	expected := append([]byte("abc"), make([]byte, 17)...)
+
+	if bytes.Equal(buffer, expected) != true {
+		t.Fatalf("Data not correct: %v", buffer)
+	}
+}
+
+// TestSpillingBuffer_MatchesSeekableBuffer does the same random operations on
+// both and makes sure that spilling is invisible.
+func TestSpillingBuffer_MatchesSeekableBuffer(t *testing.T) {
+	spilling := NewSpillingBuffer(SpillingBufferOptions{Threshold: 200})
+
+	defer spilling.Close()
+
+	sb := NewSeekableBuffer()
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		p := make([]byte, r.Intn(16))
+		r.Read(p)
+
+		offset := r.Int63n(int64(sb.Len()) + 16)
+		operation := r.Intn(4)
+
+		var n1, n2 int64
+		var err1, err2 error
+
+		if operation == 0 {
+			var n int
+
+			n, err1 = sb.Write(p)
+			n1 = int64(n)
+
+			n, err2 = spilling.Write(p)
+			n2 = int64(n)
+		} else if operation == 1 {
+			buffer1 := make([]byte, len(p))
+			buffer2 := make([]byte, len(p))
+
+			var n int
+
+			n, err1 = sb.Read(buffer1)
+			n1 = int64(n)
+
+			n, err2 = spilling.Read(buffer2)
+			n2 = int64(n)
+
+			if bytes.Equal(buffer1, buffer2) != true {
+				t.Fatalf("Read (%d) data differs.", i)
+			}
+		} else if operation == 2 {
+			whence := r.Intn(3)
+
+			n1, err1 = sb.Seek(offset-8, whence)
+			n2, err2 = spilling.Seek(offset-8, whence)
+		} else {
+			// Mostly grow.
+			size := offset + int64(r.Intn(16))
+
+			err1 = sb.Truncate(size)
+			err2 = spilling.Truncate(size)
+		}
+
+		if n1 != n2 || err1 != err2 {
+			t.Fatalf("Operation (%d) of type (%d) differs: (%d) [%v] != (%d) [%v]", i, operation, n1, err1, n2, err2)
+		}
+	}
+
+	if spilling.IsSpilled() != true {
+		t.Fatalf("Test should have spilled.")
+	}
+
+	data := make([]byte, spilling.Len())
+
+	_, err := spilling.ReadAt(data, 0)
+	log.PanicIf(err)
+
+	if bytes.Equal(data, sb.Bytes()) != true {
+		t.Fatalf("Final content differs.")
+	}
+}
+
+func TestSpillingBuffer_ReadAt_Conformance(t *testing.T) {
+	data := []byte("some test data")
+
+	// Once in memory and once spilled.
+
+	for _, threshold := range []int64{100, 10} {
+		sb := NewSpillingBuffer(SpillingBufferOptions{Threshold: threshold})
+
+		_, err := sb.Write(data)
+		log.PanicIf(err)
+
+		err = ritesting.CheckReaderAt(sb, data)
+		log.PanicIf(err)
+
+		err = sb.Close()
+		log.PanicIf(err)
+	}
+}
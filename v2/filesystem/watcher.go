@@ -0,0 +1,329 @@
+package rifs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	defaultWatchDebounceInterval = time.Millisecond * 100
+	defaultWatchPollInterval     = time.Second * 2
+
+	// watchDebounceMaximumFactor limits how long a steady stream of changes
+	// can hold back events, as a multiple of the debounce interval.
+	watchDebounceMaximumFactor = 10
+)
+
+var (
+	// ErrWatchOverflow is sent when the platform dropped events. The consumer
+	// may want to rescan.
+	ErrWatchOverflow = errors.New("watch events were dropped")
+)
+
+// WatchEventType describes what happened to a path.
+type WatchEventType int
+
+const (
+	// WatchEventCreate indicates that a path was created.
+	WatchEventCreate WatchEventType = iota
+
+	// WatchEventModify indicates that the content or attributes of a path
+	// changed.
+	WatchEventModify
+
+	// WatchEventDelete indicates that a path was removed.
+	WatchEventDelete
+
+	// WatchEventRename indicates that a path was moved within the tree.
+	WatchEventRename
+)
+
+// String returns a descriptive string.
+func (wet WatchEventType) String() string {
+	if wet == WatchEventCreate {
+		return "CREATE"
+	} else if wet == WatchEventModify {
+		return "MODIFY"
+	} else if wet == WatchEventDelete {
+		return "DELETE"
+	} else if wet == WatchEventRename {
+		return "RENAME"
+	}
+
+	log.Panicf("unknown watch-event type: (%d)", wet)
+	return ""
+}
+
+// WatchEvent is one change. `Filepath` and `Info` have the same shape as they
+// do in `VisitedFile`.
+type WatchEvent struct {
+	Type WatchEventType
+
+	// Filepath is the current path (the new path for renames).
+	Filepath string
+
+	// Info is the current information for the path. It is nil for deletes.
+	Info os.FileInfo
+
+	// OldFilepath is the previous path for renames.
+	OldFilepath string
+}
+
+// String returns a descriptive string.
+func (we WatchEvent) String() string {
+	if we.Type == WatchEventRename {
+		return fmt.Sprintf("WatchEvent<TYPE=[%s] PATH=[%s] OLD-PATH=[%s]>", we.Type, we.Filepath, we.OldFilepath)
+	}
+
+	return fmt.Sprintf("WatchEvent<TYPE=[%s] PATH=[%s]>", we.Type, we.Filepath)
+}
+
+// WatcherOptions describes how to watch.
+type WatcherOptions struct {
+	// Filter is an optional predicate that can exclude paths.
+	Filter FileListFilterPredicate
+
+	// DebounceInterval is how long the tree has to be quiet before queued
+	// events are delivered. Events for the same path are combined. Defaults
+	// to 100ms.
+	DebounceInterval time.Duration
+
+	// PollInterval is how often the polling watcher rescans. Defaults to two
+	// seconds.
+	PollInterval time.Duration
+}
+
+// Watcher delivers change events for a tree.
+type Watcher interface {
+	// Events returns the channel that events are delivered on. It is closed
+	// when the watcher is closed.
+	Events() <-chan WatchEvent
+
+	// Errors returns the channel that non-fatal errors are delivered on.
+	Errors() <-chan error
+
+	// Close stops watching. It does not wait for the consumer. Events that
+	// have not been received by then are delivered if they fit in the
+	// channel's buffer and are otherwise dropped.
+	Close() error
+}
+
+// NewWatcher returns the best watcher available on this platform for the
+// given path: inotify on Linux and polling elsewhere.
+func NewWatcher(rootPath string, options WatcherOptions) (w Watcher, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	w, err = newPlatformWatcher(rootPath, options)
+	log.PanicIf(err)
+
+	return w, nil
+}
+
+// watchDebouncer queues events, combines events for the same path, and
+// forwards them once things have been quiet for a while.
+type watchDebouncer struct {
+	interval time.Duration
+
+	inC  chan WatchEvent
+	outC chan WatchEvent
+
+	pending map[string]*WatchEvent
+	order   []string
+
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newWatchDebouncer(interval time.Duration) *watchDebouncer {
+	if interval <= 0 {
+		interval = defaultWatchDebounceInterval
+	}
+
+	wd := &watchDebouncer{
+		interval: interval,
+		inC:      make(chan WatchEvent, 100),
+		outC:     make(chan WatchEvent, 100),
+		pending:  make(map[string]*WatchEvent),
+		closeC:   make(chan struct{}),
+	}
+
+	wd.wg.Add(1)
+	go wd.run()
+
+	return wd
+}
+
+// Push queues an event. The event is dropped if we're closed.
+func (wd *watchDebouncer) Push(we WatchEvent) {
+	select {
+	case wd.inC <- we:
+	case <-wd.closeC:
+	}
+}
+
+// Close stops the debouncer and closes the output channel. It does not depend
+// on the consumer: whatever is still queued is delivered only if there's room
+// in the output channel and is otherwise dropped.
+func (wd *watchDebouncer) Close() {
+	close(wd.closeC)
+	wd.wg.Wait()
+}
+
+func (wd *watchDebouncer) run() {
+	defer wd.wg.Done()
+
+	var firstAt time.Time
+
+	timer := time.NewTimer(wd.interval)
+	timer.Stop()
+
+	for {
+		select {
+		case <-wd.closeC:
+			// Take whatever was pushed before we were closed.
+			for len(wd.inC) > 0 {
+				wd.merge(<-wd.inC)
+			}
+
+			wd.flush()
+			close(wd.outC)
+
+			return
+
+		case we := <-wd.inC:
+			if len(wd.order) == 0 {
+				firstAt = time.Now()
+			}
+
+			wd.merge(we)
+
+			// Don't let a steady stream of changes hold events back forever.
+			if time.Since(firstAt) >= wd.interval*watchDebounceMaximumFactor {
+				timer.Stop()
+				wd.flush()
+
+				continue
+			}
+
+			timer.Stop()
+			timer.Reset(wd.interval)
+
+		case <-timer.C:
+			wd.flush()
+		}
+	}
+}
+
+// merge combines the given event with anything already queued for the same
+// path.
+func (wd *watchDebouncer) merge(we WatchEvent) {
+	if we.Type == WatchEventRename {
+		if previous, found := wd.pending[we.OldFilepath]; found == true {
+			wd.remove(we.OldFilepath)
+
+			// It never existed as far as the consumer knows.
+			if previous.Type == WatchEventCreate {
+				we = WatchEvent{
+					Type:     WatchEventCreate,
+					Filepath: we.Filepath,
+					Info:     we.Info,
+				}
+			} else if previous.Type == WatchEventRename {
+				we.OldFilepath = previous.OldFilepath
+			}
+		}
+	}
+
+	previous, found := wd.pending[we.Filepath]
+	if found == false {
+		wd.add(we)
+		return
+	}
+
+	switch previous.Type {
+	case WatchEventCreate:
+		if we.Type == WatchEventDelete {
+			// It came and went.
+			wd.remove(we.Filepath)
+		} else if we.Type == WatchEventModify {
+			previous.Info = we.Info
+		} else {
+			*previous = we
+		}
+
+	case WatchEventDelete:
+		if we.Type == WatchEventCreate {
+			// It was replaced.
+			previous.Type = WatchEventModify
+			previous.Info = we.Info
+		} else {
+			*previous = we
+		}
+
+	case WatchEventRename:
+		if we.Type == WatchEventModify {
+			previous.Info = we.Info
+		} else if we.Type == WatchEventDelete {
+			// It left from where the consumer last knew it to be.
+			oldFilepath := previous.OldFilepath
+			wd.remove(we.Filepath)
+
+			wd.merge(WatchEvent{
+				Type:     WatchEventDelete,
+				Filepath: oldFilepath,
+			})
+		} else {
+			*previous = we
+		}
+
+	default:
+		*previous = we
+	}
+}
+
+func (wd *watchDebouncer) add(we WatchEvent) {
+	wd.pending[we.Filepath] = &we
+	wd.order = append(wd.order, we.Filepath)
+}
+
+func (wd *watchDebouncer) remove(filepath string) {
+	delete(wd.pending, filepath)
+
+	for i, current := range wd.order {
+		if current == filepath {
+			wd.order = append(wd.order[:i], wd.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// flush delivers everything queued. Once we're closed, it only delivers what
+// fits in the output channel and drops the rest.
+func (wd *watchDebouncer) flush() {
+	for _, filepath := range wd.order {
+		we := *wd.pending[filepath]
+
+		select {
+		case wd.outC <- we:
+			continue
+		case <-wd.closeC:
+		}
+
+		select {
+		case wd.outC <- we:
+		default:
+		}
+	}
+
+	wd.pending = make(map[string]*WatchEvent)
+	wd.order = nil
+}
@@ -0,0 +1,497 @@
+package rifs
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	inotifyWatchMask = syscall.IN_CREATE |
+		syscall.IN_MODIFY |
+		syscall.IN_CLOSE_WRITE |
+		syscall.IN_ATTRIB |
+		syscall.IN_DELETE |
+		syscall.IN_MOVED_FROM |
+		syscall.IN_MOVED_TO
+
+	inotifyReadBufferSize = 64 * 1024
+)
+
+// inotifyRawEvent is one event as read from the kernel.
+type inotifyRawEvent struct {
+	wd     int32
+	mask   uint32
+	cookie uint32
+	name   string
+}
+
+// inotifyPendingMove is the first half of a rename. If the second half never
+// arrives, the path was moved out of the tree.
+type inotifyPendingMove struct {
+	filepath string
+	isDir    bool
+	queuedAt time.Time
+}
+
+// InotifyWatcher is a `Watcher` that uses Linux's inotify. A watch is added
+// for every directory in the tree, and directories that are created or moved
+// into the tree later are watched automatically.
+type InotifyWatcher struct {
+	rootPath string
+	options  WatcherOptions
+
+	// f is used for reading (so that reads can be interrupted) and fd for
+	// managing watches. Neither is used after the goroutines stop, and fd is
+	// only closed (via f) after that.
+	f  *os.File
+	fd int
+
+	watches       map[int32]string
+	watchesByPath map[string]int32
+	pendingMoves  map[uint32]inotifyPendingMove
+
+	debouncer *watchDebouncer
+	errorsC   chan error
+	rawC      chan []inotifyRawEvent
+
+	closeC    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newPlatformWatcher returns the preferred watcher for this platform.
+func newPlatformWatcher(rootPath string, options WatcherOptions) (w Watcher, err error) {
+	return NewInotifyWatcher(rootPath, options)
+}
+
+// NewInotifyWatcher returns a new InotifyWatcher instance. Nothing that exists
+// before it returns is reported.
+func NewInotifyWatcher(rootPath string, options WatcherOptions) (iw *InotifyWatcher, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	log.PanicIf(err)
+
+	// Since the descriptor is non-blocking, this will be managed by the
+	// runtime poller, which allows reads to be interrupted by `Close`.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	iw = &InotifyWatcher{
+		rootPath:      path.Clean(rootPath),
+		options:       options,
+		f:             f,
+		fd:            fd,
+		watches:       make(map[int32]string),
+		watchesByPath: make(map[string]int32),
+		pendingMoves:  make(map[uint32]inotifyPendingMove),
+		errorsC:       make(chan error, 10),
+		rawC:          make(chan []inotifyRawEvent),
+		closeC:        make(chan struct{}),
+	}
+
+	err = iw.watchTree(iw.rootPath, false)
+	if err != nil {
+		f.Close()
+		log.Panic(err)
+	}
+
+	iw.debouncer = newWatchDebouncer(options.DebounceInterval)
+
+	iw.wg.Add(2)
+	go iw.read()
+	go iw.run()
+
+	return iw, nil
+}
+
+// Events returns the channel that events are delivered on.
+func (iw *InotifyWatcher) Events() <-chan WatchEvent {
+	return iw.debouncer.outC
+}
+
+// Errors returns the channel that non-fatal errors are delivered on.
+func (iw *InotifyWatcher) Errors() <-chan error {
+	return iw.errorsC
+}
+
+// Close stops watching and closes the events channel. It does not wait for the
+// consumer: queued events are delivered if there's room in the channel and
+// are otherwise dropped.
+func (iw *InotifyWatcher) Close() (err error) {
+	iw.closeOnce.Do(func() {
+		close(iw.closeC)
+
+		// This interrupts any blocked read.
+		err = iw.f.SetReadDeadline(time.Now())
+
+		// This unblocks anything that is pushing events.
+		iw.debouncer.Close()
+
+		iw.wg.Wait()
+
+		// Nothing can be using the descriptor now. This releases all watches.
+		if closeErr := iw.f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	})
+
+	return err
+}
+
+func (iw *InotifyWatcher) sendError(err error) {
+	select {
+	case iw.errorsC <- err:
+	default:
+	}
+}
+
+// addWatch watches a single directory.
+func (iw *InotifyWatcher) addWatch(dirPath string) (err error) {
+	wd, err := syscall.InotifyAddWatch(iw.fd, dirPath, inotifyWatchMask)
+	if err != nil {
+		return err
+	}
+
+	// The same directory will return the same descriptor.
+	if previousPath, found := iw.watches[int32(wd)]; found == true {
+		delete(iw.watchesByPath, previousPath)
+	}
+
+	iw.watches[int32(wd)] = dirPath
+	iw.watchesByPath[dirPath] = int32(wd)
+
+	return nil
+}
+
+// watchTree watches the given directory and every directory under it. If
+// `report` is true, everything under it is reported as created. This covers
+// anything that was created before we could add the watch.
+func (iw *InotifyWatcher) watchTree(dirPath string, report bool) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	err = iw.addWatch(dirPath)
+	log.PanicIf(err)
+
+	options := ListFilesOptions{
+		Filter:      iw.options.Filter,
+		ErrorPolicy: SkipPathErrorPolicy,
+	}
+
+	eventsC, errC, err := ListFilesWithOptions(dirPath, options)
+	log.PanicIf(err)
+
+EventsRead:
+
+	for {
+		select {
+		case err := <-errC:
+			log.PanicIf(err)
+
+		case event, ok := <-eventsC:
+			if ok == false {
+				break EventsRead
+			}
+
+			if event.File == nil {
+				continue
+			}
+
+			vf := event.File
+
+			if vf.Info.IsDir() == true {
+				// It may have already disappeared.
+				if err := iw.addWatch(vf.Filepath); err != nil {
+					continue
+				}
+			}
+
+			if report == true {
+				iw.debouncer.Push(WatchEvent{
+					Type:     WatchEventCreate,
+					Filepath: vf.Filepath,
+					Info:     vf.Info,
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// forgetTree stops watching the given directory and everything under it.
+func (iw *InotifyWatcher) forgetTree(dirPath string) {
+	prefix := dirPath + "/"
+
+	for wd, watchedPath := range iw.watches {
+		if watchedPath != dirPath && strings.HasPrefix(watchedPath, prefix) == false {
+			continue
+		}
+
+		// This will fail if the directory is already gone, which is fine.
+		syscall.InotifyRmWatch(iw.fd, uint32(wd))
+
+		delete(iw.watches, wd)
+		delete(iw.watchesByPath, watchedPath)
+	}
+}
+
+// moveTree updates the paths of the given directory and everything under it
+// after it was renamed.
+func (iw *InotifyWatcher) moveTree(oldPath, newPath string) {
+	prefix := oldPath + "/"
+
+	for wd, watchedPath := range iw.watches {
+		var updatedPath string
+		if watchedPath == oldPath {
+			updatedPath = newPath
+		} else if strings.HasPrefix(watchedPath, prefix) == true {
+			updatedPath = path.Join(newPath, watchedPath[len(prefix):])
+		} else {
+			continue
+		}
+
+		delete(iw.watchesByPath, watchedPath)
+
+		iw.watches[wd] = updatedPath
+		iw.watchesByPath[updatedPath] = wd
+	}
+}
+
+// stat returns the information for a path that we were told about or nil if
+// it has already disappeared or should be ignored.
+func (iw *InotifyWatcher) stat(filepath string) os.FileInfo {
+	fi, err := os.Lstat(filepath)
+	if err != nil {
+		return nil
+	}
+
+	if (fi.Mode() & os.ModeSymlink) > 0 {
+		return nil
+	}
+
+	if iw.options.Filter != nil {
+		hit, err := iw.options.Filter(path.Dir(filepath), fi)
+		if err != nil {
+			iw.sendError(err)
+			return nil
+		} else if hit == false {
+			return nil
+		}
+	}
+
+	return fi
+}
+
+func (iw *InotifyWatcher) created(filepath string) {
+	fi := iw.stat(filepath)
+	if fi == nil {
+		return
+	}
+
+	iw.debouncer.Push(WatchEvent{
+		Type:     WatchEventCreate,
+		Filepath: filepath,
+		Info:     fi,
+	})
+
+	if fi.IsDir() == true {
+		err := iw.watchTree(filepath, true)
+		if err != nil {
+			iw.sendError(err)
+		}
+	}
+}
+
+func (iw *InotifyWatcher) renamed(oldPath, newPath string, isDir bool) {
+	if isDir == true {
+		iw.moveTree(oldPath, newPath)
+	}
+
+	fi := iw.stat(newPath)
+	if fi == nil {
+		// It was moved again or it was moved somewhere that is filtered.
+		iw.deleted(oldPath, isDir)
+		return
+	}
+
+	iw.debouncer.Push(WatchEvent{
+		Type:        WatchEventRename,
+		Filepath:    newPath,
+		Info:        fi,
+		OldFilepath: oldPath,
+	})
+}
+
+func (iw *InotifyWatcher) deleted(filepath string, isDir bool) {
+	if isDir == true {
+		iw.forgetTree(filepath)
+	}
+
+	iw.debouncer.Push(WatchEvent{
+		Type:     WatchEventDelete,
+		Filepath: filepath,
+	})
+}
+
+func (iw *InotifyWatcher) modified(filepath string) {
+	fi := iw.stat(filepath)
+	if fi == nil {
+		return
+	}
+
+	iw.debouncer.Push(WatchEvent{
+		Type:     WatchEventModify,
+		Filepath: filepath,
+		Info:     fi,
+	})
+}
+
+func (iw *InotifyWatcher) handle(ire inotifyRawEvent) {
+	if ire.mask&syscall.IN_Q_OVERFLOW > 0 {
+		iw.sendError(ErrWatchOverflow)
+		return
+	}
+
+	dirPath, found := iw.watches[ire.wd]
+	if found == false {
+		return
+	}
+
+	if ire.mask&syscall.IN_IGNORED > 0 {
+		delete(iw.watches, ire.wd)
+		delete(iw.watchesByPath, dirPath)
+
+		return
+	}
+
+	// Events for the watched directory itself are reported by the watch on
+	// its parent.
+	if ire.name == "" {
+		return
+	}
+
+	filepath := path.Join(dirPath, ire.name)
+	isDir := ire.mask&syscall.IN_ISDIR > 0
+
+	if ire.mask&syscall.IN_CREATE > 0 {
+		iw.created(filepath)
+	} else if ire.mask&syscall.IN_MOVED_FROM > 0 {
+		iw.pendingMoves[ire.cookie] = inotifyPendingMove{
+			filepath: filepath,
+			isDir:    isDir,
+			queuedAt: time.Now(),
+		}
+	} else if ire.mask&syscall.IN_MOVED_TO > 0 {
+		if ipm, found := iw.pendingMoves[ire.cookie]; found == true {
+			delete(iw.pendingMoves, ire.cookie)
+			iw.renamed(ipm.filepath, filepath, isDir)
+		} else {
+			// It was moved in from outside of the tree.
+			iw.created(filepath)
+		}
+	} else if ire.mask&syscall.IN_DELETE > 0 {
+		iw.deleted(filepath, isDir)
+	} else if ire.mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE|syscall.IN_ATTRIB) > 0 {
+		iw.modified(filepath)
+	}
+}
+
+// expireMoves reports the first halves of renames that were never completed as
+// deletes. The paths were moved out of the tree.
+func (iw *InotifyWatcher) expireMoves(maximumAge time.Duration) {
+	for cookie, ipm := range iw.pendingMoves {
+		if time.Since(ipm.queuedAt) < maximumAge {
+			continue
+		}
+
+		delete(iw.pendingMoves, cookie)
+		iw.deleted(ipm.filepath, ipm.isDir)
+	}
+}
+
+func (iw *InotifyWatcher) run() {
+	defer iw.wg.Done()
+
+	interval := iw.debouncer.interval
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-iw.closeC:
+			return
+
+		case events := <-iw.rawC:
+			for _, ire := range events {
+				iw.handle(ire)
+			}
+
+		case <-ticker.C:
+			iw.expireMoves(interval)
+		}
+	}
+}
+
+// read reads and parses events from the kernel.
+func (iw *InotifyWatcher) read() {
+	defer iw.wg.Done()
+
+	buffer := make([]byte, inotifyReadBufferSize)
+
+	for {
+		n, err := iw.f.Read(buffer)
+		if err != nil {
+			select {
+			case <-iw.closeC:
+			default:
+				iw.sendError(err)
+			}
+
+			return
+		}
+
+		events := make([]inotifyRawEvent, 0)
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+
+			ire := inotifyRawEvent{
+				wd:     raw.Wd,
+				mask:   raw.Mask,
+				cookie: raw.Cookie,
+			}
+
+			nameOffset := offset + syscall.SizeofInotifyEvent
+			nameBytes := buffer[nameOffset : nameOffset+int(raw.Len)]
+
+			// The name is padded with NULs.
+			ire.name = strings.TrimRight(string(nameBytes), "\x00")
+
+			events = append(events, ire)
+
+			offset = nameOffset + int(raw.Len)
+		}
+
+		select {
+		case iw.rawC <- events:
+		case <-iw.closeC:
+			return
+		}
+	}
+}
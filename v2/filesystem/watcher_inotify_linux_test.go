@@ -0,0 +1,244 @@
+package rifs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func newTestInotifyWatcher(rootPath string, filter FileListFilterPredicate) *InotifyWatcher {
+	options := WatcherOptions{
+		Filter:           filter,
+		DebounceInterval: time.Millisecond * 20,
+	}
+
+	iw, err := NewInotifyWatcher(rootPath, options)
+	log.PanicIf(err)
+
+	return iw
+}
+
+func TestInotifyWatcher_CreateModifyDelete(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	iw := newTestInotifyWatcher(tempPath, nil)
+	defer iw.Close()
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	events := collectWatchEvents(iw, filepath, WatchEventCreate, time.Second*5)
+
+	we := findWatchEvent(events, filepath)
+	if we == nil || we.Type != WatchEventCreate {
+		t.Fatalf("Create not reported: %v", events)
+	} else if we.Info.Size() != 3 {
+		t.Fatalf("Info not correct: (%d)", we.Info.Size())
+	}
+
+	err = ioutil.WriteFile(filepath, []byte("abcdef"), 0644)
+	log.PanicIf(err)
+
+	events = collectWatchEvents(iw, filepath, WatchEventModify, time.Second*5)
+
+	we = findWatchEvent(events, filepath)
+	if we == nil || we.Type != WatchEventModify {
+		t.Fatalf("Modify not reported: %v", events)
+	}
+
+	err = os.Remove(filepath)
+	log.PanicIf(err)
+
+	events = collectWatchEvents(iw, filepath, WatchEventDelete, time.Second*5)
+
+	we = findWatchEvent(events, filepath)
+	if we == nil || we.Type != WatchEventDelete {
+		t.Fatalf("Delete not reported: %v", events)
+	}
+}
+
+func TestInotifyWatcher_NewDirectory(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	iw := newTestInotifyWatcher(tempPath, nil)
+	defer iw.Close()
+
+	dirPath := path.Join(tempPath, "aa", "bb")
+
+	err = os.MkdirAll(dirPath, 0755)
+	log.PanicIf(err)
+
+	// This may happen before or after the new directories are watched. It
+	// should be reported either way.
+	filepath := path.Join(dirPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	events := collectWatchEvents(iw, filepath, WatchEventCreate, time.Second*5)
+
+	if we := findWatchEvent(events, filepath); we == nil || we.Type != WatchEventCreate {
+		t.Fatalf("Create not reported for file in new directory: %v", events)
+	}
+}
+
+func TestInotifyWatcher_Rename(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	oldPath := path.Join(tempPath, "old")
+
+	err = os.Mkdir(oldPath, 0755)
+	log.PanicIf(err)
+
+	iw := newTestInotifyWatcher(tempPath, nil)
+	defer iw.Close()
+
+	newPath := path.Join(tempPath, "new")
+
+	err = os.Rename(oldPath, newPath)
+	log.PanicIf(err)
+
+	events := collectWatchEvents(iw, newPath, WatchEventRename, time.Second*5)
+
+	we := findWatchEvent(events, newPath)
+	if we == nil || we.Type != WatchEventRename {
+		t.Fatalf("Rename not reported: %v", events)
+	} else if we.OldFilepath != oldPath {
+		t.Fatalf("Old path not correct: [%s]", we.OldFilepath)
+	}
+
+	// The watch should follow the directory.
+
+	filepath := path.Join(newPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	events = collectWatchEvents(iw, filepath, WatchEventCreate, time.Second*5)
+
+	if we := findWatchEvent(events, filepath); we == nil {
+		t.Fatalf("Create not reported in renamed directory: %v", events)
+	}
+}
+
+func TestInotifyWatcher_MovedOut(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	watchedPath := path.Join(tempPath, "watched")
+
+	err = os.Mkdir(watchedPath, 0755)
+	log.PanicIf(err)
+
+	filepath := path.Join(watchedPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	iw := newTestInotifyWatcher(watchedPath, nil)
+	defer iw.Close()
+
+	err = os.Rename(filepath, path.Join(tempPath, "file1"))
+	log.PanicIf(err)
+
+	events := collectWatchEvents(iw, filepath, WatchEventDelete, time.Second*5)
+
+	if we := findWatchEvent(events, filepath); we == nil || we.Type != WatchEventDelete {
+		t.Fatalf("Move out of the tree should be reported as a delete: %v", events)
+	}
+}
+
+func TestInotifyWatcher_Filter(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	filter := func(parent string, child os.FileInfo) (bool, error) {
+		return child.Name() != "ignored", nil
+	}
+
+	iw := newTestInotifyWatcher(tempPath, filter)
+	defer iw.Close()
+
+	err = ioutil.WriteFile(path.Join(tempPath, "ignored"), []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	filepath := path.Join(tempPath, "file1")
+
+	err = ioutil.WriteFile(filepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	events := collectWatchEvents(iw, filepath, WatchEventCreate, time.Second*5)
+
+	if findWatchEvent(events, path.Join(tempPath, "ignored")) != nil {
+		t.Fatalf("Filtered path was reported: %v", events)
+	}
+}
+
+func TestInotifyWatcher_Close_NoConsumer(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	iw := newTestInotifyWatcher(tempPath, nil)
+
+	// Produce more events than the channel can hold without reading any.
+
+	for i := 0; i < 300; i++ {
+		filepath := path.Join(tempPath, fmt.Sprintf("file%d", i))
+
+		err := ioutil.WriteFile(filepath, []byte("abc"), 0644)
+		log.PanicIf(err)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	doneC := make(chan error, 1)
+
+	go func() {
+		doneC <- iw.Close()
+	}()
+
+	select {
+	case err := <-doneC:
+		log.PanicIf(err)
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Close blocked on the consumer.")
+	}
+}
+
+func TestNewWatcher(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	w, err := NewWatcher(tempPath, WatcherOptions{})
+	log.PanicIf(err)
+
+	defer w.Close()
+
+	if _, ok := w.(*InotifyWatcher); ok != true {
+		t.Fatalf("Expected inotify watcher on Linux: %T", w)
+	}
+}
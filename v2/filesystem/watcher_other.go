@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package rifs
+
+// newPlatformWatcher returns the preferred watcher for this platform.
+func newPlatformWatcher(rootPath string, options WatcherOptions) (w Watcher, err error) {
+	return NewPollingWatcher(rootPath, options)
+}
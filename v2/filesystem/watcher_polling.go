@@ -0,0 +1,158 @@
+package rifs
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// PollingWatcher is a portable `Watcher` that periodically takes a snapshot of
+// the tree and reports the differences from the last one.
+type PollingWatcher struct {
+	rootPath string
+	options  WatcherOptions
+
+	last *Snapshot
+
+	debouncer *watchDebouncer
+	errorsC   chan error
+
+	closeC    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewPollingWatcher returns a new PollingWatcher instance. The initial scan is
+// done before returning. Nothing that exists at that point is reported.
+func NewPollingWatcher(rootPath string, options WatcherOptions) (pw *PollingWatcher, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaultWatchPollInterval
+	}
+
+	pw = &PollingWatcher{
+		rootPath:  path.Clean(rootPath),
+		options:   options,
+		debouncer: newWatchDebouncer(options.DebounceInterval),
+		errorsC:   make(chan error, 10),
+		closeC:    make(chan struct{}),
+	}
+
+	pw.last, err = pw.snapshot()
+	if err != nil {
+		pw.debouncer.Close()
+		log.Panic(err)
+	}
+
+	pw.wg.Add(1)
+	go pw.run()
+
+	return pw, nil
+}
+
+// Events returns the channel that events are delivered on.
+func (pw *PollingWatcher) Events() <-chan WatchEvent {
+	return pw.debouncer.outC
+}
+
+// Errors returns the channel that scan failures are delivered on.
+func (pw *PollingWatcher) Errors() <-chan error {
+	return pw.errorsC
+}
+
+// Close stops polling and closes the events channel. It does not wait for the
+// consumer: queued events are delivered if there's room in the channel and
+// are otherwise dropped.
+func (pw *PollingWatcher) Close() error {
+	pw.closeOnce.Do(func() {
+		close(pw.closeC)
+
+		// This unblocks anything that is pushing events.
+		pw.debouncer.Close()
+
+		pw.wg.Wait()
+	})
+
+	return nil
+}
+
+func (pw *PollingWatcher) snapshot() (snapshot *Snapshot, err error) {
+	options := SnapshotOptions{
+		Filter:      pw.options.Filter,
+		ErrorPolicy: SkipPathErrorPolicy,
+	}
+
+	return BuildSnapshot(pw.rootPath, options)
+}
+
+func (pw *PollingWatcher) run() {
+	defer pw.wg.Done()
+
+	ticker := time.NewTicker(pw.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pw.closeC:
+			return
+
+		case <-ticker.C:
+			current, err := pw.snapshot()
+			if err != nil {
+				select {
+				case pw.errorsC <- err:
+				default:
+				}
+
+				continue
+			}
+
+			sd := DiffSnapshots(pw.last, current)
+			pw.last = current
+
+			pw.report(sd)
+		}
+	}
+}
+
+// report translates a snapshot diff to events.
+func (pw *PollingWatcher) report(sd *SnapshotDiff) {
+	for _, se := range sd.Added {
+		pw.debouncer.Push(WatchEvent{
+			Type:     WatchEventCreate,
+			Filepath: path.Join(pw.rootPath, se.Filepath),
+			Info:     se.FileInfo(),
+		})
+	}
+
+	for _, sm := range sd.Modified {
+		pw.debouncer.Push(WatchEvent{
+			Type:     WatchEventModify,
+			Filepath: path.Join(pw.rootPath, sm.After.Filepath),
+			Info:     sm.After.FileInfo(),
+		})
+	}
+
+	for _, sr := range sd.Renamed {
+		pw.debouncer.Push(WatchEvent{
+			Type:        WatchEventRename,
+			Filepath:    path.Join(pw.rootPath, sr.To.Filepath),
+			Info:        sr.To.FileInfo(),
+			OldFilepath: path.Join(pw.rootPath, sr.From.Filepath),
+		})
+	}
+
+	for _, se := range sd.Removed {
+		pw.debouncer.Push(WatchEvent{
+			Type:     WatchEventDelete,
+			Filepath: path.Join(pw.rootPath, se.Filepath),
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package rifs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestPollingWatcher(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	existingFilepath := path.Join(tempPath, "existing")
+
+	err = ioutil.WriteFile(existingFilepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	options := WatcherOptions{
+		DebounceInterval: time.Millisecond * 10,
+		PollInterval:     time.Millisecond * 50,
+	}
+
+	pw, err := NewPollingWatcher(tempPath, options)
+	log.PanicIf(err)
+
+	defer pw.Close()
+
+	newFilepath := path.Join(tempPath, "new")
+
+	err = ioutil.WriteFile(newFilepath, []byte("defg"), 0644)
+	log.PanicIf(err)
+
+	events := collectWatchEvents(pw, newFilepath, WatchEventCreate, time.Second*5)
+
+	we := findWatchEvent(events, newFilepath)
+	if we == nil || we.Type != WatchEventCreate {
+		t.Fatalf("Create not reported: %v", events)
+	} else if we.Info.Size() != 4 {
+		t.Fatalf("Info not correct: (%d)", we.Info.Size())
+	} else if findWatchEvent(events, existingFilepath) != nil {
+		t.Fatalf("Existing file should not have been reported: %v", events)
+	}
+
+	err = os.Remove(existingFilepath)
+	log.PanicIf(err)
+
+	events = collectWatchEvents(pw, existingFilepath, WatchEventDelete, time.Second*5)
+
+	we = findWatchEvent(events, existingFilepath)
+	if we == nil || we.Type != WatchEventDelete {
+		t.Fatalf("Delete not reported: %v", events)
+	}
+
+	err = pw.Close()
+	log.PanicIf(err)
+
+	if _, ok := <-pw.Events(); ok != false {
+		t.Fatalf("Events channel should be closed.")
+	}
+}
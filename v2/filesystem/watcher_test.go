@@ -0,0 +1,226 @@
+package rifs
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collectWatchEvents reads events until the given path has been reported with
+// the given type or until the timeout elapses.
+func collectWatchEvents(w Watcher, filepath string, wet WatchEventType, timeout time.Duration) []WatchEvent {
+	events := make([]WatchEvent, 0)
+	timeoutC := time.After(timeout)
+
+	for {
+		select {
+		case we, ok := <-w.Events():
+			if ok == false {
+				return events
+			}
+
+			events = append(events, we)
+
+			if we.Filepath == filepath && we.Type == wet {
+				return events
+			}
+
+		case <-timeoutC:
+			return events
+		}
+	}
+}
+
+// findWatchEvent returns the event for the given path or nil.
+func findWatchEvent(events []WatchEvent, filepath string) *WatchEvent {
+	for i, we := range events {
+		if we.Filepath == filepath {
+			return &events[i]
+		}
+	}
+
+	return nil
+}
+
+func debounceAndCollect(events ...WatchEvent) []WatchEvent {
+	wd := newWatchDebouncer(time.Hour)
+
+	for _, we := range events {
+		wd.Push(we)
+	}
+
+	wd.Close()
+
+	collected := make([]WatchEvent, 0)
+	for we := range wd.outC {
+		collected = append(collected, we)
+	}
+
+	return collected
+}
+
+func TestWatchDebouncer_CreateThenModify(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventCreate, Filepath: "a"},
+		WatchEvent{Type: WatchEventModify, Filepath: "a"},
+		WatchEvent{Type: WatchEventModify, Filepath: "b"},
+		WatchEvent{Type: WatchEventModify, Filepath: "b"})
+
+	expected := []WatchEvent{
+		{Type: WatchEventCreate, Filepath: "a"},
+		{Type: WatchEventModify, Filepath: "b"},
+	}
+
+	if reflect.DeepEqual(collected, expected) != true {
+		t.Fatalf("Events not correct: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_CreateThenDelete(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventCreate, Filepath: "a"},
+		WatchEvent{Type: WatchEventModify, Filepath: "a"},
+		WatchEvent{Type: WatchEventDelete, Filepath: "a"})
+
+	if len(collected) != 0 {
+		t.Fatalf("Expected no events: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_DeleteThenCreate(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventDelete, Filepath: "a"},
+		WatchEvent{Type: WatchEventCreate, Filepath: "a"})
+
+	expected := []WatchEvent{
+		{Type: WatchEventModify, Filepath: "a"},
+	}
+
+	if reflect.DeepEqual(collected, expected) != true {
+		t.Fatalf("Events not correct: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_RenameChain(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventRename, Filepath: "b", OldFilepath: "a"},
+		WatchEvent{Type: WatchEventRename, Filepath: "c", OldFilepath: "b"})
+
+	expected := []WatchEvent{
+		{Type: WatchEventRename, Filepath: "c", OldFilepath: "a"},
+	}
+
+	if reflect.DeepEqual(collected, expected) != true {
+		t.Fatalf("Events not correct: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_CreateThenRename(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventCreate, Filepath: "a"},
+		WatchEvent{Type: WatchEventRename, Filepath: "b", OldFilepath: "a"})
+
+	expected := []WatchEvent{
+		{Type: WatchEventCreate, Filepath: "b"},
+	}
+
+	if reflect.DeepEqual(collected, expected) != true {
+		t.Fatalf("Events not correct: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_RenameThenDelete(t *testing.T) {
+	collected := debounceAndCollect(
+		WatchEvent{Type: WatchEventRename, Filepath: "b", OldFilepath: "a"},
+		WatchEvent{Type: WatchEventDelete, Filepath: "b"})
+
+	expected := []WatchEvent{
+		{Type: WatchEventDelete, Filepath: "a"},
+	}
+
+	if reflect.DeepEqual(collected, expected) != true {
+		t.Fatalf("Events not correct: %v", collected)
+	}
+}
+
+func TestWatchDebouncer_MaximumWait(t *testing.T) {
+	interval := time.Millisecond * 20
+	wd := newWatchDebouncer(interval)
+
+	defer func() {
+		wd.Close()
+
+		for range wd.outC {
+		}
+	}()
+
+	// Keep the tree busy for longer than the maximum wait.
+
+	doneC := make(chan struct{})
+
+	go func() {
+		defer close(doneC)
+
+		for i := 0; i < watchDebounceMaximumFactor*4; i++ {
+			wd.Push(WatchEvent{Type: WatchEventModify, Filepath: "a"})
+			time.Sleep(interval / 2)
+		}
+	}()
+
+	select {
+	case <-wd.outC:
+	case <-doneC:
+		t.Fatalf("Events were held back for the whole stream.")
+	}
+
+	<-doneC
+}
+
+func TestWatchDebouncer_Close_NoConsumer(t *testing.T) {
+	interval := time.Millisecond
+	wd := newWatchDebouncer(interval)
+
+	// Queue more events than the output channel can hold and never read
+	// them.
+
+	for i := 0; i < 300; i++ {
+		we := WatchEvent{
+			Type:     WatchEventCreate,
+			Filepath: fmt.Sprintf("file%d", i),
+		}
+
+		wd.Push(we)
+	}
+
+	time.Sleep(interval * 50)
+
+	doneC := make(chan struct{})
+
+	go func() {
+		defer close(doneC)
+
+		wd.Close()
+	}()
+
+	select {
+	case <-doneC:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Close blocked on the consumer.")
+	}
+
+	count := 0
+	for range wd.outC {
+		count++
+	}
+
+	if count != cap(wd.outC) {
+		t.Fatalf("Delivered count not correct: (%d)", count)
+	}
+}
+
+func TestWatchEventType_String(t *testing.T) {
+	if WatchEventRename.String() != "RENAME" {
+		t.Fatalf("String not correct: [%s]", WatchEventRename.String())
+	}
+}
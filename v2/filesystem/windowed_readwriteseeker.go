@@ -0,0 +1,429 @@
+package rifs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	windowFillChunkSize = 32 * 1024
+)
+
+// WindowBoundaryError is returned when an operation would go beyond the end
+// of a bounded window. Nothing is read or written in that case.
+type WindowBoundaryError struct {
+	// Operation is the rejected operation ("seek", "write", or "truncate").
+	Operation string
+
+	// Offset is where, relative to the window, the operation would have
+	// started (or, for truncations, the requested size).
+	Offset int64
+
+	// Length is the size of the operation. Zero for seeks and truncations.
+	Length int64
+
+	// WindowLength is the length of the window.
+	WindowLength int64
+}
+
+// Error returns the error message.
+func (wbe *WindowBoundaryError) Error() string {
+	return fmt.Sprintf("%s beyond window: offset (%d) length (%d) window (%d)", wbe.Operation, wbe.Offset, wbe.Length, wbe.WindowLength)
+}
+
+// WindowedReadWriteSeeker exposes the [start, end) range of another
+// ReadWriteSeeker as if it were the whole stream, for both reading and
+// writing. Offsets are relative to the start of the window. An unbounded
+// window has no end.
+//
+// The logical size of the stream is determined once, when the window is
+// created, and then tracked as writes extend it and as it is truncated, so
+// the window can be used as a sub-file within a larger container. Reads stop
+// at the logical size even if the underlying stream has more data.
+type WindowedReadWriteSeeker struct {
+	rws io.ReadWriteSeeker
+
+	start   int64
+	end     int64
+	bounded bool
+
+	position int64
+	size     int64
+
+	// readAtMutex serializes `ReadAt` calls that have to seek the underlying
+	// stream.
+	readAtMutex sync.Mutex
+}
+
+// NewWindowedReadWriteSeeker returns a new WindowedReadWriteSeeker instance
+// for the [start, end) range.
+func NewWindowedReadWriteSeeker(rws io.ReadWriteSeeker, start, end int64) (wrws *WindowedReadWriteSeeker, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if start < 0 || end < start {
+		log.Panicf("window not valid: [%d, %d)", start, end)
+	}
+
+	wrws = &WindowedReadWriteSeeker{
+		rws:     rws,
+		start:   start,
+		end:     end,
+		bounded: true,
+	}
+
+	err = wrws.loadSize()
+	log.PanicIf(err)
+
+	return wrws, nil
+}
+
+// NewUnboundedWindowedReadWriteSeeker returns a new WindowedReadWriteSeeker
+// instance for everything from the given start onward.
+func NewUnboundedWindowedReadWriteSeeker(rws io.ReadWriteSeeker, start int64) (wrws *WindowedReadWriteSeeker, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if start < 0 {
+		log.Panicf("window start not valid: (%d)", start)
+	}
+
+	wrws = &WindowedReadWriteSeeker{
+		rws:   rws,
+		start: start,
+	}
+
+	err = wrws.loadSize()
+	log.PanicIf(err)
+
+	return wrws, nil
+}
+
+// String returns a descriptive string.
+func (wrws *WindowedReadWriteSeeker) String() string {
+	if wrws.bounded == false {
+		return fmt.Sprintf("WindowedReadWriteSeeker<START=(%d) UNBOUNDED SIZE=(%d) POSITION=(%d)>", wrws.start, wrws.size, wrws.position)
+	}
+
+	return fmt.Sprintf("WindowedReadWriteSeeker<START=(%d) END=(%d) SIZE=(%d) POSITION=(%d)>", wrws.start, wrws.end, wrws.size, wrws.position)
+}
+
+// Start returns the offset of the window in the underlying stream.
+func (wrws *WindowedReadWriteSeeker) Start() int64 {
+	return wrws.start
+}
+
+// End returns the offset of the end of the window in the underlying stream
+// and whether the window is bounded.
+func (wrws *WindowedReadWriteSeeker) End() (end int64, bounded bool) {
+	return wrws.end, wrws.bounded
+}
+
+// Size returns the logical size of the stream as seen through the window.
+func (wrws *WindowedReadWriteSeeker) Size() int64 {
+	return wrws.size
+}
+
+// windowLength returns the length of a bounded window.
+func (wrws *WindowedReadWriteSeeker) windowLength() int64 {
+	return wrws.end - wrws.start
+}
+
+// underlyingSize returns the size of the underlying stream.
+func (wrws *WindowedReadWriteSeeker) underlyingSize() (size int64, err error) {
+	return wrws.rws.Seek(0, io.SeekEnd)
+}
+
+// loadSize determines the initial logical size from the underlying stream.
+func (wrws *WindowedReadWriteSeeker) loadSize() (err error) {
+	underlyingSize, err := wrws.underlyingSize()
+	if err != nil {
+		return err
+	}
+
+	size := underlyingSize - wrws.start
+	if size < 0 {
+		size = 0
+	}
+
+	if wrws.bounded == true && size > wrws.windowLength() {
+		size = wrws.windowLength()
+	}
+
+	wrws.size = size
+
+	return nil
+}
+
+// checkWrite returns an error if a write would cross the end of the window.
+func (wrws *WindowedReadWriteSeeker) checkWrite(offset int64, length int) error {
+	if wrws.bounded == true && offset+int64(length) > wrws.windowLength() {
+		return &WindowBoundaryError{
+			Operation:    "write",
+			Offset:       offset,
+			Length:       int64(length),
+			WindowLength: wrws.windowLength(),
+		}
+	}
+
+	return nil
+}
+
+// limitRead shortens a read so that it doesn't cross the logical end of the
+// stream.
+func (wrws *WindowedReadWriteSeeker) limitRead(p []byte, offset int64) []byte {
+	available := wrws.size - offset
+	if available < 0 {
+		available = 0
+	}
+
+	if int64(len(p)) > available {
+		p = p[:available]
+	}
+
+	return p
+}
+
+// writeAt writes at the given window offset and extends the logical size.
+func (wrws *WindowedReadWriteSeeker) writeAt(p []byte, offset int64) (n int, err error) {
+	if wa, ok := wrws.rws.(io.WriterAt); ok == true {
+		n, err = wa.WriteAt(p, wrws.start+offset)
+	} else {
+		_, err = wrws.rws.Seek(wrws.start+offset, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+
+		n, err = wrws.rws.Write(p)
+	}
+
+	if offset+int64(n) > wrws.size {
+		wrws.size = offset + int64(n)
+	}
+
+	return n, err
+}
+
+// fill writes zeros from the logical end up to the given offset so that a
+// gap never exposes stale data from the underlying stream.
+func (wrws *WindowedReadWriteSeeker) fill(offset int64) (err error) {
+	if offset <= wrws.size {
+		return nil
+	}
+
+	zeros := make([]byte, windowFillChunkSize)
+
+	for wrws.size < offset {
+		chunk := zeros
+		if remaining := offset - wrws.size; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+
+		_, err := wrws.writeAt(chunk, wrws.size)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Seek moves within the window. Seeks before the start are clamped to it, and
+// seeks past the end of a bounded window fail with `WindowBoundaryError`.
+// Seeks relative to the end use the logical size.
+func (wrws *WindowedReadWriteSeeker) Seek(offset int64, whence int) (newOffset int64, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	newOffset, err = CalculateSeek(wrws.position, offset, whence, wrws.size)
+	log.PanicIf(err)
+
+	if wrws.bounded == true && newOffset > wrws.windowLength() {
+		return wrws.position, &WindowBoundaryError{
+			Operation:    "seek",
+			Offset:       newOffset,
+			WindowLength: wrws.windowLength(),
+		}
+	}
+
+	wrws.position = newOffset
+
+	return newOffset, nil
+}
+
+// Read reads from the current position up to the logical end.
+func (wrws *WindowedReadWriteSeeker) Read(p []byte) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	p = wrws.limitRead(p, wrws.position)
+	if len(p) == 0 {
+		return 0, io.EOF
+	}
+
+	_, err = wrws.rws.Seek(wrws.start+wrws.position, io.SeekStart)
+	log.PanicIf(err)
+
+	n, err = wrws.rws.Read(p)
+	wrws.position += int64(n)
+
+	if err == io.EOF {
+		return n, err
+	}
+
+	log.PanicIf(err)
+
+	return n, nil
+}
+
+// Write writes at the current position, extending the logical size as
+// needed. A write that would cross the end of a bounded window fails with
+// `WindowBoundaryError`.
+func (wrws *WindowedReadWriteSeeker) Write(p []byte) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	err = wrws.checkWrite(wrws.position, len(p))
+	if err != nil {
+		return 0, err
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	err = wrws.fill(wrws.position)
+	log.PanicIf(err)
+
+	n, err = wrws.writeAt(p, wrws.position)
+	wrws.position += int64(n)
+
+	log.PanicIf(err)
+
+	return n, nil
+}
+
+// ReadAt reads at the given offset without moving the position. A short read
+// returns `io.EOF`. It may be called concurrently with other `ReadAt` calls
+// but not with the other methods.
+func (wrws *WindowedReadWriteSeeker) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	limited := wrws.limitRead(p, offset)
+	if len(limited) == 0 {
+		return 0, io.EOF
+	}
+
+	if ra, ok := wrws.rws.(io.ReaderAt); ok == true {
+		n, err = ra.ReadAt(limited, wrws.start+offset)
+	} else {
+		wrws.readAtMutex.Lock()
+		n, err = readSeekerReadAt(wrws.rws, limited, wrws.start+offset)
+		wrws.readAtMutex.Unlock()
+	}
+
+	if err != nil {
+		return n, err
+	} else if len(limited) < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// WriteAt writes at the given offset without moving the position, extending
+// the logical size as needed. A write that would cross the end of a bounded
+// window fails with `WindowBoundaryError`.
+func (wrws *WindowedReadWriteSeeker) WriteAt(p []byte, offset int64) (n int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	err = wrws.checkWrite(offset, len(p))
+	if err != nil {
+		return 0, err
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+
+	err = wrws.fill(offset)
+	log.PanicIf(err)
+
+	n, err = wrws.writeAt(p, offset)
+	log.PanicIf(err)
+
+	return n, nil
+}
+
+// Truncate changes the logical size. Growing fills with zeros. Shrinking
+// only truncates the underlying stream if the window's data runs to its end
+// and it supports truncation; otherwise, whatever follows in the underlying
+// stream is left alone. The position is not changed.
+func (wrws *WindowedReadWriteSeeker) Truncate(size int64) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if size < 0 {
+		return ErrNegativeOffset
+	} else if wrws.bounded == true && size > wrws.windowLength() {
+		return &WindowBoundaryError{
+			Operation:    "truncate",
+			Offset:       size,
+			WindowLength: wrws.windowLength(),
+		}
+	}
+
+	if size >= wrws.size {
+		err = wrws.fill(size)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	if t, ok := wrws.rws.(truncater); ok == true {
+		underlyingSize, err := wrws.underlyingSize()
+		log.PanicIf(err)
+
+		if wrws.start+wrws.size >= underlyingSize {
+			err := t.Truncate(wrws.start + size)
+			log.PanicIf(err)
+		}
+	}
+
+	wrws.size = size
+
+	return nil
+}
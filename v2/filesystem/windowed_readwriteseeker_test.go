@@ -0,0 +1,414 @@
+package rifs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-utility/v2/testing"
+)
+
+// testPlainReadWriteSeeker hides the optional interfaces of the wrapped value.
+type testPlainReadWriteSeeker struct {
+	io.ReadWriteSeeker
+}
+
+func TestWindowedReadWriteSeeker_Read(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 2, 6)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(wrws)
+	log.PanicIf(err)
+
+	if string(recovered) != "2345" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Read_Unbounded(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewUnboundedWindowedReadWriteSeeker(sb, 7)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(wrws)
+	log.PanicIf(err)
+
+	if string(recovered) != "789" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Write(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 2, 6)
+	log.PanicIf(err)
+
+	_, err = wrws.Seek(1, io.SeekStart)
+	log.PanicIf(err)
+
+	n, err := wrws.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	if n != 3 {
+		t.Fatalf("Write count not correct: (%d)", n)
+	} else if string(sb.Bytes()) != "012abc6789" {
+		t.Fatalf("Data not correct: [%s]", string(sb.Bytes()))
+	}
+
+	position, err := wrws.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 4 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+}
+
+func TestWindowedReadWriteSeeker_Write_BeyondEnd(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 2, 6)
+	log.PanicIf(err)
+
+	_, err = wrws.Seek(2, io.SeekStart)
+	log.PanicIf(err)
+
+	n, err := wrws.Write([]byte("abc"))
+
+	var wbe *WindowBoundaryError
+	if errors.As(err, &wbe) == false {
+		t.Fatalf("Expected boundary error: [%v]", err)
+	} else if wbe.Operation != "write" || wbe.Offset != 2 || wbe.Length != 3 || wbe.WindowLength != 4 {
+		t.Fatalf("Boundary error not correct: %v", wbe)
+	} else if n != 0 {
+		t.Fatalf("Expected nothing to be written: (%d)", n)
+	} else if string(sb.Bytes()) != "0123456789" {
+		t.Fatalf("Data was modified: [%s]", string(sb.Bytes()))
+	}
+
+	// Writing right up to the end is fine.
+
+	_, err = wrws.Write([]byte("ab"))
+	log.PanicIf(err)
+
+	if string(sb.Bytes()) != "0123ab6789" {
+		t.Fatalf("Data not correct: [%s]", string(sb.Bytes()))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Write_Unbounded(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewUnboundedWindowedReadWriteSeeker(sb, 8)
+	log.PanicIf(err)
+
+	_, err = wrws.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	_, err = wrws.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	if string(sb.Bytes()) != "0123456789abc" {
+		t.Fatalf("Data not correct: [%s]", string(sb.Bytes()))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Seek(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 2, 6)
+	log.PanicIf(err)
+
+	position, err := wrws.Seek(-1, io.SeekEnd)
+	log.PanicIf(err)
+
+	if position != 3 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+
+	// Seeking before the start is clamped.
+
+	position, err = wrws.Seek(-10, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 0 {
+		t.Fatalf("Position not correct after clamp: (%d)", position)
+	}
+
+	position, err = wrws.Seek(4, io.SeekStart)
+	log.PanicIf(err)
+
+	if position != 4 {
+		t.Fatalf("Position not correct at end: (%d)", position)
+	}
+
+	_, err = wrws.Seek(5, io.SeekStart)
+
+	var wbe *WindowBoundaryError
+	if errors.As(err, &wbe) == false {
+		t.Fatalf("Expected boundary error: [%v]", err)
+	} else if wbe.Operation != "seek" || wbe.Offset != 5 {
+		t.Fatalf("Boundary error not correct: %v", wbe)
+	}
+
+	position, err = wrws.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 4 {
+		t.Fatalf("Failed seek moved the position: (%d)", position)
+	}
+}
+
+func TestWindowedReadWriteSeeker_Seek_End_ShortUnderlying(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 8, 20)
+	log.PanicIf(err)
+
+	position, err := wrws.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	if position != 2 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+}
+
+func TestWindowedReadWriteSeeker_ReadAt(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("0123456789"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 2, 6)
+	log.PanicIf(err)
+
+	err = ritesting.CheckReaderAt(wrws, []byte("2345"))
+	log.PanicIf(err)
+
+	// Through a source that can only seek.
+
+	wrws, err = NewWindowedReadWriteSeeker(testPlainReadWriteSeeker{sb}, 2, 6)
+	log.PanicIf(err)
+
+	err = ritesting.CheckReaderAt(wrws, []byte("2345"))
+	log.PanicIf(err)
+}
+
+func TestWindowedReadWriteSeeker_WriteAt(t *testing.T) {
+	for _, rws := range []io.ReadWriteSeeker{NewSeekableBufferWithBytes([]byte("0123456789")), testPlainReadWriteSeeker{NewSeekableBufferWithBytes([]byte("0123456789"))}} {
+		wrws, err := NewWindowedReadWriteSeeker(rws, 2, 6)
+		log.PanicIf(err)
+
+		_, err = wrws.WriteAt([]byte("ab"), 2)
+		log.PanicIf(err)
+
+		_, err = wrws.WriteAt([]byte("abc"), 2)
+
+		var wbe *WindowBoundaryError
+		if errors.As(err, &wbe) == false {
+			t.Fatalf("Expected boundary error: [%v]", err)
+		}
+
+		_, err = wrws.WriteAt([]byte("a"), -1)
+		if err != ErrNegativeOffset {
+			t.Fatalf("Expected negative-offset error: [%v]", err)
+		}
+
+		recovered, err := ioutil.ReadAll(wrws)
+		log.PanicIf(err)
+
+		if string(recovered) != "23ab" {
+			t.Fatalf("Data not correct: [%s]", string(recovered))
+		}
+	}
+}
+
+func TestNewWindowedReadWriteSeeker_Invalid(t *testing.T) {
+	sb := NewSeekableBuffer()
+
+	_, err := NewWindowedReadWriteSeeker(sb, 5, 4)
+	if err == nil {
+		t.Fatalf("Expected error for inverted window.")
+	}
+
+	_, err = NewUnboundedWindowedReadWriteSeeker(sb, -1)
+	if err == nil {
+		t.Fatalf("Expected error for negative start.")
+	}
+}
+
+func TestWindowedReadWriteSeeker_Size_Grows(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("header"))
+
+	wrws, err := NewUnboundedWindowedReadWriteSeeker(sb, 6)
+	log.PanicIf(err)
+
+	if wrws.Size() != 0 {
+		t.Fatalf("Initial size not correct: (%d)", wrws.Size())
+	}
+
+	_, err = wrws.Write([]byte("abc"))
+	log.PanicIf(err)
+
+	_, err = wrws.WriteAt([]byte("de"), 3)
+	log.PanicIf(err)
+
+	if wrws.Size() != 5 {
+		t.Fatalf("Size not correct: (%d)", wrws.Size())
+	}
+
+	position, err := wrws.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	if position != 5 {
+		t.Fatalf("End position not correct: (%d)", position)
+	} else if string(sb.Bytes()) != "headerabcde" {
+		t.Fatalf("Data not correct: [%s]", string(sb.Bytes()))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Size_Container(t *testing.T) {
+	// The window covers the "abc" block and has room to grow into the
+	// padding. The trailer that follows is never exposed.
+
+	sb := NewSeekableBufferWithBytes([]byte("abc"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 0, 8)
+	log.PanicIf(err)
+
+	_, err = sb.WriteAt([]byte("TRAILER"), 8)
+	log.PanicIf(err)
+
+	if wrws.Size() != 3 {
+		t.Fatalf("Size not correct: (%d)", wrws.Size())
+	}
+
+	position, err := wrws.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	if position != 3 {
+		t.Fatalf("End position not correct: (%d)", position)
+	}
+
+	_, err = wrws.Write([]byte("de"))
+	log.PanicIf(err)
+
+	_, err = wrws.Seek(0, io.SeekStart)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(wrws)
+	log.PanicIf(err)
+
+	if string(recovered) != "abcde" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	} else if string(sb.Bytes()[8:]) != "TRAILER" {
+		t.Fatalf("Trailer was modified: [%s]", string(sb.Bytes()[8:]))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Write_Gap(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcXXXXXXX"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 0, 10)
+	log.PanicIf(err)
+
+	err = wrws.Truncate(3)
+	log.PanicIf(err)
+
+	_, err = wrws.WriteAt([]byte("z"), 6)
+	log.PanicIf(err)
+
+	recovered := make([]byte, 7)
+
+	_, err = wrws.ReadAt(recovered, 0)
+	log.PanicIf(err)
+
+	if string(recovered) != "abc\x00\x00\x00z" {
+		t.Fatalf("Gap not filled with zeros: %v", recovered)
+	}
+}
+
+func TestWindowedReadWriteSeeker_Truncate_Shrink_End(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("headerabcdef"))
+
+	wrws, err := NewUnboundedWindowedReadWriteSeeker(sb, 6)
+	log.PanicIf(err)
+
+	_, err = wrws.Seek(0, io.SeekEnd)
+	log.PanicIf(err)
+
+	err = wrws.Truncate(2)
+	log.PanicIf(err)
+
+	if wrws.Size() != 2 {
+		t.Fatalf("Size not correct: (%d)", wrws.Size())
+	} else if string(sb.Bytes()) != "headerab" {
+		t.Fatalf("Underlying not truncated: [%s]", string(sb.Bytes()))
+	}
+
+	// The position is unchanged.
+
+	position, err := wrws.Seek(0, io.SeekCurrent)
+	log.PanicIf(err)
+
+	if position != 6 {
+		t.Fatalf("Position not correct: (%d)", position)
+	}
+
+	_, err = wrws.Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Fatalf("Expected EOF: [%v]", err)
+	}
+}
+
+func TestWindowedReadWriteSeeker_Truncate_Shrink_Middle(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("abcdefTRAILER"))
+
+	wrws, err := NewWindowedReadWriteSeeker(sb, 0, 6)
+	log.PanicIf(err)
+
+	err = wrws.Truncate(2)
+	log.PanicIf(err)
+
+	recovered, err := ioutil.ReadAll(wrws)
+	log.PanicIf(err)
+
+	if string(recovered) != "ab" {
+		t.Fatalf("Data not correct: [%s]", string(recovered))
+	} else if string(sb.Bytes()) != "abcdefTRAILER" {
+		t.Fatalf("Underlying was modified: [%s]", string(sb.Bytes()))
+	}
+}
+
+func TestWindowedReadWriteSeeker_Truncate_Grow(t *testing.T) {
+	sb := NewSeekableBufferWithBytes([]byte("ab"))
+
+	wrws, err := NewWindowedReadWriteSeeker(testPlainReadWriteSeeker{sb}, 0, 4)
+	log.PanicIf(err)
+
+	err = wrws.Truncate(4)
+	log.PanicIf(err)
+
+	if wrws.Size() != 4 {
+		t.Fatalf("Size not correct: (%d)", wrws.Size())
+	} else if string(sb.Bytes()) != "ab\x00\x00" {
+		t.Fatalf("Data not correct: %v", sb.Bytes())
+	}
+
+	err = wrws.Truncate(5)
+
+	var wbe *WindowBoundaryError
+	if errors.As(err, &wbe) == false {
+		t.Fatalf("Expected boundary error: [%v]", err)
+	} else if wbe.Operation != "truncate" || wbe.Offset != 5 {
+		t.Fatalf("Boundary error not correct: %v", wbe)
+	}
+
+	err = wrws.Truncate(-1)
+	if err != ErrNegativeOffset {
+		t.Fatalf("Expected negative-offset error: [%v]", err)
+	}
+}
@@ -2,12 +2,17 @@ package rifs
 
 import (
 	"io"
+	"sync/atomic"
 )
 
 // WriteCounter proxies write requests and maintains a counter of bytes written.
+// The count may be read from another goroutine while writes are in progress.
 type WriteCounter struct {
-	w       io.Writer
-	counter int
+	// counter is first so that it is aligned for atomic access on 32-bit
+	// platforms.
+	counter int64
+
+	w io.Writer
 }
 
 // NewWriteCounter returns a new `WriteCounter` struct wrapping a `Writer`.
@@ -17,20 +22,20 @@ func NewWriteCounter(w io.Writer) *WriteCounter {
 	}
 }
 
-// Count returns the total number of bytes read.
-func (wc *WriteCounter) Count() int {
-	return wc.counter
+// Count returns the total number of bytes written.
+func (wc *WriteCounter) Count() int64 {
+	return atomic.LoadInt64(&wc.counter)
 }
 
 // Reset resets the counter to zero.
 func (wc *WriteCounter) Reset() {
-	wc.counter = 0
+	atomic.StoreInt64(&wc.counter, 0)
 }
 
 // Write forwards a write to the underlying `Writer` while bumping the counter.
 func (wc *WriteCounter) Write(b []byte) (n int, err error) {
 	n, err = wc.w.Write(b)
-	wc.counter += n
+	atomic.AddInt64(&wc.counter, int64(n))
 
 	return n, err
 }
@@ -24,7 +24,7 @@ func TestWriteCounter_Write(t *testing.T) {
 		t.Fatalf("Written data not correct:\nACTUAL:\n%v\nEXPECTED:\n%v", writtenBytes, []byte(s))
 	}
 
-	if wc.Count() != len(s) {
+	if wc.Count() != int64(len(s)) {
 		t.Fatalf("Counter not correct: (%d)", wc.Count())
 	}
 
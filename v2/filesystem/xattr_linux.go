@@ -0,0 +1,89 @@
+package rifs
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// isXattrUnsupported returns true if the error means that the filesystem or
+// the current user can not have the attribute.
+func isXattrUnsupported(err error) bool {
+	return err == syscall.ENOTSUP || err == syscall.EPERM
+}
+
+// readXattrNames returns the names of the extended attributes on the given
+// path.
+func readXattrNames(filepath string) (names []string, err error) {
+	size, err := syscall.Listxattr(filepath, nil)
+	if err != nil {
+		return nil, err
+	} else if size == 0 {
+		return nil, nil
+	}
+
+	buffer := make([]byte, size)
+
+	size, err = syscall.Listxattr(filepath, buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	names = make([]string, 0)
+	for _, name := range bytes.Split(buffer[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+
+	return names, nil
+}
+
+// readXattr returns the value of one extended attribute.
+func readXattr(filepath, name string) (value []byte, err error) {
+	size, err := syscall.Getxattr(filepath, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	value = make([]byte, size)
+
+	size, err = syscall.Getxattr(filepath, name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value[:size], nil
+}
+
+// copyXattrs copies the extended attributes from one path to another.
+// Attributes that the destination does not support or that we are not
+// permitted to set are skipped.
+func copyXattrs(fromFilepath, toFilepath string) (err error) {
+	names, err := readXattrNames(fromFilepath)
+	if err != nil {
+		if isXattrUnsupported(err) == true {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, name := range names {
+		value, err := readXattr(fromFilepath, name)
+		if err != nil {
+			// It may have been removed since we listed it.
+			if err == syscall.ENODATA {
+				continue
+			}
+
+			return err
+		}
+
+		err = syscall.Setxattr(toFilepath, name, value, 0)
+		if err != nil && isXattrUnsupported(err) == false {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package rifs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	fromFilepath := path.Join(tempPath, "from")
+	toFilepath := path.Join(tempPath, "to")
+
+	err = ioutil.WriteFile(fromFilepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(toFilepath, []byte("abc"), 0644)
+	log.PanicIf(err)
+
+	err = syscall.Setxattr(fromFilepath, "user.test", []byte("value"), 0)
+	if err == syscall.ENOTSUP {
+		t.Skip("Extended attributes are not supported by the temporary filesystem.")
+	}
+
+	log.PanicIf(err)
+
+	err = copyXattrs(fromFilepath, toFilepath)
+	log.PanicIf(err)
+
+	value, err := readXattr(toFilepath, "user.test")
+	log.PanicIf(err)
+
+	if string(value) != "value" {
+		t.Fatalf("Extended attribute not copied: [%s]", string(value))
+	}
+}
+
+func TestCopyTree_Xattrs(t *testing.T) {
+	sourcePath := newCopyTreeTestSource()
+	defer os.RemoveAll(sourcePath)
+
+	destinationPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(destinationPath)
+
+	err = syscall.Setxattr(path.Join(sourcePath, "file1"), "user.test", []byte("value"), 0)
+	if err == syscall.ENOTSUP {
+		t.Skip("Extended attributes are not supported by the temporary filesystem.")
+	}
+
+	log.PanicIf(err)
+
+	options := CopyTreeOptions{
+		PreserveXattrs: true,
+	}
+
+	_, err = CopyTree(sourcePath, destinationPath, options)
+	log.PanicIf(err)
+
+	names, err := readXattrNames(path.Join(destinationPath, "file1"))
+	log.PanicIf(err)
+
+	if len(names) != 1 || names[0] != "user.test" {
+		t.Fatalf("Extended attributes not copied: %v", names)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package rifs
+
+// copyXattrs is a no-op on this platform.
+func copyXattrs(fromFilepath, toFilepath string) (err error) {
+	return nil
+}
@@ -1,6 +1,6 @@
 module github.com/dsoprea/go-utility/v2
 
-go 1.12
+go 1.16
 
 // Development only
 // replace github.com/dsoprea/go-exif/v3 => ../../go-exif/v3
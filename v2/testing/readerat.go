@@ -0,0 +1,163 @@
+package ritesting
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// readerAtExhaustiveSize is the largest size for which every offset and
+	// length is checked. Larger data is sampled.
+	readerAtExhaustiveSize = 64
+
+	readerAtSampleCount = 2000
+
+	readerAtConcurrency = 8
+)
+
+// CheckReaderAt checks that the given `io.ReaderAt` honors the contract of
+// that interface when serving the expected data. Reads that fit must return
+// the full count and no error (or `io.EOF` if they end exactly at the end).
+// Reads that cross or start past the end must return what is available along
+// with an unwrapped `io.EOF`. Empty reads must return zero, negative offsets
+// must fail, and concurrent reads must be safe. An error describing the first
+// violation is returned.
+func CheckReaderAt(ra io.ReaderAt, expected []byte) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	size := int64(len(expected))
+
+	if size <= readerAtExhaustiveSize {
+		for offset := int64(0); offset <= size+2; offset++ {
+			for length := 0; int64(length) <= size+2; length++ {
+				err := checkReaderAtRead(ra, expected, offset, length)
+				log.PanicIf(err)
+			}
+		}
+	} else {
+		r := rand.New(rand.NewSource(1))
+
+		for i := 0; i < readerAtSampleCount; i++ {
+			offset := r.Int63n(size + 2)
+			length := r.Intn(1024)
+
+			err := checkReaderAtRead(ra, expected, offset, length)
+			log.PanicIf(err)
+		}
+
+		// The whole thing, and across the end.
+
+		err := checkReaderAtRead(ra, expected, 0, int(size))
+		log.PanicIf(err)
+
+		err = checkReaderAtRead(ra, expected, size-1, 2)
+		log.PanicIf(err)
+	}
+
+	n, err := ra.ReadAt(make([]byte, 1), -1)
+	if err == nil {
+		log.Panicf("negative offset did not fail")
+	} else if n != 0 {
+		log.Panicf("negative offset returned data: (%d)", n)
+	}
+
+	err = checkReaderAtConcurrent(ra, expected)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// checkReaderAtRead checks one read.
+func checkReaderAtRead(ra io.ReaderAt, expected []byte, offset int64, length int) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	size := int64(len(expected))
+
+	buffer := make([]byte, length)
+
+	n, err := ra.ReadAt(buffer, offset)
+
+	available := size - offset
+	if available < 0 {
+		available = 0
+	}
+
+	if length == 0 {
+		if n != 0 {
+			log.Panicf("empty read at (%d) returned (%d)", offset, n)
+		} else if err != nil && err != io.EOF {
+			log.Panicf("empty read at (%d) failed: %v", offset, err)
+		}
+
+		return nil
+	}
+
+	if int64(length) <= available {
+		if n != length {
+			log.Panicf("read of (%d) at (%d) was short: (%d)", length, offset, n)
+		} else if err != nil && (err != io.EOF || int64(length) != available) {
+			log.Panicf("read of (%d) at (%d) failed: %v", length, offset, err)
+		}
+	} else {
+		if int64(n) != available {
+			log.Panicf("read of (%d) at (%d) across the end returned (%d) instead of (%d)", length, offset, n, available)
+		} else if err != io.EOF {
+			log.Panicf("read of (%d) at (%d) across the end did not return io.EOF: %v", length, offset, err)
+		}
+	}
+
+	if n > 0 && bytes.Equal(buffer[:n], expected[offset:offset+int64(n)]) != true {
+		log.Panicf("read of (%d) at (%d) returned the wrong data", length, offset)
+	}
+
+	return nil
+}
+
+// checkReaderAtConcurrent does random reads from several goroutines.
+func checkReaderAtConcurrent(ra io.ReaderAt, expected []byte) (err error) {
+	size := int64(len(expected))
+	if size == 0 {
+		return nil
+	}
+
+	wg := new(sync.WaitGroup)
+	errC := make(chan error, readerAtConcurrency)
+
+	for i := 0; i < readerAtConcurrency; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(int64(i)))
+
+			for j := 0; j < 100; j++ {
+				offset := r.Int63n(size)
+				length := r.Intn(int(size-offset)) + 1
+
+				err := checkReaderAtRead(ra, expected, offset, length)
+				if err != nil {
+					errC <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errC)
+
+	return <-errC
+}
@@ -0,0 +1,61 @@
+package ritesting
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCheckReaderAt(t *testing.T) {
+	expected := []byte("some test data")
+
+	err := CheckReaderAt(bytes.NewReader(expected), expected)
+	log.PanicIf(err)
+
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+
+	err = CheckReaderAt(bytes.NewReader(large), large)
+	log.PanicIf(err)
+
+	err = CheckReaderAt(bytes.NewReader(nil), nil)
+	log.PanicIf(err)
+}
+
+// unexpectedEofReaderAt reports short reads the way `io.ReadFull` does.
+type unexpectedEofReaderAt struct {
+	data []byte
+}
+
+func (uera unexpectedEofReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	} else if offset >= int64(len(uera.data)) {
+		return 0, io.EOF
+	}
+
+	n = copy(p, uera.data[offset:])
+	if n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, nil
+}
+
+func TestCheckReaderAt_Violation(t *testing.T) {
+	expected := []byte("some test data")
+
+	err := CheckReaderAt(unexpectedEofReaderAt{expected}, expected)
+	if err == nil {
+		t.Fatalf("Expected violation.")
+	}
+
+	// The wrong data.
+
+	err = CheckReaderAt(bytes.NewReader([]byte("some best data")), expected)
+	if err == nil {
+		t.Fatalf("Expected violation for wrong data.")
+	}
+}